@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// classifyImage returns the scene labels of an image given by the
+// ClassifyCommand of the configuration
+//
+// The command receives the path of the image as last argument and
+// must print the labels separated by commas or new lines, e.g.
+// "mountains, aurora". It can run any local classifier model
+func classifyImage(config *config, imagePath string) ([]string, error) {
+	output, err := runExternal(config.ClassifyCommand, imagePath)
+	if err != nil {
+		return nil, err
+	}
+	var labels []string
+	for _, label := range strings.FieldsFunc(output, func(r rune) bool {
+		return r == ',' || r == '\n' || r == '\r'
+	}) {
+		if label = strings.ToLower(strings.TrimSpace(label)); label != "" {
+			labels = append(labels, label)
+		}
+	}
+	return labels, nil
+}
+
+// tagEntry stores the labels of a wallpaper as its tags, and the
+// category they belong to so the index tells its scene without
+// looking at the [categories] section
+func (c *config) tagEntry(entry *indexEntry, labels []string) {
+	entry.Tags = labels
+	entry.Category = categoryOf(c.categories, labels)
+}
+
+// classifyLibrary labels the wallpapers in the output folder that
+// don't have tags yet and adds them to the index
+func classifyLibrary() {
+	config := loadConfig()
+	if config.ClassifyCommand == "" {
+		log.Fatalln("ClassifyCommand is not set in the configuration")
+	}
+	library, err := loadIndex()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	images, err := libraryImages(config, config.OutputDir)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	classified := 0
+	for _, image := range images {
+		entry := library.get(image.path)
+		if entry == nil {
+			entry = newIndexEntry(image.path, "", nil)
+			entry.Saved = image.modTime
+		}
+		if len(entry.Tags) > 0 {
+			continue
+		}
+		labels, err := classifyImage(config, image.path)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		config.tagEntry(entry, labels)
+		library.put(entry)
+		classified++
+	}
+	if err := library.save(); err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Println(tr("classified %d wallpapers", classified))
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// logPath returns the path of the log file
+func logPath() string {
+	return filepath.Join(appDir(), "logs.txt")
+}
+
+// parseAge reads an age like 90d, 2w or any Go duration like 36h
+func parseAge(age string) (time.Duration, error) {
+	units := map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour}
+	for suffix, unit := range units {
+		if count, ok := strings.CutSuffix(age, suffix); ok {
+			n, err := strconv.Atoi(count)
+			if err != nil || n < 0 {
+				return 0, fmt.Errorf("%s is not an age like 90d", age)
+			}
+			return time.Duration(n) * unit, nil
+		}
+	}
+	duration, err := time.ParseDuration(age)
+	if err != nil || duration < 0 {
+		return 0, fmt.Errorf("%s is not an age like 90d", age)
+	}
+	return duration, nil
+}
+
+// cleanOldData removes the logs and the state of the program older
+// than a given age, so its own footprint doesn't keep growing
+//
+// With --logs the old lines of the log file are removed. With --state
+// the journals of old runs are removed, so they can't be undone anymore,
+// the index and the seen assets forget wallpapers whose file no longer
+// exists and the failed copies of sources that are gone are dropped.
+// Without either of them both are cleaned
+//
+// With --undersized the saved wallpapers that don't pass the current
+// minimum size are removed, or moved to the folder given in --move-to
+func cleanOldData(args []string) {
+	flags := newFlagSet("clean")
+	logs := flags.Bool("logs", false, "remove old lines of the log file")
+	state := flags.Bool("state", false, "remove old run journals and the state of missing files")
+	olderThan := flags.String("older-than", "90d", "age from which logs and runs are removed, like 90d, 2w or 36h")
+	undersized := flags.Bool("undersized", false, "remove the saved wallpapers smaller than MinimumWidth and MinimumHeight")
+	moveTo := flags.String("move-to", "", "with --undersized, move the wallpapers to this folder instead of removing them")
+	flags.Parse(args)
+	if *undersized {
+		removed, err := cleanUndersized(*moveTo)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		if *moveTo != "" {
+			fmt.Println(tr("moved %d wallpapers smaller than the minimum size to %s", removed, *moveTo))
+		} else {
+			fmt.Println(tr("removed %d wallpapers smaller than the minimum size", removed))
+		}
+		if !*logs && !*state {
+			return
+		}
+	}
+	if !*logs && !*state {
+		*logs, *state = true, true
+	}
+	age, err := parseAge(*olderThan)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	cutoff := time.Now().Add(-age)
+
+	if *logs {
+		removed, err := cleanLog(logPath(), cutoff)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		fmt.Println(tr("removed %d old log lines", removed))
+	}
+	if *state {
+		removed, err := cleanJournals(cutoff)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		fmt.Println(tr("removed %d old run journals", removed))
+		orphans, err := cleanOrphanedEntries()
+		if err != nil {
+			log.Fatalln(err)
+		}
+		fmt.Println(tr("removed %d index entries of missing files", orphans))
+		forgotten, err := cleanSeenAssets()
+		if err != nil {
+			log.Fatalln(err)
+		}
+		fmt.Println(tr("forgot %d seen assets whose saved file is missing", forgotten))
+		failures, err := cleanFailedFiles()
+		if err != nil {
+			log.Fatalln(err)
+		}
+		fmt.Println(tr("removed %d failed copies of missing sources", failures))
+	}
+}
+
+// cleanUndersized removes the saved wallpapers that don't pass the
+// size checks of a run, or moves them to moveTo, and returns how
+// many were removed
+//
+// With MatchDisplay the minimum size comes from the monitors, as in a run
+func cleanUndersized(moveTo string) (int, error) {
+	config := loadConfig()
+	config.matchDisplay(newLeveledLogger("", config.logLevel()))
+	library, err := loadIndex()
+	if err != nil {
+		return 0, err
+	}
+	files, err := libraryFiles(config, library)
+	if err != nil {
+		return 0, err
+	}
+	if moveTo != "" {
+		moveTo = normalizePath(moveTo)
+		if err := os.MkdirAll(moveTo, 0755); err != nil {
+			return 0, err
+		}
+	}
+	removed := 0
+	for _, file := range files {
+		if file.width == 0 || config.isWallpaperSize(file.width, file.height) {
+			continue
+		}
+		if moveTo != "" {
+			err = moveWallpaper(file.path, freePath(moveTo, filepath.Base(file.path)))
+		} else {
+			err = removeWallpaper(file.path)
+		}
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		fmt.Printf("%s (%dx%d)\n", file.path, file.width, file.height)
+		library.remove(file.path)
+		removed++
+	}
+	return removed, library.save()
+}
+
+// cleanLog removes the lines of a log file written before cutoff,
+// returning how many were removed
+//
+// Lines without a date, like the blank lines between runs,
+// are kept or removed along with the lines before them
+func cleanLog(logFilePath string, cutoff time.Time) (int, error) {
+	data, err := os.ReadFile(logFilePath)
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	var kept bytes.Buffer
+	removed := 0
+	old := true
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if len(line) >= len(time.DateTime) {
+			if written, err := time.ParseInLocation("2006/01/02 15:04:05", line[:len(time.DateTime)], time.Local); err == nil {
+				old = written.Before(cutoff)
+			}
+		}
+		if old {
+			removed++
+			continue
+		}
+		kept.WriteString(line + "\n")
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+	return removed, os.WriteFile(logFilePath, kept.Bytes(), 0644)
+}
+
+// cleanJournals removes the journals of the runs started before cutoff
+func cleanJournals(cutoff time.Time) (int, error) {
+	paths, err := filepath.Glob(filepath.Join(journalDir(), "*.json"))
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, journalPath := range paths {
+		name := strings.TrimSuffix(filepath.Base(journalPath), ".json")
+		started, err := time.ParseInLocation("20060102-150405", name, time.Local)
+		if err != nil || !started.Before(cutoff) {
+			continue
+		}
+		if err := os.Remove(journalPath); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// cleanOrphanedEntries removes from the index the wallpapers
+// whose file was deleted or moved outside of the program
+func cleanOrphanedEntries() (int, error) {
+	library, err := loadIndex()
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, entry := range library.sorted(func(a, b *indexEntry) bool { return a.Path < b.Path }) {
+		if _, err := os.Stat(entry.Path); os.IsNotExist(err) {
+			library.remove(entry.Path)
+			removed++
+		}
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+	return removed, library.save()
+}
+
+// cleanSeenAssets forgets the seen assets whose saved file or the
+// file they duplicate no longer exists, so they are considered again,
+// and returns how many were forgotten
+func cleanSeenAssets() (int, error) {
+	seen, err := loadSeen()
+	if err != nil {
+		return 0, err
+	}
+	var missing []string
+	removed := 0
+	for _, assets := range seen.Jobs {
+		for _, asset := range assets {
+			if asset.Path == "" {
+				continue
+			}
+			if _, err := os.Stat(asset.Path); os.IsNotExist(err) {
+				missing = append(missing, asset.Path)
+				removed++
+			}
+		}
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+	seen.forgetSaved(missing)
+	return removed, seen.save()
+}
+
+// cleanFailedFiles drops the failed copies of source files that no
+// longer exist, which can't be retried, and returns how many were dropped
+func cleanFailedFiles() (int, error) {
+	failed, err := loadFailed()
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for jobName, files := range failed.Jobs {
+		for sourcePath := range files {
+			if _, err := os.Stat(sourcePath); os.IsNotExist(err) {
+				delete(files, sourcePath)
+				removed++
+			}
+		}
+		if len(files) == 0 {
+			delete(failed.Jobs, jobName)
+		}
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+	return removed, failed.save()
+}
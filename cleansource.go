@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// fileChecksum returns the SHA-256 of the file in the given path
+func fileChecksum(filePath string) ([]byte, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return nil, err
+	}
+	return hash.Sum(nil), nil
+}
+
+// isSpotlightCache tells whether a folder is one of the known
+// Spotlight locations, whatever the user folder it's in
+func isSpotlightCache(dir string) bool {
+	parts := strings.Split(filepath.Clean(dir), string(filepath.Separator))
+	for _, pattern := range knownSourcePatterns {
+		patternParts := strings.Split(pattern, string(filepath.Separator))
+		if len(parts) < len(patternParts) {
+			continue
+		}
+		matched := true
+		for i, patternPart := range patternParts {
+			part := parts[len(parts)-len(patternParts)+i]
+			if ok, _ := filepath.Match(strings.ToLower(patternPart), strings.ToLower(part)); !ok {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// verifySaved tells whether a saved wallpaper holds the same image as
+// its source: the same bytes, or the same picture when it was rotated
+// upright and re-encoded
+func verifySaved(sourcePath string, savedPath string) error {
+	sourceChecksum, err := fileChecksum(sourcePath)
+	if err != nil {
+		return err
+	}
+	savedChecksum, err := fileChecksum(savedPath)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(sourceChecksum, savedChecksum) {
+		return nil
+	}
+	sourceImage, err := decodeImage(sourcePath)
+	if err != nil {
+		return err
+	}
+	savedImage, err := decodeImage(savedPath)
+	if err != nil {
+		return err
+	}
+	sourceHash := perceptualHash(orientImage(sourceImage, imageOrientation(sourcePath)))
+	if hammingDistance(sourceHash, perceptualHash(savedImage)) > 2 {
+		return fmt.Errorf("%s doesn't match %s", savedPath, sourcePath)
+	}
+	return nil
+}
+
+// cleanSource deletes a harvested asset from the Spotlight cache
+// when the run was started with --clean-source
+//
+// Only files in a known Spotlight location are deleted, and only once
+// the saved wallpaper is verified to hold the same image, so a custom
+// SourceDir or a failed save never loses a file
+func (j *job) cleanSource(ctx *runContext, sourcePath string, savedPath string) {
+	if !ctx.cleanSource || ctx.dryRun {
+		return
+	}
+	if !isSpotlightCache(filepath.Dir(sourcePath)) {
+		j.logger.Printf("not cleaning %s, it isn't in a Spotlight folder\n", sourcePath)
+		return
+	}
+	if err := verifySaved(sourcePath, savedPath); err != nil {
+		j.logger.Errorf("not cleaning %s: %s\n", sourcePath, err)
+		return
+	}
+	if err := os.Remove(sourcePath); err != nil {
+		j.logger.Errorf("couldn't remove %s: %s\n", sourcePath, err)
+		return
+	}
+	j.logger.Printf("removed %s from the source\n", sourcePath)
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// command is a subcommand of the program
+//
+// Commands that parse their own flags take any number of
+// arguments, the rest are checked against minArgs and maxArgs
+type command struct {
+	name    string
+	args    string
+	summary string
+	minArgs int
+	maxArgs int
+	run     func(args []string)
+}
+
+// runSettings are the options of a run
+type runSettings struct {
+	jobs        string
+	cleanSource bool
+	dryRun      bool
+	json        bool
+	retry       bool
+	since       string
+	iniConfig   *ini.File // read instead of the configuration files, like the one the daemon watches
+}
+
+// runOptions are the flags of the run and daemon commands, which
+// are also accepted before the command for older invocations
+// like wspotsave --job phone
+var runOptions runSettings
+
+// addRunFlags adds the flags of the run and daemon commands to a flag set
+func addRunFlags(flags *flag.FlagSet) {
+	flags.StringVar(&runOptions.jobs, "job", runOptions.jobs, "comma separated names of the jobs to run, all jobs run if empty")
+	flags.BoolVar(&runOptions.cleanSource, "clean-source", runOptions.cleanSource, "delete the saved wallpapers from the Spotlight folder once verified")
+	addConfigFlags(flags)
+}
+
+// addDryRunFlag adds the --dry-run flag of the run command to a flag set
+func addDryRunFlag(flags *flag.FlagSet) {
+	flags.BoolVar(&runOptions.dryRun, "dry-run", runOptions.dryRun, "print which files would be copied and where, without writing anything")
+}
+
+// addJSONFlag adds the --json flag of the run command to a flag set
+func addJSONFlag(flags *flag.FlagSet) {
+	flags.BoolVar(&runOptions.json, "json", runOptions.json, "print the result of the run as JSON")
+}
+
+// addSinceFlag adds the --since flag of the run command to a flag set
+func addSinceFlag(flags *flag.FlagSet) {
+	flags.StringVar(&runOptions.since, "since", runOptions.since, "only consider the Spotlight files modified since a date like 2024-06-01, an age like 7d, or last-run")
+}
+
+// runCommandLine saves the new wallpapers once
+func runCommandLine(args []string) {
+	flags := newFlagSet("run")
+	addRunFlags(flags)
+	addDryRunFlag(flags)
+	addJSONFlag(flags)
+	addSinceFlag(flags)
+	flags.Parse(args)
+	if flags.NArg() != 0 {
+		flags.Usage()
+		os.Exit(1)
+	}
+	runAndReport()
+}
+
+// retryCommandLine tries again to copy the files that
+// couldn't be copied in the last run
+func retryCommandLine(args []string) {
+	flags := newFlagSet("retry")
+	addRunFlags(flags)
+	addJSONFlag(flags)
+	flags.Parse(args)
+	if flags.NArg() != 0 {
+		flags.Usage()
+		os.Exit(1)
+	}
+	failed, err := loadFailed()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if failed.count() == 0 && !runOptions.json {
+		fmt.Println(tr("there are no failed copies to retry"))
+		return
+	}
+	runOptions.retry = true
+	runAndReport()
+}
+
+// runAndReport runs the jobs once with the run options,
+// reports the result and exits with its exit code
+func runAndReport() {
+	openLog()
+	stats, err := runOnce(runOptions)
+	if !runOptions.dryRun {
+		if err := newRunState(stats, err).save(); err != nil {
+			logError(err)
+		}
+		if !runOptions.json {
+			printSummary(stats)
+		}
+	}
+	if runOptions.json {
+		if err := printRunResult(stats, err); err != nil {
+			logError(err)
+		}
+	}
+	if err != nil {
+		logError(err)
+		os.Exit(exitCode(err))
+	}
+}
+
+// runDaemonCommandLine starts the daemon
+func runDaemonCommandLine(args []string) {
+	flags := newFlagSet("daemon")
+	addRunFlags(flags)
+	flags.Parse(args)
+	if flags.NArg() != 0 {
+		flags.Usage()
+		os.Exit(1)
+	}
+	openLog()
+	runDaemon(runOptions.jobs, runOptions.cleanSource)
+}
+
+// anyArgs is the maxArgs of the commands that parse their own flags
+// with newFlagSet and check their own arguments
+const anyArgs = -1
+
+// commands returns the subcommands of the program
+func commands() []command {
+	return []command{
+		{"run", "[--job NAMES] [--clean-source] [--dry-run] [--json] [--since DATE] [--profile NAME] [--source folder] [--output folder]", "save the new wallpapers, the default when no command is given", 0, anyArgs, runCommandLine},
+		{"retry", "[--job NAMES] [--clean-source] [--json]", "try again to copy the files that failed in the last run", 0, anyArgs, retryCommandLine},
+		{"config", "[get <key> | set <key> <value>]", "show the configuration, or get or set one of its keys", 0, 3, configCommand},
+		{"restore", "", "restore the default configuration", 0, 0, func([]string) {
+			fmt.Println(tr("restoring default configuration"))
+			restoreConfig(configPath())
+		}},
+		{"version", "[--check]", "print the version and how it was built", 0, anyArgs, showVersion},
+		{"help", "[command]", "show the commands or the help of a command", 0, 1, showHelp},
+		{"apply", "<image>", "set a saved wallpaper as the desktop background", 1, 1, func(args []string) { applyWallpaper(args[0]) }},
+		{"review", "", "approve or reject the wallpapers waiting in the pending folder", 0, 0, func([]string) { review() }},
+		{"undo", "[--dry-run]", "remove the files copied by the most recent run", 0, anyArgs, undoLastRun},
+		{"list", "[--job NAMES]", "list the files of the Spotlight folder and whether they would be copied", 0, anyArgs, listSources},
+		{"show", "[--print-path] latest|<name>", "open the latest or a given saved wallpaper in the image viewer", 1, anyArgs, showWallpaper},
+		{"prune", "[--distance N] [--quarantine folder] [--delete]", "move the duplicated wallpapers of the output folder to a quarantine folder", 0, anyArgs, pruneLibrary},
+		{"verify", "[--record]", "check the saved wallpapers against the checksums recorded when copied", 0, anyArgs, verifyLibrary},
+		{"stats", "", "show how many wallpapers are saved, their disk usage and resolutions", 0, 0, func([]string) { showStats() }},
+		{"search", "<words or filters>", "list the wallpapers whose title, description, tags or name contain the words and that pass the filters", 1, anyArgs, searchLibrary},
+		{"find", "--color <#rrggbb|name|warm|cool|neutral> [--tolerance N]", "list the wallpapers with a similar color or tone", 0, anyArgs, findByColor},
+		{"stacks", "[--keep-best]", "list the groups of similar wallpapers", 0, anyArgs, showStacks},
+		{"export", "[--top N] [--since date] [--until date] <folder or file.zip>", "copy wallpapers of the library to a folder or archive", 0, anyArgs, exportLibrary},
+		{"score", "", "score the saved wallpapers with ScoreCommand", 0, 0, func([]string) { scoreLibrary() }},
+		{"classify", "", "tag the saved wallpapers with ClassifyCommand", 0, 0, func([]string) { classifyLibrary() }},
+		{"reclassify", "", "tag the library again and move it to the category folders", 0, 0, func([]string) { reclassifyLibrary() }},
+		{"upright", "", "rotate upright the saved wallpapers that have an EXIF orientation", 0, 0, func([]string) { uprightLibrary() }},
+		{"lockscreen", "", "create the missing lock screen crops", 0, 0, func([]string) { lockScreenLibrary() }},
+		{"span", "[--size WxH] [--output file] [--apply] <image> [<image>]", "compose a wallpaper that spans two monitors", 0, anyArgs, spanWallpaper},
+		{"clean", "[--logs] [--state] [--older-than AGE] [--undersized [--move-to folder]]", "remove old logs and state, or the wallpapers smaller than the minimum size", 0, anyArgs, cleanOldData},
+		{"schedule", "install|uninstall", "run the program daily", 1, 1, func(args []string) { schedule(args[0]) }},
+		{"daemon", "[--job NAMES] [--clean-source]", "keep running and save new wallpapers periodically", 0, anyArgs, runDaemonCommandLine},
+		{"ctl", "scan|status|events", "control the running daemon", 1, 1, controlDaemon},
+		{"trigger", "", "ask the running daemon to scan now", 0, 0, func([]string) { trigger() }},
+		{"telemetry", "status|on|off|preview", "show or change the anonymous usage statistics", 1, 1, func(args []string) { telemetry(args[0]) }},
+		{"secret", "set|delete <name>", "store a password or token in the Windows Credential Manager, to use in the configuration as secret:<name>", 2, 2, secretCommand},
+		{"doctor", "", "check why wallpapers may not be copied and how to fix it", 0, 0, func([]string) { doctor() }},
+		{"self-update", "", "replace the program with the latest release", 0, 0, func([]string) { selfUpdate() }},
+	}
+}
+
+// findCommand returns the command with the given name
+func findCommand(name string) (command, bool) {
+	for _, c := range commands() {
+		if c.name == name {
+			return c, true
+		}
+	}
+	return command{}, false
+}
+
+// commandUsage returns the usage line of a command
+func commandUsage(c command) string {
+	return strings.TrimSpace("wspotsave " + c.name + " " + c.args)
+}
+
+// printCommandHelp prints the usage line and the summary of a command
+func printCommandHelp(c command) {
+	fmt.Println(tr("Usage: %s", commandUsage(c)))
+	fmt.Println()
+	fmt.Println(tr(c.summary))
+}
+
+// newFlagSet returns the flag set of a command, which
+// prints the help of the command on -h or a wrong flag
+func newFlagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	flags.Usage = func() {
+		if c, ok := findCommand(name); ok {
+			printCommandHelp(c)
+		}
+		if hasFlags(flags) {
+			fmt.Println()
+			flags.PrintDefaults()
+		}
+	}
+	return flags
+}
+
+// hasFlags tells whether a flag set defines any flag
+func hasFlags(flags *flag.FlagSet) bool {
+	defined := false
+	flags.VisitAll(func(*flag.Flag) { defined = true })
+	return defined
+}
+
+// runSubcommand runs the command named by the first argument,
+// checking its arguments and answering -h and --help
+func runSubcommand(args []string) {
+	c, ok := findCommand(args[0])
+	if !ok {
+		fmt.Println(tr("Unknown command %s", args[0]))
+		fmt.Println()
+		showHelp(nil)
+		os.Exit(1)
+	}
+	args = args[1:]
+	if c.maxArgs != anyArgs {
+		if len(args) == 1 && (args[0] == "-h" || args[0] == "--help") {
+			printCommandHelp(c)
+			return
+		}
+		if len(args) < c.minArgs || len(args) > c.maxArgs {
+			fmt.Println(tr("Usage: %s", commandUsage(c)))
+			os.Exit(1)
+		}
+	} else if len(args) < c.minArgs {
+		fmt.Println(tr("Usage: %s", commandUsage(c)))
+		os.Exit(1)
+	}
+	c.run(args)
+}
+
+// showHelp prints the commands, or the help of the given command
+func showHelp(args []string) {
+	if len(args) == 1 {
+		c, ok := findCommand(args[0])
+		if !ok {
+			fmt.Println(tr("Unknown command %s", args[0]))
+			os.Exit(1)
+		}
+		if c.maxArgs == anyArgs {
+			// commands with flags print them in their own help
+			c.run([]string{"-h"})
+			return
+		}
+		printCommandHelp(c)
+		return
+	}
+	fmt.Println(tr("Usage: %s", "wspotsave [--portable] [command]"))
+	fmt.Println()
+	fmt.Println(tr("Commands:"))
+	for _, c := range commands() {
+		fmt.Printf("  %-12s %s\n", c.name, tr(c.summary))
+	}
+	fmt.Println()
+	fmt.Println(tr("Use wspotsave help <command> for the help of a command."))
+}
+
+// showConfig prints the configuration files and the values in use
+func showConfig() {
+	if _, err := os.Stat(machineConfigPath()); err == nil && !portable {
+		fmt.Println(tr("machine-wide configuration: %s", machineConfigPath()))
+	}
+	if path := alternateConfigPath(); path != "" {
+		fmt.Println(tr("configuration: %s", path))
+	} else {
+		fmt.Println(tr("configuration: %s", configPath()))
+	}
+	fmt.Println()
+	for _, key := range configInUse(loadConfig()).Keys() {
+		if _, ok := os.LookupEnv(envName(key.Name())); ok {
+			fmt.Printf("%s = %s  (%s)\n", key.Name(), key.Value(), envName(key.Name()))
+			continue
+		}
+		fmt.Printf("%s = %s\n", key.Name(), key.Value())
+	}
+}
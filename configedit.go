@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// configCommand shows the configuration, or gets or sets one of its keys
+func configCommand(args []string) {
+	if len(args) == 0 {
+		showConfig()
+		return
+	}
+	switch {
+	case args[0] == "get" && len(args) == 2:
+		fmt.Println(getConfigValue(args[1]))
+	case args[0] == "set" && len(args) == 3:
+		setConfigValue(args[1], args[2])
+	default:
+		c, _ := findCommand("config")
+		fmt.Println(tr("Usage: %s", commandUsage(c)))
+		os.Exit(1)
+	}
+}
+
+// splitConfigKey returns the section and the name of a key, given as
+// Key for the default section or as job.name.Key for a job, with the
+// name spelled as in the configuration
+func splitConfigKey(key string) (string, string) {
+	section := ini.DefaultSection
+	if i := strings.LastIndex(key, "."); i >= 0 {
+		section, key = key[:i], key[i+1:]
+	}
+	keys := ini.Empty()
+	if err := keys.Section("").ReflectFrom(defaultConfig()); err != nil {
+		log.Fatalln(err)
+	}
+	for _, name := range keys.Section("").KeyStrings() {
+		if strings.EqualFold(name, key) {
+			return section, name
+		}
+	}
+	fatalConfig(fmt.Errorf("%s is not a configuration key", key))
+	return "", ""
+}
+
+// getConfigValue returns the value in use of a configuration key
+func getConfigValue(key string) string {
+	section, name := splitConfigKey(key)
+	iniConfig := loadIniConfig()
+	config := configFromIni(iniConfig)
+	if section != ini.DefaultSection {
+		jobName, ok := strings.CutPrefix(section, jobSectionPrefix)
+		if !ok {
+			fatalConfig(fmt.Errorf("%s is not a job section", section))
+		}
+		jobs, err := loadJobs(iniConfig, config, jobName)
+		if err != nil {
+			fatalConfig(err)
+		}
+		config = jobs[0].config
+	}
+	return configInUse(config).Key(name).Value()
+}
+
+// configInUse returns the values of a configuration as a section
+func configInUse(config *config) *ini.Section {
+	inUse := ini.Empty()
+	if err := inUse.Section("").ReflectFrom(config); err != nil {
+		log.Fatalln(err)
+	}
+	if len(config.extraOutputDirs) > 0 {
+		outputDirs := append([]string{config.OutputDir}, config.extraOutputDirs...)
+		inUse.Section("").Key("OutputDir").SetValue(strings.Join(outputDirs, ";"))
+	}
+	config.hideSecrets(inUse.Section(""))
+	return inUse.Section("")
+}
+
+// setConfigValue writes a configuration key to the per-user
+// configuration, after checking that the value is valid
+//
+// The rest of the file, comments included, is kept as it is
+func setConfigValue(key string, value string) {
+	section, name := splitConfigKey(key)
+	if path := alternateConfigPath(); path != "" {
+		fatalConfig(fmt.Errorf("config set only changes wspotsave.ini, edit %s instead", path))
+	}
+	path := configPath()
+	iniConfig := ini.Empty()
+	if _, err := os.Stat(path); err == nil {
+		iniConfig, err = loadINIConfig(path)
+		if err != nil {
+			fatalConfig(fmt.Errorf("couldn't read the configuration: %s", err))
+		}
+	}
+	iniConfig.Section(section).Key(name).SetValue(value)
+	if err := iniConfig.Section(section).StrictMapTo(defaultConfig()); err != nil {
+		fatalConfig(fmt.Errorf("invalid value %q for %s: %s", value, name, err))
+	}
+	// wrong values elsewhere in the file don't stop fixing this one
+	configFromIni(iniConfig)
+	problems := slices.DeleteFunc(validateConfig(iniConfig), func(problem configProblem) bool {
+		return problem.section != section || problem.key != name
+	})
+	if len(problems) > 0 {
+		fatalConfig(configError(problems))
+	}
+	if err := iniConfig.SaveTo(path); err != nil {
+		log.Fatalln(err)
+	}
+	if section != ini.DefaultSection {
+		name = section + "." + name
+	}
+	fmt.Println(tr("%s set to %s in %s", name, value, path))
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/ini.v1"
+	"gopkg.in/yaml.v3"
+)
+
+// alternateConfigNames are the configuration files in other formats
+// that are used instead of wspotsave.ini when present
+var alternateConfigNames = []string{"wspotsave.yaml", "wspotsave.yml", "wspotsave.toml"}
+
+// alternateConfigPath returns the path of the configuration file in
+// another format than INI, or an empty string if there is none
+func alternateConfigPath() string {
+	for _, name := range alternateConfigNames {
+		path := filepath.Join(appDir(), name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// loadConfigFile reads a configuration file in INI, YAML or TOML format
+//
+// YAML and TOML files are read into the same sections as an INI file:
+// keys at the top are the default section and nested tables are
+// sections named by their path, like job.phone
+func loadConfigFile(path string) (*ini.File, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return loadYAMLConfig(path)
+	case ".toml":
+		return loadTOMLConfig(path)
+	}
+	return loadINIConfig(path)
+}
+
+// loadINIConfig reads an INI configuration file
+//
+// Comments after a value need a space before the ; or #, so values
+// like lists of folders separated by ; are kept whole
+func loadINIConfig(path string) (*ini.File, error) {
+	return ini.LoadSources(ini.LoadOptions{SpaceBeforeInlineComment: true}, path)
+}
+
+// mergeConfig sets the keys of overrides in base
+func mergeConfig(base *ini.File, overrides *ini.File) {
+	for _, section := range overrides.Sections() {
+		for _, key := range section.Keys() {
+			base.Section(section.Name()).Key(key.Name()).SetValue(key.Value())
+		}
+	}
+}
+
+// loadYAMLConfig reads a YAML configuration file
+func loadYAMLConfig(path string) (*ini.File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var values map[string]any
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("couldn't read %s: %s", path, err)
+	}
+	iniConfig := ini.Empty()
+	var add func(section string, values map[string]any)
+	add = func(section string, values map[string]any) {
+		for key, value := range values {
+			switch value := value.(type) {
+			case map[string]any:
+				name := key
+				if section != "" {
+					name = section + "." + key
+				}
+				add(name, value)
+			case []any:
+				items := make([]string, len(value))
+				for i, item := range value {
+					items[i] = fmt.Sprint(item)
+				}
+				iniConfig.Section(section).Key(key).SetValue(strings.Join(items, ","))
+			case nil:
+				iniConfig.Section(section).Key(key).SetValue("")
+			default:
+				iniConfig.Section(section).Key(key).SetValue(fmt.Sprint(value))
+			}
+		}
+	}
+	add("", values)
+	return iniConfig, nil
+}
+
+// loadTOMLConfig reads a TOML configuration file
+//
+// Only what a configuration needs is supported: tables, and keys with
+// strings, numbers, booleans or arrays of them as values
+func loadTOMLConfig(path string) (*ini.File, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	iniConfig := ini.Empty()
+	section := ""
+	scanner := bufio.NewScanner(file)
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimSpace(stripTOMLComment(scanner.Text()))
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") || strings.HasPrefix(line, "[[") {
+				return nil, fmt.Errorf("%s:%d: %s is not a table", path, number, line)
+			}
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: missing = in %s", path, number, line)
+		}
+		parsed, err := parseTOMLValue(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", path, number, err)
+		}
+		iniConfig.Section(section).Key(strings.Trim(strings.TrimSpace(key), `"`)).SetValue(parsed)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return iniConfig, nil
+}
+
+// stripTOMLComment removes a comment from a line, outside of strings
+func stripTOMLComment(line string) string {
+	var quote rune
+	for i, r := range line {
+		switch {
+		case quote != 0 && r == quote && (quote == '\'' || i == 0 || line[i-1] != '\\'):
+			quote = 0
+		case quote == 0 && (r == '"' || r == '\''):
+			quote = r
+		case quote == 0 && r == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// parseTOMLValue returns a TOML value as the text of an INI value,
+// arrays as comma separated items
+func parseTOMLValue(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, `"`):
+		unquoted, err := strconv.Unquote(value)
+		if err != nil {
+			return "", fmt.Errorf("%s is not a valid string", value)
+		}
+		return unquoted, nil
+	case strings.HasPrefix(value, "'"):
+		if len(value) < 2 || !strings.HasSuffix(value, "'") {
+			return "", fmt.Errorf("%s is not a valid string", value)
+		}
+		return value[1 : len(value)-1], nil
+	case strings.HasPrefix(value, "["):
+		if !strings.HasSuffix(value, "]") {
+			return "", fmt.Errorf("arrays must be written in one line")
+		}
+		var items []string
+		for _, item := range strings.Split(value[1:len(value)-1], ",") {
+			item = strings.TrimSpace(item)
+			if item == "" {
+				continue
+			}
+			parsed, err := parseTOMLValue(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, parsed)
+		}
+		return strings.Join(items, ","), nil
+	case value == "true" || value == "false":
+		return value, nil
+	}
+	if _, err := strconv.ParseFloat(strings.ReplaceAll(value, "_", ""), 64); err != nil {
+		return "", fmt.Errorf("%s is not a string, number or boolean", value)
+	}
+	return strings.ReplaceAll(value, "_", ""), nil
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"sync"
+	"time"
+)
+
+// maxDaemonEvents is how many events the daemon keeps for clients
+const maxDaemonEvents = 100
+
+// DaemonEvent is something that happened in the daemon
+type DaemonEvent struct {
+	Seq     int
+	Time    time.Time
+	Message string
+}
+
+// DaemonStatus is the state of the daemon reported to clients
+type DaemonStatus struct {
+	Version   string
+	Running   bool
+	LastRun   time.Time
+	LastError string
+	NextRun   time.Time
+	Scanned   int
+	Copied    int
+}
+
+// EventsArgs asks for the events after a sequence number, waiting
+// up to WaitSeconds for a new one when there isn't any
+type EventsArgs struct {
+	After       int
+	WaitSeconds int
+}
+
+// Control is the JSON-RPC service of the daemon, so scripts and
+// interfaces control the single running instance instead of
+// starting their own runs
+type Control struct {
+	mu      sync.Mutex
+	changed *sync.Cond
+	status  DaemonStatus
+	events  []DaemonEvent
+	seq     int
+	trigger chan struct{}
+}
+
+// newControl returns the control service of a daemon
+func newControl() *Control {
+	c := &Control{status: DaemonStatus{Version: version}, trigger: make(chan struct{}, 1)}
+	c.changed = sync.NewCond(&c.mu)
+	return c
+}
+
+// event records an event and wakes up the clients waiting for one
+func (c *Control) event(format string, args ...any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.seq++
+	message := fmt.Sprintf(format, args...)
+	log.Println(message)
+	c.events = append(c.events, DaemonEvent{c.seq, time.Now(), message})
+	if len(c.events) > maxDaemonEvents {
+		c.events = c.events[len(c.events)-maxDaemonEvents:]
+	}
+	c.changed.Broadcast()
+}
+
+// Scan starts a run as soon as the current one, if any, finishes
+func (c *Control) Scan(_ struct{}, queued *bool) error {
+	select {
+	case c.trigger <- struct{}{}:
+		*queued = true
+	default:
+		// a run is already queued
+		*queued = false
+	}
+	return nil
+}
+
+// Status returns the state of the daemon
+func (c *Control) Status(_ struct{}, status *DaemonStatus) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	*status = c.status
+	return nil
+}
+
+// Events returns the events after args.After, waiting for new ones
+// when there aren't any, so clients can follow the daemon
+func (c *Control) Events(args EventsArgs, events *[]DaemonEvent) error {
+	deadline := time.Now().Add(time.Duration(args.WaitSeconds) * time.Second)
+	timer := time.AfterFunc(time.Until(deadline), func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.changed.Broadcast()
+	})
+	defer timer.Stop()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for c.seq <= args.After && time.Now().Before(deadline) {
+		c.changed.Wait()
+	}
+	*events = nil
+	for _, event := range c.events {
+		if event.Seq > args.After {
+			*events = append(*events, event)
+		}
+	}
+	return nil
+}
+
+// serve accepts JSON-RPC clients on a listener
+func (c *Control) serve(listener net.Listener) {
+	server := rpc.NewServer()
+	if err := server.Register(c); err != nil {
+		log.Fatalln(err)
+	}
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			logError(err)
+			return
+		}
+		go server.ServeCodec(jsonrpc.NewServerCodec(conn))
+	}
+}
+
+// runDaemon keeps running the jobs every DaemonInterval, or when
+// a client asks for it, serving the control API on ControlAddress
+//
+// Changes to the configuration are picked up without restarting,
+// each run uses the configuration as it was when the run started
+func runDaemon(jobNames string, cleanSource bool) {
+	live := newLiveConfig()
+	config := configFromIni(live.current())
+	interval, err := parseAge(config.DaemonInterval)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	listener, err := net.Listen("tcp", config.ControlAddress)
+	if err != nil {
+		fmt.Println(tr("couldn't listen on %s, is another daemon running?", config.ControlAddress))
+		log.Fatalln(err)
+	}
+	control := newControl()
+	go control.serve(listener)
+	go serveTrigger(control)
+	go live.watch(control)
+	fmt.Println(tr("daemon listening on %s", listener.Addr()))
+	control.event("daemon started, listening on %s", listener.Addr())
+
+	state := &runState{Version: version}
+	for {
+		control.mu.Lock()
+		control.status.Running = true
+		control.mu.Unlock()
+		control.event("run started")
+		state.Running, state.DaemonPID = true, os.Getpid()
+		if err := state.save(); err != nil {
+			logError(err)
+		}
+		iniConfig := live.current()
+		if newInterval, err := parseAge(configFromIni(iniConfig).DaemonInterval); err == nil {
+			interval = newInterval
+		}
+		stats, err := runOnce(runSettings{jobs: jobNames, cleanSource: cleanSource, iniConfig: iniConfig})
+		state = newRunState(stats, err)
+		nextRun := time.Now().Add(interval)
+		state.NextRun, state.DaemonPID = &nextRun, os.Getpid()
+		if err := state.save(); err != nil {
+			logError(err)
+		}
+
+		control.mu.Lock()
+		control.status.Running = false
+		control.status.LastRun = time.Now()
+		control.status.NextRun = time.Now().Add(interval)
+		control.status.LastError = ""
+		if err != nil {
+			control.status.LastError = err.Error()
+		}
+		if stats != nil {
+			control.status.Scanned, control.status.Copied = stats.Scanned, stats.Copied
+		}
+		control.mu.Unlock()
+		if err != nil {
+			control.event("run failed: %s", err)
+		} else {
+			control.event("run finished, copied %d of %d files", stats.Copied, stats.Scanned)
+		}
+
+		select {
+		case <-time.After(interval):
+		case <-control.trigger:
+			control.event("scan requested")
+		}
+		openLogFor(configFromIni(live.current()))
+	}
+}
+
+// controlDaemon sends a command to the running daemon: scan to start
+// a run, status to print its state and events to follow what it does
+func controlDaemon(args []string) {
+	if len(args) != 1 {
+		fmt.Println(tr("Usage: wspotsave ctl scan|status|events"))
+		os.Exit(1)
+	}
+	config := loadConfig()
+	client, err := jsonrpc.Dial("tcp", config.ControlAddress)
+	if err != nil {
+		fmt.Println(tr("the daemon isn't running"))
+		os.Exit(1)
+	}
+	defer client.Close()
+	switch args[0] {
+	case "scan":
+		var queued bool
+		if err := client.Call("Control.Scan", struct{}{}, &queued); err != nil {
+			log.Fatalln(err)
+		}
+		if queued {
+			fmt.Println(tr("scan requested"))
+		} else {
+			fmt.Println(tr("a scan is already requested"))
+		}
+	case "status":
+		var status DaemonStatus
+		if err := client.Call("Control.Status", struct{}{}, &status); err != nil {
+			log.Fatalln(err)
+		}
+		fmt.Println(tr("version: %s", status.Version))
+		fmt.Println(tr("running: %t", status.Running))
+		if !status.LastRun.IsZero() {
+			fmt.Println(tr("last run: %s, copied %d of %d files", status.LastRun.Format(time.DateTime), status.Copied, status.Scanned))
+			fmt.Println(tr("next run: %s", status.NextRun.Format(time.DateTime)))
+		}
+		if status.LastError != "" {
+			fmt.Println(tr("last error: %s", status.LastError))
+		}
+	case "events":
+		after := 0
+		for {
+			var events []DaemonEvent
+			if err := client.Call("Control.Events", EventsArgs{After: after, WaitSeconds: 30}, &events); err != nil {
+				log.Fatalln(err)
+			}
+			for _, event := range events {
+				fmt.Printf("%s %s\n", event.Time.Format(time.DateTime), event.Message)
+				after = event.Seq
+			}
+		}
+	default:
+		fmt.Println(tr("Usage: wspotsave ctl scan|status|events"))
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import "fmt"
+
+// display is the resolution of a monitor in pixels
+type display struct {
+	Width  int
+	Height int
+}
+
+// largestDisplay returns the attached monitor with the most pixels
+func largestDisplay() (display, error) {
+	displays, err := attachedDisplays()
+	if err != nil {
+		return display{}, err
+	}
+	if len(displays) == 0 {
+		return display{}, fmt.Errorf("couldn't find any monitor")
+	}
+	largest := displays[0]
+	for _, d := range displays[1:] {
+		if d.Width*d.Height > largest.Width*largest.Height {
+			largest = d
+		}
+	}
+	return largest, nil
+}
+
+// combinedDisplaySize returns the size of the attached monitors side
+// by side, the sum of their widths and the tallest height
+func combinedDisplaySize() (int, int, error) {
+	displays, err := attachedDisplays()
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(displays) == 0 {
+		return 0, 0, fmt.Errorf("couldn't find any monitor")
+	}
+	var width, height int
+	for _, d := range displays {
+		width += d.Width
+		height = max(height, d.Height)
+	}
+	return width, height, nil
+}
+
+// matchDisplay replaces the minimum size with the resolution of the
+// largest monitor when MatchDisplay is set
+//
+// The long side of the monitor is the minimum of the long side of
+// images and its short side the one of their short side, so portrait
+// images are measured the same as landscape ones and a monitor turned
+// to portrait gives the same minimum as when it is upright. If the
+// monitors can't be queried the configured minimum size is kept
+func (c *config) matchDisplay(logger *leveledLogger) {
+	if !c.MatchDisplay {
+		return
+	}
+	d, err := largestDisplay()
+	if err != nil {
+		logger.Errorf("couldn't query the monitors, keeping the minimum size: %s\n", err)
+		return
+	}
+	c.MinimumWidth, c.MinimumHeight = max(d.Width, d.Height), min(d.Width, d.Height)
+	c.sidesMinimum = true
+	logger.Printf("minimum size set to %dx%d from the largest monitor\n", c.MinimumWidth, c.MinimumHeight)
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+)
+
+// displayResolution matches the resolution lines of system_profiler,
+// e.g. "Resolution: 2880 x 1800 Retina"
+var displayResolution = regexp.MustCompile(`Resolution: (\d+) x (\d+)`)
+
+// attachedDisplays returns the resolution of the monitors using system_profiler
+func attachedDisplays() ([]display, error) {
+	output, err := exec.Command("system_profiler", "SPDisplaysDataType").Output()
+	if err != nil {
+		return nil, fmt.Errorf("couldn't run system_profiler: %s", err)
+	}
+	var displays []display
+	for _, match := range displayResolution.FindAllSubmatch(output, -1) {
+		width, _ := strconv.Atoi(string(match[1]))
+		height, _ := strconv.Atoi(string(match[2]))
+		displays = append(displays, display{width, height})
+	}
+	return displays, nil
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+)
+
+// xrandrMode matches the current mode of a connected output,
+// e.g. "HDMI-1 connected primary 2560x1440+0+0"
+var xrandrMode = regexp.MustCompile(` connected (?:primary )?(\d+)x(\d+)\+`)
+
+// attachedDisplays returns the resolution of the monitors using xrandr,
+// which also works on Wayland sessions through XWayland
+func attachedDisplays() ([]display, error) {
+	output, err := exec.Command("xrandr", "--current").Output()
+	if err != nil {
+		return nil, fmt.Errorf("couldn't run xrandr: %s", err)
+	}
+	var displays []display
+	for _, match := range xrandrMode.FindAllSubmatch(output, -1) {
+		width, _ := strconv.Atoi(string(match[1]))
+		height, _ := strconv.Atoi(string(match[2]))
+		displays = append(displays, display{width, height})
+	}
+	return displays, nil
+}
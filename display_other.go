@@ -0,0 +1,13 @@
+//go:build !linux && !windows && !darwin
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// attachedDisplays is not supported on this platform
+func attachedDisplays() ([]display, error) {
+	return nil, fmt.Errorf("querying the monitors is not supported on %s", runtime.GOOS)
+}
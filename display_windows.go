@@ -0,0 +1,80 @@
+package main
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+const (
+	enumCurrentSettings            = 0xFFFFFFFF
+	displayDeviceAttachedToDesktop = 0x1
+)
+
+var (
+	procEnumDisplayDevicesW  = windows.NewLazySystemDLL("user32.dll").NewProc("EnumDisplayDevicesW")
+	procEnumDisplaySettingsW = windows.NewLazySystemDLL("user32.dll").NewProc("EnumDisplaySettingsW")
+)
+
+// displayDevice is the DISPLAY_DEVICEW structure
+type displayDevice struct {
+	cb           uint32
+	DeviceName   [32]uint16
+	DeviceString [128]uint16
+	StateFlags   uint32
+	DeviceID     [128]uint16
+	DeviceKey    [128]uint16
+}
+
+// devMode is the display part of the DEVMODEW structure
+type devMode struct {
+	DeviceName       [32]uint16
+	SpecVersion      uint16
+	DriverVersion    uint16
+	Size             uint16
+	DriverExtra      uint16
+	Fields           uint32
+	Position         [16]byte
+	Color            int16
+	Duplex           int16
+	YResolution      int16
+	TTOption         int16
+	Collate          int16
+	FormName         [32]uint16
+	LogPixels        uint16
+	BitsPerPel       uint32
+	PelsWidth        uint32
+	PelsHeight       uint32
+	DisplayFlags     uint32
+	DisplayFrequency uint32
+	Reserved         [8]uint32
+}
+
+// attachedDisplays returns the resolution of the monitors of the desktop
+//
+// The modes are read from the display devices, so they are physical
+// pixels regardless of the scaling of the desktop
+func attachedDisplays() ([]display, error) {
+	var displays []display
+	for i := uintptr(0); ; i++ {
+		device := displayDevice{cb: uint32(unsafe.Sizeof(displayDevice{}))}
+		ret, _, _ := procEnumDisplayDevicesW.Call(0, i, uintptr(unsafe.Pointer(&device)), 0)
+		if ret == 0 {
+			break
+		}
+		if device.StateFlags&displayDeviceAttachedToDesktop == 0 {
+			continue
+		}
+		mode := devMode{Size: uint16(unsafe.Sizeof(devMode{}))}
+		ret, _, err := procEnumDisplaySettingsW.Call(
+			uintptr(unsafe.Pointer(&device.DeviceName[0])),
+			enumCurrentSettings,
+			uintptr(unsafe.Pointer(&mode)),
+		)
+		if ret == 0 {
+			return nil, err
+		}
+		displays = append(displays, display{int(mode.PelsWidth), int(mode.PelsHeight)})
+	}
+	return displays, nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// diagnosis prints the outcome of the checks of the doctor command
+// and counts the problems found
+type diagnosis struct {
+	color    bool
+	problems int
+}
+
+// ok prints a check that passed
+func (d *diagnosis) ok(message string) {
+	fmt.Printf("%s %s\n", d.paint(colorGreen, " ok "), message)
+}
+
+// skip prints a check that couldn't be done
+func (d *diagnosis) skip(message string) {
+	fmt.Printf("%s %s\n", d.paint(colorGray, " -- "), message)
+}
+
+// fail prints a problem and what to do about it
+func (d *diagnosis) fail(problem string, hint string) {
+	d.problems++
+	fmt.Printf("%s %s\n", d.paint(colorRed, " !! "), problem)
+	fmt.Printf("     %s\n", d.paint(colorYellow, hint))
+}
+
+func (d *diagnosis) paint(code string, text string) string {
+	if !d.color {
+		return text
+	}
+	return code + text + colorReset
+}
+
+// doctor checks why wallpapers may not be copied: the configuration,
+// the Spotlight setting and the source and output folders of every job
+func doctor() {
+	d := &diagnosis{color: supportsColor(os.Stdout)}
+	iniConfig := d.checkConfig()
+	applyProfile(iniConfig)
+	applyEnvOverrides(iniConfig)
+	applyFlagOverrides(iniConfig)
+	config := configFromIni(iniConfig)
+	jobs, err := loadJobs(iniConfig, config, "")
+	if err != nil {
+		d.fail(err.Error(), tr("fix the job in the configuration"))
+		jobs = []*job{newJob("", config)}
+	}
+
+	reason, err := spotlightDisabledReason()
+	switch {
+	case err != nil:
+		d.skip(err.Error())
+	case reason != "":
+		d.fail(reason, tr("choose Windows spotlight in Settings > Personalization > Lock screen, or ask your administrator about the policy"))
+	default:
+		d.ok(tr("Windows Spotlight is enabled"))
+	}
+
+	for _, j := range jobs {
+		d.checkSource(j)
+		d.checkOutput(j)
+	}
+
+	fmt.Println()
+	if d.problems > 0 {
+		fmt.Println(tr("%d problems found", d.problems))
+		os.Exit(exitFailure)
+	}
+	fmt.Println(tr("no problems found"))
+}
+
+// checkConfig checks that the configuration files can be read and
+// their values are valid, and returns the configuration to check
+// the rest with
+func (d *diagnosis) checkConfig() *ini.File {
+	files := configFiles()
+	if len(files) == 0 {
+		d.ok(tr("there is no configuration file yet, the defaults are used"))
+		return ini.Empty()
+	}
+	iniConfig := ini.Empty()
+	for _, path := range files {
+		file, err := loadConfigWithIncludes(path)
+		if err != nil {
+			d.fail(tr("the configuration can't be read: %s", err), tr("fix the file or run wspotsave restore to write the defaults"))
+			return ini.Empty()
+		}
+		mergeConfig(iniConfig, file)
+	}
+	problems := validateConfig(iniConfig)
+	for _, problem := range problems {
+		d.fail(tr("the configuration has a wrong value, %s", problem), problem.fix)
+	}
+	if len(problems) == 0 {
+		d.ok(tr("the configuration is valid: %s", strings.Join(files, ", ")))
+	}
+	return iniConfig
+}
+
+// checkSource checks that the source folder of a job exists and has images
+func (d *diagnosis) checkSource(j *job) {
+	prefix := j.logger.Prefix()
+	dirs := []string{j.config.SourceDir}
+	if checkDirectory(j.config.SourceDir) != nil {
+		dirs = knownSourceDirs()
+		if len(dirs) == 0 {
+			d.fail(prefix+tr("no Spotlight folder found, %s doesn't exist", j.config.SourceDir),
+				tr("set SourceDir to the folder where Spotlight keeps its images"))
+			return
+		}
+		d.skip(prefix + tr("SourceDir %s doesn't exist, the known Spotlight folders are used", j.config.SourceDir))
+	}
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		switch {
+		case err != nil:
+			d.fail(prefix+tr("the source folder can't be read: %s", err), tr("check the permissions of the folder"))
+		case len(entries) == 0:
+			d.fail(prefix+tr("the source folder %s is empty", dir),
+				tr("Spotlight hasn't downloaded images yet or is disabled, keep Windows spotlight as the lock screen for a day"))
+		default:
+			d.ok(prefix + tr("the source folder %s has %d files", dir, len(entries)))
+		}
+	}
+}
+
+// checkOutput checks that the output folder of a job exists
+// and files can be written in it
+func (d *diagnosis) checkOutput(j *job) {
+	prefix := j.logger.Prefix()
+	outputDir := j.config.OutputDir
+	if err := checkDirectory(outputDir); err != nil {
+		d.fail(prefix+tr("the output folder is not usable: %s", err), tr("create the folder or set OutputDir to an existing folder"))
+		return
+	}
+	if !isWritableDir(outputDir) {
+		d.fail(prefix+tr("the output folder %s is not writable", outputDir), tr("set OutputDir to a folder you can write to"))
+		return
+	}
+	d.ok(prefix + tr("the output folder %s is writable", outputDir))
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"gopkg.in/ini.v1"
+)
+
+// envPrefix is the prefix of the environment variables
+// that override the configuration
+const envPrefix = "WSPOTSAVE_"
+
+// envAliases are shorter names accepted for some environment variables
+var envAliases = map[string]string{
+	"MIN_WIDTH":  "MinimumWidth",
+	"MIN_HEIGHT": "MinimumHeight",
+}
+
+// flagOverrides are the configuration keys set with command-line flags
+// for a single run, which take precedence over everything else
+var flagOverrides = make(map[string]string)
+
+// addConfigFlags adds the flags that override configuration keys to a flag set
+func addConfigFlags(flags *flag.FlagSet) {
+	overrideFlag := func(name string, key string, usage string, isNumber bool) {
+		flags.Func(name, usage, func(value string) error {
+			if _, err := strconv.Atoi(value); isNumber && err != nil {
+				return fmt.Errorf("%s is not a number", value)
+			}
+			flagOverrides[key] = value
+			return nil
+		})
+	}
+	flags.StringVar(&selectedProfile, "profile", selectedProfile, "the profile of the configuration to use, like phone for [profile.phone]")
+	overrideFlag("source", "SourceDir", "the folder to look for wallpapers in, instead of SourceDir", false)
+	overrideFlag("output", "OutputDir", "the folder to save the wallpapers to, instead of OutputDir", false)
+	overrideFlag("min-width", "MinimumWidth", "the minimum width of the wallpapers, instead of MinimumWidth", true)
+	overrideFlag("min-height", "MinimumHeight", "the minimum height of the wallpapers, instead of MinimumHeight", true)
+}
+
+// configuredOutputDirs are the output folders of the configuration
+// before a command-line flag overrides them
+var configuredOutputDirs []string
+
+// adHocOutput tells whether the run saves to an output folder given in
+// a command-line flag that isn't one of the configured ones, so what
+// it saves isn't part of the library
+func adHocOutput() bool {
+	dir, ok := flagOverrides["OutputDir"]
+	if !ok {
+		return false
+	}
+	return !slices.Contains(configuredOutputDirs, normalizePath(dir))
+}
+
+// applyFlagOverrides sets the keys of the default section given
+// in command-line flags
+func applyFlagOverrides(iniConfig *ini.File) {
+	if _, ok := flagOverrides["OutputDir"]; ok {
+		outputDir := defaultConfig().OutputDir
+		if section := iniConfig.Section(""); section.HasKey("OutputDir") {
+			outputDir = section.Key("OutputDir").String()
+		}
+		configuredOutputDirs = nil
+		for _, dir := range strings.Split(outputDir, ";") {
+			configuredOutputDirs = append(configuredOutputDirs, normalizePath(dir))
+		}
+	}
+	for key, value := range flagOverrides {
+		iniConfig.Section("").Key(key).SetValue(value)
+	}
+}
+
+// envName returns the environment variable of a configuration key,
+// e.g. WSPOTSAVE_OUTPUT_DIR for OutputDir
+func envName(key string) string {
+	runes := []rune(key)
+	var name strings.Builder
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			previousLower := !unicode.IsUpper(runes[i-1])
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if previousLower || nextLower {
+				name.WriteByte('_')
+			}
+		}
+		name.WriteRune(unicode.ToUpper(r))
+	}
+	return envPrefix + name.String()
+}
+
+// applyEnvOverrides sets the keys of the default section that have an
+// environment variable set, which takes precedence over the files
+func applyEnvOverrides(iniConfig *ini.File) {
+	keys := ini.Empty()
+	if err := keys.Section("").ReflectFrom(defaultConfig()); err != nil {
+		log.Fatalln(err)
+	}
+	section := iniConfig.Section("")
+	for _, key := range keys.Section("").KeyStrings() {
+		if value, ok := os.LookupEnv(envName(key)); ok {
+			section.Key(key).SetValue(value)
+		}
+	}
+	for alias, key := range envAliases {
+		if value, ok := os.LookupEnv(envPrefix + alias); ok {
+			section.Key(key).SetValue(value)
+		}
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// openSystemLog is only supported on Windows
+func openSystemLog() (io.Writer, error) {
+	return nil, fmt.Errorf("the event log is only available on Windows")
+}
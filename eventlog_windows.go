@@ -0,0 +1,59 @@
+package main
+
+import (
+	"io"
+	"strings"
+
+	"golang.org/x/sys/windows/svc/eventlog"
+)
+
+// eventSource is the source of the events in the Application log
+const eventSource = "wspotsave"
+
+// event ids of the informative messages and of the errors
+const (
+	eventInfo  = 1
+	eventError = 2
+)
+
+// eventLogWriter writes every log line as an event of the
+// Application log, errors as error events
+type eventLogWriter struct {
+	log *eventlog.Log
+}
+
+// Write reports a log line, leaving out the date
+// since events have their own
+func (w *eventLogWriter) Write(p []byte) (int, error) {
+	line := strings.TrimSpace(string(p))
+	if len(line) > len("2006/01/02 15:04:05 ") && line[4] == '/' {
+		line = line[len("2006/01/02 15:04:05 "):]
+	}
+	if line == "" {
+		return len(p), nil
+	}
+	var err error
+	if strings.Contains(line, errorPrefix) {
+		err = w.log.Error(eventError, line)
+	} else {
+		err = w.log.Info(eventInfo, line)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// openSystemLog returns a writer to the Windows Event Log
+//
+// Registering the source needs administrator rights, so it usually
+// happens once from an installer or an elevated run. Events are still
+// written without it, only with a less friendly description
+func openSystemLog() (io.Writer, error) {
+	eventlog.InstallAsEventCreate(eventSource, eventlog.Error|eventlog.Warning|eventlog.Info)
+	log, err := eventlog.Open(eventSource)
+	if err != nil {
+		return nil, err
+	}
+	return &eventLogWriter{log}, nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// excludePattern is a pattern of ExcludePatterns, a glob or
+// a regular expression between slashes
+type excludePattern struct {
+	glob   string
+	regexp *regexp.Regexp
+}
+
+// parseExcludePatterns reads the patterns of ExcludePatterns,
+// separated by ;
+func parseExcludePatterns(text string) ([]excludePattern, error) {
+	var patterns []excludePattern
+	for _, pattern := range strings.Split(text, ";") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if expr, ok := strings.CutPrefix(pattern, "/"); ok && strings.HasSuffix(expr, "/") {
+			re, err := regexp.Compile("(?i)" + strings.TrimSuffix(expr, "/"))
+			if err != nil {
+				return nil, fmt.Errorf("%s is not a valid regular expression: %s", pattern, err)
+			}
+			patterns = append(patterns, excludePattern{regexp: re})
+			continue
+		}
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("%s is not a valid pattern: %s", pattern, err)
+		}
+		patterns = append(patterns, excludePattern{glob: strings.ToLower(pattern)})
+	}
+	return patterns, nil
+}
+
+// excluded returns whether a source file name or checksum matches
+// any of the patterns of the job, ignoring case
+func (j *job) excluded(text string) bool {
+	for _, pattern := range j.excludes {
+		if pattern.regexp != nil {
+			if pattern.regexp.MatchString(text) {
+				return true
+			}
+		} else if matched, _ := filepath.Match(pattern.glob, strings.ToLower(text)); matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"unicode/utf16"
+)
+
+// types of the EXIF fields that are written
+const (
+	exifByte      = 1
+	exifASCII     = 2
+	exifLong      = 4
+	exifUndefined = 7
+)
+
+// tags of the EXIF fields that are written
+const (
+	tagImageDescription = 0x010E
+	tagCopyright        = 0x8298
+	tagExifIFD          = 0x8769
+	tagGPSIFD           = 0x8825
+	tagInteropIFD       = 0xA005
+	tagUserComment      = 0x9286
+	tagXPTitle          = 0x9C9B
+)
+
+// exifHeader starts the APP1 segment of EXIF metadata
+const exifHeader = "Exif\x00\x00"
+
+// exifField is a field of an image file directory
+type exifField struct {
+	tag   uint16
+	kind  uint16
+	count uint32
+	value []byte
+}
+
+// embedMetadata writes the title, description and copyright that
+// Spotlight gave a saved JPEG wallpaper into its EXIF, so photo
+// managers show them, when EmbedMetadata is true, and returns
+// whether it was written
+func (j *job) embedMetadata(targetPath string, checksum []byte) bool {
+	if !j.config.EmbedMetadata || j.config.StripMetadata || !isJPEG(targetPath) {
+		return false
+	}
+	metadata, ok := j.metadata[hex.EncodeToString(checksum)]
+	if !ok {
+		return false
+	}
+	if err := writeEXIFMetadata(targetPath, metadata); err != nil {
+		j.logger.Errorln(err)
+		return false
+	}
+	return true
+}
+
+// writeEXIFMetadata writes the metadata into the EXIF segment of a
+// JPEG file, merging it with the fields the segment already has, or
+// adds a segment with it when the file has none
+//
+// The fields of the existing segment are kept, except for the
+// thumbnail, which is left out
+func writeEXIFMetadata(imagePath string, metadata assetMetadata) error {
+	data, err := os.ReadFile(imagePath)
+	if err != nil {
+		return fmt.Errorf("couldn't open %s", imagePath)
+	}
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return fmt.Errorf("couldn't add the metadata of %s: %s", imagePath, errMalformedImage)
+	}
+	var order binary.ByteOrder = binary.BigEndian
+	ifd0 := new(exifDirectory)
+	start, end := findEXIFSegment(data)
+	if start >= 0 {
+		order, ifd0, err = parseTIFF(data[start+4+len(exifHeader) : end])
+		if err != nil {
+			return fmt.Errorf("couldn't add the metadata of %s: %s", imagePath, err)
+		}
+	} else {
+		// EXIF goes right after the start of image, or after a JFIF segment
+		start = 2
+		if data[2] == 0xFF && data[3] == 0xE0 && len(data) >= 6 {
+			start += 2 + int(binary.BigEndian.Uint16(data[4:]))
+		}
+		if start > len(data) {
+			return fmt.Errorf("couldn't add the metadata of %s: %s", imagePath, errMalformedImage)
+		}
+		end = start
+	}
+	setMetadataFields(ifd0, metadata, order)
+	segment, err := encodeEXIFSegment(ifd0, order)
+	if err != nil {
+		return fmt.Errorf("couldn't add the metadata of %s: %s", imagePath, err)
+	}
+	embedded := append(append(append([]byte{}, data[:start]...), segment...), data[end:]...)
+	if err := os.WriteFile(imagePath, embedded, 0644); err != nil {
+		return fmt.Errorf("couldn't write %s", imagePath)
+	}
+	return nil
+}
+
+// findEXIFSegment returns where the EXIF segment of a JPEG image
+// starts and ends among those before the compressed data, or -1
+// when it has none
+func findEXIFSegment(data []byte) (int, int) {
+	offset := 2
+	for offset+4 <= len(data) && data[offset] == 0xFF {
+		marker := data[offset+1]
+		if marker == 0xDA {
+			break
+		}
+		end := offset + 2 + int(binary.BigEndian.Uint16(data[offset+2:]))
+		if marker == 0xE1 && end <= len(data) && bytes.HasPrefix(data[offset+4:end], []byte(exifHeader)) {
+			return offset, end
+		}
+		offset = end
+	}
+	return -1, -1
+}
+
+// setMetadataFields sets the title as image description and Windows
+// title, the copyright, and the description as user comment
+func setMetadataFields(ifd0 *exifDirectory, metadata assetMetadata, order binary.ByteOrder) {
+	if metadata.Title != "" {
+		ifd0.set(asciiField(tagImageDescription, metadata.Title))
+		ifd0.set(utf16Field(tagXPTitle, metadata.Title))
+	}
+	if metadata.Copyright != "" {
+		ifd0.set(asciiField(tagCopyright, metadata.Copyright))
+	}
+	if metadata.Description != "" {
+		ifd0.child(tagExifIFD).set(userCommentField(metadata.Description, order))
+	}
+}
+
+// exifDirectory is an image file directory with the directories its
+// pointer fields lead to, by the tag of the field
+type exifDirectory struct {
+	fields   []exifField
+	children map[uint16]*exifDirectory
+}
+
+// set adds a field to the directory, replacing the one with its tag
+func (d *exifDirectory) set(field exifField) {
+	for i := range d.fields {
+		if d.fields[i].tag == field.tag {
+			d.fields[i] = field
+			return
+		}
+	}
+	d.fields = append(d.fields, field)
+}
+
+// child returns the directory a pointer field leads to, adding
+// the field and an empty directory when there isn't any
+func (d *exifDirectory) child(tag uint16) *exifDirectory {
+	if d.children == nil {
+		d.children = make(map[uint16]*exifDirectory)
+	}
+	if d.children[tag] == nil {
+		d.children[tag] = new(exifDirectory)
+		// the pointer is filled when the directories are encoded
+		d.set(exifField{tag, exifLong, 1, make([]byte, 4)})
+	}
+	return d.children[tag]
+}
+
+// isPointerTag tells whether a field points to another directory
+func isPointerTag(tag uint16) bool {
+	return tag == tagExifIFD || tag == tagGPSIFD || tag == tagInteropIFD
+}
+
+// exifTypeSize returns the size in bytes of a value of a field
+// type, 0 for unknown types
+func exifTypeSize(kind uint16) int {
+	switch kind {
+	case 1, 2, 6, 7:
+		return 1
+	case 3, 8:
+		return 2
+	case 4, 9, 11, 13:
+		return 4
+	case 5, 10, 12:
+		return 8
+	}
+	return 0
+}
+
+// parseTIFF reads the first directory of the TIFF data of an EXIF
+// segment and returns it with the byte order of the data
+func parseTIFF(tiff []byte) (binary.ByteOrder, *exifDirectory, error) {
+	if len(tiff) < 8 {
+		return nil, nil, errMalformedImage
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil, nil, errMalformedImage
+	}
+	ifd0, err := parseIFD(tiff, order, int(order.Uint32(tiff[4:])), 0)
+	return order, ifd0, err
+}
+
+// parseIFD reads the directory at an offset of the TIFF data with the
+// directories its pointer fields lead to, but not the next directory,
+// which holds the thumbnail. Fields of unknown types are left out
+func parseIFD(tiff []byte, order binary.ByteOrder, offset int, depth int) (*exifDirectory, error) {
+	if depth > 2 || offset < 8 || offset+2 > len(tiff) {
+		return nil, errMalformedImage
+	}
+	count := int(order.Uint16(tiff[offset:]))
+	if offset+2+12*count > len(tiff) {
+		return nil, errMalformedImage
+	}
+	dir := &exifDirectory{children: make(map[uint16]*exifDirectory)}
+	for i := 0; i < count; i++ {
+		entry := tiff[offset+2+12*i:]
+		field := exifField{tag: order.Uint16(entry), kind: order.Uint16(entry[2:]), count: order.Uint32(entry[4:])}
+		typeSize := exifTypeSize(field.kind)
+		if typeSize == 0 {
+			continue
+		}
+		if uint64(field.count)*uint64(typeSize) > uint64(len(tiff)) {
+			return nil, errMalformedImage
+		}
+		size := int(field.count) * typeSize
+		if size <= 4 {
+			field.value = bytes.Clone(entry[8 : 8+size])
+		} else {
+			valueOffset := int(order.Uint32(entry[8:]))
+			if valueOffset+size > len(tiff) {
+				return nil, errMalformedImage
+			}
+			field.value = bytes.Clone(tiff[valueOffset : valueOffset+size])
+		}
+		if isPointerTag(field.tag) && size == 4 {
+			child, err := parseIFD(tiff, order, int(order.Uint32(field.value)), depth+1)
+			if err != nil {
+				return nil, err
+			}
+			dir.children[field.tag] = child
+		}
+		dir.fields = append(dir.fields, field)
+	}
+	return dir, nil
+}
+
+// encodeEXIFSegment returns an APP1 segment with a first directory
+// and the ones it points to, in the given byte order
+func encodeEXIFSegment(ifd0 *exifDirectory, order binary.ByteOrder) ([]byte, error) {
+	if len(ifd0.fields) == 0 {
+		return nil, errors.New("no metadata to write")
+	}
+	const ifd0Offset = 8
+	tiff := []byte("MM\x00\x2A\x00\x00\x00\x08")
+	if order == binary.LittleEndian {
+		tiff = []byte("II\x2A\x00\x08\x00\x00\x00")
+	}
+	tiff = append(tiff, encodeDirectory(ifd0, ifd0Offset, order)...)
+
+	length := 2 + len(exifHeader) + len(tiff)
+	if length > 0xFFFF {
+		return nil, errors.New("the metadata is too long")
+	}
+	segment := []byte{0xFF, 0xE1, byte(length >> 8), byte(length)}
+	segment = append(segment, exifHeader...)
+	return append(segment, tiff...), nil
+}
+
+// encodeDirectory returns a directory that starts at the given offset
+// of the TIFF data, followed by the directories it points to
+func encodeDirectory(dir *exifDirectory, offset int, order binary.ByteOrder) []byte {
+	// the entries of a directory are sorted by tag
+	sort.Slice(dir.fields, func(a, b int) bool { return dir.fields[a].tag < dir.fields[b].tag })
+	// pointers fit in their entry, so the size doesn't depend on them
+	next := offset + len(encodeIFD(dir.fields, offset, order))
+	var children []byte
+	for i, field := range dir.fields {
+		child, ok := dir.children[field.tag]
+		if !ok {
+			continue
+		}
+		childOffset := next + len(children)
+		dir.fields[i].value = make([]byte, 4)
+		order.PutUint32(dir.fields[i].value, uint32(childOffset))
+		children = append(children, encodeDirectory(child, childOffset, order)...)
+	}
+	return append(encodeIFD(dir.fields, offset, order), children...)
+}
+
+// encodeIFD returns an image file directory that starts at the given
+// offset of the TIFF data, followed by the values that don't fit
+// in its entries
+func encodeIFD(fields []exifField, offset int, order binary.ByteOrder) []byte {
+	entries := make([]byte, 2, 2+12*len(fields)+4)
+	order.PutUint16(entries, uint16(len(fields)))
+	var values []byte
+	valuesOffset := offset + 2 + 12*len(fields) + 4
+	for _, field := range fields {
+		entry := make([]byte, 12)
+		order.PutUint16(entry[0:], field.tag)
+		order.PutUint16(entry[2:], field.kind)
+		order.PutUint32(entry[4:], field.count)
+		if len(field.value) <= 4 {
+			copy(entry[8:], field.value)
+		} else {
+			order.PutUint32(entry[8:], uint32(valuesOffset+len(values)))
+			values = append(values, field.value...)
+			// values start at even offsets
+			if len(values)%2 == 1 {
+				values = append(values, 0)
+			}
+		}
+		entries = append(entries, entry...)
+	}
+	// there is no next directory
+	entries = append(entries, 0, 0, 0, 0)
+	return append(entries, values...)
+}
+
+// asciiField returns a text field, ended by a NUL
+func asciiField(tag uint16, text string) exifField {
+	value := append([]byte(text), 0)
+	return exifField{tag, exifASCII, uint32(len(value)), value}
+}
+
+// utf16Field returns a text field of the kind Windows shows in the
+// details of a file, UTF-16 little endian ended by a NUL
+func utf16Field(tag uint16, text string) exifField {
+	var value []byte
+	for _, unit := range append(utf16.Encode([]rune(text)), 0) {
+		value = binary.LittleEndian.AppendUint16(value, unit)
+	}
+	return exifField{tag, exifByte, uint32(len(value)), value}
+}
+
+// userCommentField returns a UserComment field, whose text is
+// preceded by its character code, UNICODE for UTF-16 in the byte
+// order of the file
+func userCommentField(text string, order binary.ByteOrder) exifField {
+	value := []byte("UNICODE\x00")
+	for _, unit := range utf16.Encode([]rune(text)) {
+		value = append(value, 0, 0)
+		order.PutUint16(value[len(value)-2:], unit)
+	}
+	return exifField{tagUserComment, exifUndefined, uint32(len(value)), value}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"image"
+	"os"
+	"strings"
+)
+
+// values of OnExisting, what is done with a new image whose
+// target file already exists
+const (
+	existingSkip                 = "skip"
+	existingOverwrite            = "overwrite"
+	existingOverwriteIfDifferent = "overwrite-if-different"
+	existingRename               = "rename"
+)
+
+// onExisting returns the OnExisting policy of the configuration
+func (c *config) onExisting() string {
+	policy := strings.ToLower(strings.TrimSpace(c.OnExisting))
+	if policy == "" {
+		return existingSkip
+	}
+	return policy
+}
+
+// differsFromSaved tells whether a saved file is no longer what was
+// saved, comparing it with the checksum in the index, or with the
+// checksum of the source image when it isn't indexed
+func differsFromSaved(library *libraryIndex, savedPath string, checksum []byte) bool {
+	expected := hex.EncodeToString(checksum)
+	if entry := library.get(savedPath); entry != nil && entry.Checksum != "" {
+		expected = entry.Checksum
+	}
+	current, err := fileChecksum(savedPath)
+	return err != nil || hex.EncodeToString(current) != expected
+}
+
+// replaceExisting saves a source image over the existing file it
+// collides with when OnExisting asks for it, and returns whether it
+// did, or would in a dry run
+//
+// With overwrite-if-different the file is only replaced when it
+// changed since it was saved, like a truncated or re-encoded copy.
+// The image goes through the same people, text overlay and score
+// checks as a new one first
+func (j *job) replaceExisting(ctx *runContext, imagePath string, sourceName string, existingPath string, img image.Image, checksum []byte) bool {
+	policy := j.config.onExisting()
+	if policy != existingOverwrite && policy != existingOverwriteIfDifferent {
+		return false
+	}
+	if _, err := os.Stat(existingPath); err != nil {
+		return false
+	}
+	if policy == existingOverwriteIfDifferent && !differsFromSaved(ctx.library, existingPath, checksum) {
+		return false
+	}
+	if img == nil {
+		// saved copies found through seen.json aren't decoded before
+		img, _ = decodeImage(imagePath)
+	}
+	score, textOverlay, skipped := j.skipsContent(ctx, sourceName, imagePath, img, checksum)
+	if skipped {
+		return true
+	}
+	if ctx.dryRun {
+		j.stats.Copied++
+		j.logger.Printf("would replace %s with %s\n", existingPath, imagePath)
+		if !runOptions.json {
+			fmt.Println(tr("would replace %s with %s", existingPath, imagePath))
+		}
+		return true
+	}
+	j.logger.Printf("replacing file %s\n", existingPath)
+	if err := saveWallpaper(j.config, imagePath, existingPath); err != nil {
+		j.logger.Errorln(err)
+		ctx.failed.add(j.stateKey(), imagePath, err)
+		return true
+	}
+	j.countSpaceSaved(imagePath, existingPath)
+	j.stats.Copied++
+	entry := j.indexEntry(existingPath, sourceName, img)
+	entry.Score = score
+	entry.TextOverlay = textOverlay
+	if old := ctx.library.get(existingPath); old != nil {
+		entry.Title, entry.Description, entry.Copyright = old.Title, old.Description, old.Copyright
+	}
+	j.finishSaved(imagePath, entry, checksum)
+	j.writeSidecar(ctx, entry, imagePath)
+	ctx.library.put(entry)
+	ctx.seen.add(j.stateKey(), checksum, seenSaved, entry.Path)
+	j.stats.Files = append(j.stats.Files, copiedFile{j.name, imagePath, entry.Path, entry.Width, entry.Height})
+	return true
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// exit codes of the program, so schedulers and scripts can
+// tell what went wrong
+const (
+	exitOK            = 0
+	exitFailure       = 1 // any other error
+	exitConfig        = 2 // the configuration can't be read or is wrong
+	exitSourceMissing = 3 // no Spotlight folder was found
+	exitOutputMissing = 4 // the output folder doesn't exist
+	exitPartial       = 5 // some files couldn't be copied
+)
+
+// codedError is an error that ends the program with a given exit code
+type codedError struct {
+	code int
+	err  error
+}
+
+func (e *codedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *codedError) Unwrap() error {
+	return e.err
+}
+
+// withExitCode returns err marked to end the program with code
+func withExitCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &codedError{code, err}
+}
+
+// exitCode returns the exit code for an error
+func exitCode(err error) int {
+	if err == nil {
+		return exitOK
+	}
+	var coded *codedError
+	if errors.As(err, &coded) {
+		return coded.code
+	}
+	return exitFailure
+}
+
+// fatalConfig logs an error in the configuration and
+// ends the program with exitConfig
+func fatalConfig(err error) {
+	logError(err)
+	os.Exit(exitConfig)
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// exportLibrary copies wallpapers from the library to a folder,
+// or to a zip archive when the destination ends in .zip
+//
+// With --since and --until only the wallpapers saved between those
+// dates, both included, are copied. With --top N only the N wallpapers
+// with the highest score are copied
+func exportLibrary(args []string) {
+	flags := newFlagSet("export")
+	top := flags.Int("top", 0, "copy only the wallpapers with the highest score")
+	since := flags.String("since", "", "copy only the wallpapers saved from this date, YYYY-MM-DD")
+	until := flags.String("until", "", "copy only the wallpapers saved until this date, YYYY-MM-DD")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		fmt.Println(tr("Usage: wspotsave export [--top N] [--since date] [--until date] <folder or file.zip>"))
+		os.Exit(1)
+	}
+	from, err := parseDate(*since)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	to, err := parseDate(*until)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if !to.IsZero() {
+		to = to.AddDate(0, 0, 1)
+	}
+
+	library, err := loadIndex()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	entries := library.sorted(func(a, b *indexEntry) bool {
+		return a.Score > b.Score
+	})
+	var selected []*indexEntry
+	for _, entry := range entries {
+		if entry.Saved.Before(from) || (!to.IsZero() && !entry.Saved.Before(to)) {
+			continue
+		}
+		selected = append(selected, entry)
+	}
+	if *top > 0 && len(selected) > *top {
+		selected = selected[:*top]
+	}
+
+	target := normalizePath(flags.Arg(0))
+	var exported int
+	if strings.EqualFold(filepath.Ext(target), ".zip") {
+		exported, err = exportZip(selected, target)
+	} else {
+		exported, err = exportFolder(selected, target)
+	}
+	if err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Println(tr("exported %d wallpapers to %s", exported, target))
+}
+
+// parseDate reads a date written as YYYY-MM-DD in local time,
+// an empty date is the zero time
+func parseDate(date string) (time.Time, error) {
+	if date == "" {
+		return time.Time{}, nil
+	}
+	parsed, err := time.ParseInLocation(time.DateOnly, date, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s is not a date like 2024-01-31", date)
+	}
+	return parsed, nil
+}
+
+// exportFolder copies the wallpapers of the entries to a folder
+func exportFolder(entries []*indexEntry, targetDir string) (int, error) {
+	if err := os.MkdirAll(targetDir, 0755); err != nil {
+		return 0, err
+	}
+	exported := 0
+	for _, entry := range entries {
+		targetPath := filepath.Join(targetDir, filepath.Base(entry.Path))
+		if err := copyFile(entry.Path, targetPath); err != nil {
+			fmt.Println(err)
+			continue
+		}
+		exported++
+	}
+	return exported, nil
+}
+
+// exportZip writes the wallpapers of the entries to a zip archive
+func exportZip(entries []*indexEntry, targetPath string) (int, error) {
+	archiveFile, err := os.Create(targetPath)
+	if err != nil {
+		return 0, fmt.Errorf("couldn't create file %s", targetPath)
+	}
+	defer archiveFile.Close()
+	archive := zip.NewWriter(archiveFile)
+	exported := 0
+	for _, entry := range entries {
+		if err := addToZip(archive, entry); err != nil {
+			fmt.Println(err)
+			continue
+		}
+		exported++
+	}
+	if err := archive.Close(); err != nil {
+		return 0, fmt.Errorf("couldn't write %s", targetPath)
+	}
+	return exported, nil
+}
+
+// addToZip adds the wallpaper of an entry to a zip archive
+//
+// JPEG images are already compressed, so they are stored as they are
+func addToZip(archive *zip.Writer, entry *indexEntry) error {
+	sourceFile, err := os.Open(entry.Path)
+	if err != nil {
+		return fmt.Errorf("couldn't open %s", entry.Path)
+	}
+	defer sourceFile.Close()
+	header := &zip.FileHeader{
+		Name:     filepath.Base(entry.Path),
+		Method:   zip.Store,
+		Modified: entry.Saved,
+	}
+	writer, err := archive.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(writer, sourceFile)
+	return err
+}
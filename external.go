@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// splitCommand splits a command line from the configuration into
+// the program and its arguments, keeping text in double quotes together
+func splitCommand(commandLine string) []string {
+	var fields []string
+	var field strings.Builder
+	inQuotes := false
+	hasField := false
+	for _, r := range commandLine {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+			hasField = true
+		case (r == ' ' || r == '\t') && !inQuotes:
+			if hasField {
+				fields = append(fields, field.String())
+				field.Reset()
+				hasField = false
+			}
+		default:
+			field.WriteRune(r)
+			hasField = true
+		}
+	}
+	if hasField {
+		fields = append(fields, field.String())
+	}
+	return fields
+}
+
+// runExternal runs a command line from the configuration with
+// extra arguments and returns what it printed
+func runExternal(commandLine string, args ...string) (string, error) {
+	return runExternalInput(commandLine, "", args...)
+}
+
+// runExternalInput runs a command line from the configuration like
+// runExternal, writing input to the standard input of the command
+func runExternalInput(commandLine string, input string, args ...string) (string, error) {
+	fields := splitCommand(commandLine)
+	if len(fields) == 0 {
+		return "", fmt.Errorf("command is empty")
+	}
+	fields = append(fields, args...)
+	command := exec.Command(fields[0], fields[1:]...)
+	if input != "" {
+		command.Stdin = strings.NewReader(input)
+	}
+	output, err := command.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return "", fmt.Errorf("couldn't run %s: %s %s", fields[0], err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("couldn't run %s: %s", fields[0], err)
+	}
+	return string(output), nil
+}
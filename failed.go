@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// failedFile is a source file that couldn't be copied
+type failedFile struct {
+	Error string
+	Time  time.Time
+}
+
+// failedFiles keeps the source files each job couldn't copy in the
+// last run, by path, so they can be retried without a full scan
+//
+// It is safe to use by several jobs at the same time
+type failedFiles struct {
+	mu   sync.Mutex
+	Jobs map[string]map[string]*failedFile
+}
+
+// failedPath returns the path of the file of failed copies
+func failedPath() string {
+	return filepath.Join(appDir(), "failed.json")
+}
+
+// newFailedFiles returns an empty set of failed copies
+func newFailedFiles() *failedFiles {
+	return &failedFiles{Jobs: make(map[string]map[string]*failedFile)}
+}
+
+// loadFailed reads the failed copies, which are none if the file doesn't exist
+func loadFailed() (*failedFiles, error) {
+	failed := newFailedFiles()
+	data, err := os.ReadFile(failedPath())
+	if os.IsNotExist(err) {
+		return failed, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, failed); err != nil {
+		return nil, err
+	}
+	if failed.Jobs == nil {
+		failed.Jobs = make(map[string]map[string]*failedFile)
+	}
+	return failed, nil
+}
+
+// save writes the file of failed copies
+func (f *failedFiles) save() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(failedPath(), data, 0644)
+}
+
+// add records that a job couldn't copy a source file
+func (f *failedFiles) add(jobName string, sourcePath string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Jobs[jobName] == nil {
+		f.Jobs[jobName] = make(map[string]*failedFile)
+	}
+	f.Jobs[jobName][sourcePath] = &failedFile{Error: err.Error(), Time: time.Now()}
+}
+
+// has tells whether a job couldn't copy a source file
+func (f *failedFiles) has(jobName string, sourcePath string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.Jobs[jobName][sourcePath] != nil
+}
+
+// count returns how many failed copies there are
+func (f *failedFiles) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	count := 0
+	for _, files := range f.Jobs {
+		count += len(files)
+	}
+	return count
+}
+
+// replace replaces the failed copies of the jobs that ran with
+// the ones of the last run
+func (f *failedFiles) replace(jobs []*job, last *failedFiles) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, j := range jobs {
+		delete(f.Jobs, j.stateKey())
+		if files := last.Jobs[j.stateKey()]; len(files) > 0 {
+			f.Jobs[j.stateKey()] = files
+		}
+	}
+}
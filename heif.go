@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+// maxHeifHeader is how much of a HEIC or AVIF file is read to find
+// its size, the metadata comes before the image data
+const maxHeifHeader = 4 << 20
+
+// heifFormat returns heic or avif for the major brand of the ftyp box
+// of an ISO media file, or an empty string for other files
+func heifFormat(brand string) string {
+	switch brand {
+	case "avif", "avis":
+		return "avif"
+	case "heic", "heix", "heim", "heis", "hevc", "hevx", "mif1", "msf1":
+		return "heic"
+	}
+	return ""
+}
+
+// heifSize returns the width and the height of a HEIC or AVIF image,
+// from the largest image spatial extents property of its metadata,
+// swapped when the image is rotated a quarter turn
+//
+// Go can't decode these formats, so only their boxes are read
+func heifSize(imagePath string) (int, int, error) {
+	file, err := os.Open(imagePath)
+	if err != nil {
+		return 0, 0, fmt.Errorf("couldn't open %s", imagePath)
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxHeifHeader))
+	if err != nil {
+		return 0, 0, err
+	}
+	var width, height int
+	quarterTurn := false
+	var walk func(data []byte)
+	walk = func(data []byte) {
+		for len(data) >= 8 {
+			size, headerSize := int(binary.BigEndian.Uint32(data)), 8
+			switch size {
+			case 0:
+				size = len(data)
+			case 1:
+				if len(data) < 16 {
+					return
+				}
+				size, headerSize = int(binary.BigEndian.Uint64(data[8:])), 16
+			}
+			if size < headerSize || size > len(data) {
+				return
+			}
+			body := data[headerSize:size]
+			switch string(data[4:8]) {
+			case "meta":
+				// a full box, with a version and flags first
+				if len(body) >= 4 {
+					walk(body[4:])
+				}
+			case "iprp", "ipco":
+				walk(body)
+			case "ispe":
+				if len(body) >= 12 {
+					w, h := int(binary.BigEndian.Uint32(body[4:])), int(binary.BigEndian.Uint32(body[8:]))
+					if w*h > width*height {
+						width, height = w, h
+					}
+				}
+			case "irot":
+				if len(body) >= 1 && body[0]&1 == 1 {
+					quarterTurn = true
+				}
+			}
+			data = data[size:]
+		}
+	}
+	walk(data)
+	if width == 0 || height == 0 {
+		return 0, 0, fmt.Errorf("couldn't find the size of %s", imagePath)
+	}
+	if quarterTurn {
+		width, height = height, width
+	}
+	return width, height, nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"image"
+	_ "image/jpeg"
+	"math/bits"
+	"os"
+)
+
+// hashWidth and hashHeight are the size of the grid the image is
+// reduced to for the difference hash, one bit per adjacent pair
+const (
+	hashWidth  = 9
+	hashHeight = 8
+)
+
+// perceptualHash returns the difference hash of an image
+//
+// The image is reduced to a 9x8 grid of average brightness and each
+// bit tells whether a cell is brighter than the one on its right, so
+// re-encoded or slightly cropped copies get hashes that differ in
+// only a few bits
+func perceptualHash(img image.Image) uint64 {
+	grid := brightnessGrid(img, hashWidth, hashHeight)
+	var hash uint64
+	for y := 0; y < hashHeight; y++ {
+		for x := 0; x < hashWidth-1; x++ {
+			hash <<= 1
+			if grid[y][x] > grid[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// brightnessGrid returns the average brightness of
+// each cell of the image divided in columns and rows
+func brightnessGrid(img image.Image, columns int, rows int) [][]float64 {
+	bounds := img.Bounds()
+	sums := make([][]float64, rows)
+	counts := make([][]int, rows)
+	for y := range sums {
+		sums[y] = make([]float64, columns)
+		counts[y] = make([]int, columns)
+	}
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		row := (y - bounds.Min.Y) * rows / bounds.Dy()
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			column := (x - bounds.Min.X) * columns / bounds.Dx()
+			sums[row][column] += brightness(img, x, y)
+			counts[row][column]++
+		}
+	}
+	for y := range sums {
+		for x := range sums[y] {
+			if counts[y][x] > 0 {
+				sums[y][x] /= float64(counts[y][x])
+			}
+		}
+	}
+	return sums
+}
+
+// brightness returns the luma of a pixel from 0 to 255
+//
+// JPEG images are read from their luma plane directly
+// because it is much faster than converting every color
+func brightness(img image.Image, x int, y int) float64 {
+	if ycbcr, ok := img.(*image.YCbCr); ok {
+		return float64(ycbcr.Y[ycbcr.YOffset(x, y)])
+	}
+	r, g, b, _ := img.At(x, y).RGBA()
+	return (0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)) / 257
+}
+
+// hammingDistance returns the number of bits that differ between two hashes
+func hammingDistance(a uint64, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// savedDuplicate returns the entry of a saved wallpaper that looks like
+// an image within DuplicateDistance, or nil if there isn't any, the
+// image couldn't be decoded or duplicates aren't looked for
+func (j *job) savedDuplicate(library *libraryIndex, img image.Image) *indexEntry {
+	if j.config.DuplicateDistance < 0 || img == nil {
+		return nil
+	}
+	duplicate := library.similarTo(perceptualHash(img), j.config.DuplicateDistance)
+	if duplicate == nil {
+		return nil
+	}
+	if _, err := os.Stat(duplicate.Path); err != nil {
+		return nil
+	}
+	return duplicate
+}
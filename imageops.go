@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+// parseSize reads a size written as WIDTHxHEIGHT, e.g. 3840x1080
+func parseSize(size string) (int, int, error) {
+	widthText, heightText, ok := strings.Cut(strings.ToLower(strings.TrimSpace(size)), "x")
+	if !ok {
+		return 0, 0, fmt.Errorf("%s is not a size like 3840x1080", size)
+	}
+	width, err := strconv.Atoi(widthText)
+	if err != nil || width <= 0 {
+		return 0, 0, fmt.Errorf("%s is not a size like 3840x1080", size)
+	}
+	height, err := strconv.Atoi(heightText)
+	if err != nil || height <= 0 {
+		return 0, 0, fmt.Errorf("%s is not a size like 3840x1080", size)
+	}
+	return width, height, nil
+}
+
+// coverRect returns the largest rectangle of the aspect ratio of
+// width and height that fits in bounds, centered
+func coverRect(bounds image.Rectangle, width int, height int) image.Rectangle {
+	cropWidth, cropHeight := bounds.Dx(), bounds.Dx()*height/width
+	if cropHeight > bounds.Dy() {
+		cropWidth, cropHeight = bounds.Dy()*width/height, bounds.Dy()
+	}
+	x := bounds.Min.X + (bounds.Dx()-cropWidth)/2
+	y := bounds.Min.Y + (bounds.Dy()-cropHeight)/2
+	return image.Rect(x, y, x+cropWidth, y+cropHeight)
+}
+
+// coverResize returns the image scaled to exactly width and height,
+// cropping the center to keep the aspect ratio like a fill wallpaper
+func coverResize(img image.Image, width int, height int) image.Image {
+	return resizeRect(img, coverRect(img.Bounds(), width, height), width, height)
+}
+
+// resizeRect returns a part of an image scaled to width and height
+func resizeRect(img image.Image, rect image.Rectangle, width int, height int) *image.RGBA {
+	target := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(target, target.Bounds(), img, rect, draw.Src, nil)
+	return target
+}
+
+// outputSize returns the size OutputSize asks an image of the given
+// size to be resized to, and whether it has to be resized
+//
+// Ultrawide panoramas and images of the other orientation are left
+// as they are, since cropping them would lose most of the image
+func (c *config) outputSize(width int, height int) (int, int, bool) {
+	if c.OutputSize == "" {
+		return 0, 0, false
+	}
+	targetWidth, targetHeight, err := parseSize(c.OutputSize)
+	if err != nil || (width == targetWidth && height == targetHeight) {
+		return 0, 0, false
+	}
+	if c.isUltrawide(width, height) || orientation(width, height) != orientation(targetWidth, targetHeight) {
+		return 0, 0, false
+	}
+	return targetWidth, targetHeight, true
+}
+
+// resizesToOutputSize tells whether the image in the given path is
+// resized to OutputSize when saved
+func (c *config) resizesToOutputSize(imagePath string) bool {
+	width, height, err := imageSize(imagePath)
+	if err != nil {
+		return false
+	}
+	_, _, ok := c.outputSize(width, height)
+	return ok
+}
+
+// fitOutputSize returns an upright image scaled and cropped to
+// OutputSize, or as it is when it isn't resized
+func (c *config) fitOutputSize(img image.Image) image.Image {
+	width, height, ok := c.outputSize(img.Bounds().Dx(), img.Bounds().Dy())
+	if !ok {
+		return img
+	}
+	return coverResize(img, width, height)
+}
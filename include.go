@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// includeKey is the key of the default section with the configuration
+// files that a file builds on, separated by ;
+const includeKey = "Include"
+
+// loadConfigWithIncludes reads a configuration file in any format
+// together with the files it includes
+func loadConfigWithIncludes(path string) (*ini.File, error) {
+	file, err := loadConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return includeConfigs(file, path, nil)
+}
+
+// includeConfigs returns the configuration of the files that a file
+// includes, in order, with the keys of the file overriding theirs
+//
+// Relative paths are relative to the folder of the file, so a shared
+// base and a machine configuration can sit side by side. including
+// are the files being read, to refuse cycles
+func includeConfigs(file *ini.File, path string, including []string) (*ini.File, error) {
+	migrateConfig(file)
+	include := file.Section("").Key(includeKey).String()
+	if include == "" {
+		return file, nil
+	}
+	file.Section("").DeleteKey(includeKey)
+	including = append(including, filepath.Clean(path))
+	iniConfig := ini.Empty()
+	for _, includePath := range strings.Split(include, ";") {
+		includePath = filepath.FromSlash(expandVariables(strings.TrimSpace(includePath)))
+		if includePath == "" {
+			continue
+		}
+		if !filepath.IsAbs(includePath) {
+			includePath = filepath.Join(filepath.Dir(path), includePath)
+		}
+		if slices.Contains(including, filepath.Clean(includePath)) {
+			return nil, fmt.Errorf("%s is included by itself", includePath)
+		}
+		included, err := loadConfigFile(includePath)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't include %s: %s", includePath, err)
+		}
+		included, err = includeConfigs(included, includePath, including)
+		if err != nil {
+			return nil, err
+		}
+		mergeConfig(iniConfig, included)
+	}
+	mergeConfig(iniConfig, file)
+	return iniConfig, nil
+}
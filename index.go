@@ -0,0 +1,231 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"image"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// indexEntry is what is known about a saved wallpaper
+type indexEntry struct {
+	Path        string
+	Source      string
+	Width       int
+	Height      int
+	Saved       time.Time
+	Score       float64  `json:",omitempty"`
+	Tags        []string `json:",omitempty"`
+	Category    string   `json:",omitempty"`
+	Hash        uint64   `json:",omitempty"`
+	Stack       int      `json:",omitempty"`
+	Pair        string   `json:",omitempty"`
+	Palette     []string `json:",omitempty"`
+	Theme       string   `json:",omitempty"`
+	TextOverlay bool     `json:",omitempty"`
+	Title       string   `json:",omitempty"`
+	Description string   `json:",omitempty"`
+	Copyright   string   `json:",omitempty"`
+	Checksum    string   `json:",omitempty"`
+	// the checksum of the source image, which differs from Checksum
+	// when the wallpaper was converted, resized or had metadata added
+	SourceChecksum string `json:",omitempty"`
+}
+
+// libraryIndex keeps the entries of the saved wallpapers by path
+//
+// It is safe to use by several jobs at the same time
+type libraryIndex struct {
+	mu      sync.Mutex
+	Entries map[string]*indexEntry
+	// the entries by the checksum of their source image
+	sources map[string][]*indexEntry
+}
+
+// indexPath returns the path of the index file
+func indexPath() string {
+	return filepath.Join(appDir(), "index.json")
+}
+
+// loadIndex reads the index, which is empty if the file doesn't exist
+func loadIndex() (*libraryIndex, error) {
+	library := &libraryIndex{Entries: make(map[string]*indexEntry)}
+	data, err := os.ReadFile(indexPath())
+	if os.IsNotExist(err) {
+		return library, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, library); err != nil {
+		return nil, err
+	}
+	if library.Entries == nil {
+		library.Entries = make(map[string]*indexEntry)
+	}
+	for _, entry := range library.Entries {
+		library.linkSource(entry)
+	}
+	return library, nil
+}
+
+// save writes the index file
+func (l *libraryIndex) save() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(indexPath(), data, 0644)
+}
+
+// get returns the entry of a saved wallpaper, or nil if it isn't indexed
+func (l *libraryIndex) get(filePath string) *indexEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.Entries[filePath]
+}
+
+// put adds or replaces the entry of a saved wallpaper
+func (l *libraryIndex) put(entry *indexEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if old, ok := l.Entries[entry.Path]; ok {
+		l.unlinkSource(old)
+	}
+	l.Entries[entry.Path] = entry
+	l.linkSource(entry)
+}
+
+// remove removes the entry of a wallpaper
+func (l *libraryIndex) remove(filePath string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if entry, ok := l.Entries[filePath]; ok {
+		l.unlinkSource(entry)
+	}
+	delete(l.Entries, filePath)
+}
+
+// linkSource adds an entry to the entries of its source checksum
+func (l *libraryIndex) linkSource(entry *indexEntry) {
+	if entry.SourceChecksum == "" {
+		return
+	}
+	if l.sources == nil {
+		l.sources = make(map[string][]*indexEntry)
+	}
+	l.sources[entry.SourceChecksum] = append(l.sources[entry.SourceChecksum], entry)
+}
+
+// unlinkSource removes an entry from the entries of its source checksum
+func (l *libraryIndex) unlinkSource(entry *indexEntry) {
+	entries := slices.DeleteFunc(l.sources[entry.SourceChecksum], func(e *indexEntry) bool { return e == entry })
+	if len(entries) == 0 {
+		delete(l.sources, entry.SourceChecksum)
+	} else {
+		l.sources[entry.SourceChecksum] = entries
+	}
+}
+
+// move changes the path of the entry of a wallpaper that was moved
+func (l *libraryIndex) move(oldPath string, newPath string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if entry, ok := l.Entries[oldPath]; ok {
+		delete(l.Entries, oldPath)
+		if replaced, ok := l.Entries[newPath]; ok && replaced != entry {
+			l.unlinkSource(replaced)
+		}
+		entry.Path = newPath
+		l.Entries[newPath] = entry
+	}
+}
+
+// bySource returns the entry of the wallpaper saved from a source
+// file name, or nil if there isn't any
+func (l *libraryIndex) bySource(source string) *indexEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, entry := range l.Entries {
+		if entry.Source == source {
+			return entry
+		}
+	}
+	return nil
+}
+
+// savedFrom returns the entry of a wallpaper in one of the given
+// folders saved from the source image with the given SHA-256
+// checksum, or nil if there isn't any
+func (l *libraryIndex) savedFrom(sourceChecksum string, dirs []string) *indexEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, entry := range l.sources[sourceChecksum] {
+		if slices.ContainsFunc(dirs, func(dir string) bool { return isInsideDir(entry.Path, dir) }) {
+			return entry
+		}
+	}
+	return nil
+}
+
+// similarTo returns the entry of a wallpaper whose perceptual hash
+// differs from hash in at most maxDistance bits, the closest one,
+// or nil if there isn't any
+func (l *libraryIndex) similarTo(hash uint64, maxDistance int) *indexEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var closest *indexEntry
+	closestDistance := maxDistance + 1
+	for _, entry := range l.Entries {
+		if entry.Hash == 0 {
+			continue
+		}
+		if distance := hammingDistance(hash, entry.Hash); distance < closestDistance {
+			closest, closestDistance = entry, distance
+		}
+	}
+	return closest
+}
+
+// sorted returns the entries ordered with the given less function
+func (l *libraryIndex) sorted(less func(a, b *indexEntry) bool) []*indexEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entries := make([]*indexEntry, 0, len(l.Entries))
+	for _, entry := range l.Entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, k int) bool {
+		return less(entries[i], entries[k])
+	})
+	return entries
+}
+
+// newIndexEntry returns the entry of an image file, with the hash,
+// palette and theme of img, the image decoded from its source, or
+// of the file itself when img is nil
+func newIndexEntry(filePath string, source string, img image.Image) *indexEntry {
+	entry := &indexEntry{Path: filePath, Source: source, Saved: time.Now()}
+	if width, height, err := imageSize(filePath); err == nil {
+		entry.Width = width
+		entry.Height = height
+	}
+	if checksum, err := fileChecksum(filePath); err == nil {
+		entry.Checksum = hex.EncodeToString(checksum)
+	}
+	if img == nil {
+		img, _ = decodeImage(filePath)
+	}
+	if img != nil {
+		entry.Hash = perceptualHash(img)
+		entry.Palette = hexColors(imagePalette(img, paletteSize))
+		entry.Theme = imageTheme(img)
+	}
+	return entry
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+)
+
+// photoshopHeader starts the APP13 segment of the Photoshop image
+// resources, where IPTC metadata is kept in JPEG images
+const photoshopHeader = "Photoshop 3.0\x00"
+
+// iptcResource is the Photoshop image resource with the IPTC records
+const iptcResource = 0x0404
+
+// the IPTC datasets that are written, as record and number
+const (
+	iptcCharacterSet    = 0x015A
+	iptcRecordVersion   = 0x0200
+	iptcKeywords        = 0x0219
+	iptcCaptionAbstract = 0x0278
+)
+
+// the longest IPTC Caption-Abstract and Keywords, in bytes
+const (
+	iptcCaptionLength = 2000
+	iptcKeywordLength = 64
+)
+
+// textData is what the templates of the texts written into saved
+// wallpapers know, like IPTCCaption or WatermarkText
+type textData struct {
+	Title       string
+	Description string
+	Copyright   string
+	Source      string
+	Tags        []string
+	Category    string
+	Colors      []string
+	Tone        string
+	Date        time.Time
+}
+
+// newTextData returns what the templates know about a wallpaper
+func newTextData(entry *indexEntry) textData {
+	return textData{
+		Title:       entry.Title,
+		Description: entry.Description,
+		Copyright:   entry.Copyright,
+		Source:      entry.Source,
+		Tags:        entry.Tags,
+		Category:    entry.Category,
+		Colors:      colorNames(entry.Palette),
+		Tone:        paletteTone(entry.Palette),
+		Date:        entry.Saved,
+	}
+}
+
+// parseTextTemplate parses a template of the configuration, like
+// IPTCCaption, with the naming functions, returning nil when it is empty
+func (c *config) parseTextTemplate(key string, text string) (*template.Template, error) {
+	if text == "" {
+		return nil, nil
+	}
+	parsed, err := template.New(key).Funcs(c.templateFunctions()).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read %s: %s", key, err)
+	}
+	return parsed, nil
+}
+
+// embedIPTC writes the caption and keywords of IPTCCaption and
+// IPTCKeywords into a saved JPEG wallpaper, and returns whether
+// they were written
+func (j *job) embedIPTC(entry *indexEntry) bool {
+	if (j.config.IPTCCaption == "" && j.config.IPTCKeywords == "") || j.config.StripMetadata || !isJPEG(entry.Path) {
+		return false
+	}
+	data := newTextData(entry)
+	caption, err := j.config.executeTextTemplate("IPTCCaption", j.config.IPTCCaption, data)
+	if err != nil {
+		j.logger.Errorln(err)
+		return false
+	}
+	keywords, err := j.config.executeTextTemplate("IPTCKeywords", j.config.IPTCKeywords, data)
+	if err != nil {
+		j.logger.Errorln(err)
+		return false
+	}
+	if err := writeIPTC(entry.Path, caption, splitKeywords(keywords)); err != nil {
+		j.logger.Errorln(err)
+		return false
+	}
+	return caption != "" || len(splitKeywords(keywords)) > 0
+}
+
+// executeTextTemplate returns the text of a template of the
+// configuration for a wallpaper, empty when the template is
+func (c *config) executeTextTemplate(key string, text string, data textData) (string, error) {
+	parsed, err := c.parseTextTemplate(key, text)
+	if err != nil || parsed == nil {
+		return "", err
+	}
+	var output strings.Builder
+	if err := parsed.Execute(&output, data); err != nil {
+		return "", fmt.Errorf("couldn't write %s: %s", key, err)
+	}
+	return strings.TrimSpace(output.String()), nil
+}
+
+// splitKeywords returns the keywords separated by ; or new lines,
+// without empty or repeated ones
+func splitKeywords(text string) []string {
+	var keywords []string
+	for _, keyword := range strings.FieldsFunc(text, func(r rune) bool { return r == ';' || r == '\n' }) {
+		keyword = truncateBytes(strings.TrimSpace(keyword), iptcKeywordLength)
+		if keyword != "" && !slices.Contains(keywords, keyword) {
+			keywords = append(keywords, keyword)
+		}
+	}
+	return keywords
+}
+
+// truncateBytes cuts a text to at most length bytes
+// without splitting a character
+func truncateBytes(text string, length int) string {
+	if len(text) <= length {
+		return text
+	}
+	text = text[:length]
+	for !utf8.ValidString(text) {
+		text = text[:len(text)-1]
+	}
+	return text
+}
+
+// writeIPTC adds a Photoshop segment with the IPTC caption and
+// keywords to a JPEG file, or merges them into the one it has,
+// replacing the caption and keywords already there
+func writeIPTC(imagePath string, caption string, keywords []string) error {
+	if caption == "" && len(keywords) == 0 {
+		return nil
+	}
+	data, err := os.ReadFile(imagePath)
+	if err != nil {
+		return fmt.Errorf("couldn't open %s", imagePath)
+	}
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return fmt.Errorf("couldn't add the IPTC of %s: %s", imagePath, errMalformedImage)
+	}
+	// the segment goes after the other application segments
+	offset := 2
+	for offset+4 <= len(data) && data[offset] == 0xFF && data[offset+1] >= 0xE0 && data[offset+1] <= 0xEF {
+		end := offset + 2 + int(binary.BigEndian.Uint16(data[offset+2:]))
+		if data[offset+1] == 0xED && end <= len(data) && bytes.HasPrefix(data[offset+4:end], []byte(photoshopHeader)) {
+			segment, err := mergeIPTCSegment(data[offset+4+len(photoshopHeader):end], caption, keywords)
+			if err != nil {
+				return fmt.Errorf("couldn't add the IPTC of %s: %s", imagePath, err)
+			}
+			merged := append(append(append([]byte{}, data[:offset]...), segment...), data[end:]...)
+			if err := os.WriteFile(imagePath, merged, 0644); err != nil {
+				return fmt.Errorf("couldn't write %s", imagePath)
+			}
+			return nil
+		}
+		offset = end
+	}
+	if offset > len(data) {
+		return fmt.Errorf("couldn't add the IPTC of %s: %s", imagePath, errMalformedImage)
+	}
+	segment, err := iptcSegment(caption, keywords)
+	if err != nil {
+		return fmt.Errorf("couldn't add the IPTC of %s: %s", imagePath, err)
+	}
+	embedded := append(append(append([]byte{}, data[:offset]...), segment...), data[offset:]...)
+	if err := os.WriteFile(imagePath, embedded, 0644); err != nil {
+		return fmt.Errorf("couldn't write %s", imagePath)
+	}
+	return nil
+}
+
+// iptcSegment returns an APP13 segment with a Photoshop resource of
+// IPTC records in UTF-8 with the caption and keywords
+func iptcSegment(caption string, keywords []string) ([]byte, error) {
+	return photoshopSegment(photoshopResource(iptcResource, iptcRecords(caption, keywords, nil)))
+}
+
+// mergeIPTCSegment returns an APP13 segment with the Photoshop
+// resources of an existing one and the caption and keywords, keeping
+// the IPTC records other than the caption, keywords and the ones
+// describing the records
+func mergeIPTCSegment(resources []byte, caption string, keywords []string) ([]byte, error) {
+	var others, kept []byte
+	for len(resources) > 0 {
+		if len(resources) < 7 || string(resources[:4]) != "8BIM" {
+			return nil, errMalformedImage
+		}
+		id := binary.BigEndian.Uint16(resources[4:])
+		// the name is a Pascal string padded to an even size
+		nameSize := int(resources[6]) + 1
+		nameSize += nameSize % 2
+		if 6+nameSize+4 > len(resources) {
+			return nil, errMalformedImage
+		}
+		dataStart := 6 + nameSize + 4
+		dataSize := int(binary.BigEndian.Uint32(resources[6+nameSize:]))
+		if dataStart+dataSize > len(resources) {
+			return nil, errMalformedImage
+		}
+		end := min(dataStart+dataSize+dataSize%2, len(resources))
+		if id == iptcResource {
+			var err error
+			if kept, err = keptIPTCDatasets(resources[dataStart : dataStart+dataSize]); err != nil {
+				return nil, err
+			}
+		} else {
+			others = append(others, resources[:end]...)
+		}
+		resources = resources[end:]
+	}
+	return photoshopSegment(append(others, photoshopResource(iptcResource, iptcRecords(caption, keywords, kept))...))
+}
+
+// keptIPTCDatasets returns the IPTC datasets of existing records that
+// aren't replaced when the caption and keywords are merged into them
+func keptIPTCDatasets(records []byte) ([]byte, error) {
+	var kept []byte
+	// the records may be followed by padding
+	for len(records) > 0 && records[0] == 0x1C {
+		if len(records) < 5 {
+			return nil, errMalformedImage
+		}
+		dataset := binary.BigEndian.Uint16(records[1:])
+		size := int(binary.BigEndian.Uint16(records[3:]))
+		if size&0x8000 != 0 {
+			return nil, errors.New("extended IPTC datasets aren't supported")
+		}
+		if 5+size > len(records) {
+			return nil, errMalformedImage
+		}
+		switch dataset {
+		case iptcCharacterSet, iptcRecordVersion, iptcKeywords, iptcCaptionAbstract:
+		default:
+			kept = append(kept, records[:5+size]...)
+		}
+		records = records[5+size:]
+	}
+	return kept, nil
+}
+
+// iptcRecords returns the IPTC records in UTF-8 with the caption,
+// the keywords and other datasets to keep
+func iptcRecords(caption string, keywords []string, kept []byte) []byte {
+	var records []byte
+	records = appendIPTCDataset(records, iptcCharacterSet, []byte("\x1b%G"))
+	records = appendIPTCDataset(records, iptcRecordVersion, []byte{0, 4})
+	records = append(records, kept...)
+	for _, keyword := range keywords {
+		records = appendIPTCDataset(records, iptcKeywords, []byte(keyword))
+	}
+	if caption != "" {
+		records = appendIPTCDataset(records, iptcCaptionAbstract, []byte(truncateBytes(caption, iptcCaptionLength)))
+	}
+	return records
+}
+
+// photoshopResource returns a Photoshop image resource with an
+// empty name, padded to an even size, as is its data
+func photoshopResource(id uint16, data []byte) []byte {
+	resource := []byte("8BIM")
+	resource = binary.BigEndian.AppendUint16(resource, id)
+	resource = append(resource, 0, 0)
+	resource = binary.BigEndian.AppendUint32(resource, uint32(len(data)))
+	resource = append(resource, data...)
+	if len(data)%2 == 1 {
+		resource = append(resource, 0)
+	}
+	return resource
+}
+
+// photoshopSegment returns an APP13 segment with Photoshop resources
+func photoshopSegment(resources []byte) ([]byte, error) {
+	length := 2 + len(photoshopHeader) + len(resources)
+	if length > 0xFFFF {
+		return nil, errors.New("the caption and keywords are too long")
+	}
+	segment := []byte{0xFF, 0xED, byte(length >> 8), byte(length)}
+	segment = append(segment, photoshopHeader...)
+	return append(segment, resources...), nil
+}
+
+// appendIPTCDataset appends a dataset, its record and number
+// followed by the length of its value and the value
+func appendIPTCDataset(records []byte, dataset uint16, value []byte) []byte {
+	records = append(records, 0x1C, byte(dataset>>8), byte(dataset))
+	records = binary.BigEndian.AppendUint16(records, uint16(len(value)))
+	return append(records, value...)
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+// jobSectionPrefix is the prefix of the configuration sections
+// that define jobs, e.g. [job.phone]
+const jobSectionPrefix = "job."
+
+// job is a copy of wallpapers with its own folders and filters
+//
+// Every job logs with its name as prefix and counts its own stats
+type job struct {
+	name     string
+	config   *config
+	logger   *leveledLogger
+	stats    *runStats
+	metadata map[string]assetMetadata
+
+	nameTemplate *template.Template
+	excludes     []excludePattern
+}
+
+// newJob returns a job with the given name and configuration
+func newJob(name string, config *config) *job {
+	prefix := ""
+	if name != "" {
+		prefix = "[" + name + "] "
+	}
+	return &job{
+		name:   name,
+		config: config,
+		logger: newLeveledLogger(prefix, config.logLevel()),
+		stats:  new(runStats),
+	}
+}
+
+// loadJobs returns the jobs defined in the configuration
+//
+// Each [job.NAME] section overrides the keys of the default section.
+// If there are no job sections the default section is the only job.
+// selected is a comma separated list of job names to keep, all
+// the jobs are kept if it's empty
+func loadJobs(iniConfig *ini.File, base *config, selected string) ([]*job, error) {
+	var jobs []*job
+	for _, section := range iniConfig.Sections() {
+		name, ok := strings.CutPrefix(section.Name(), jobSectionPrefix)
+		if !ok {
+			continue
+		}
+		jobConfig := *base
+		if section.HasKey("OutputDir") {
+			jobConfig.extraOutputDirs = nil
+		}
+		if err := section.MapTo(&jobConfig); err != nil {
+			return nil, fmt.Errorf("couldn't read job %s: %s", name, err)
+		}
+		jobConfig.normalizePaths()
+		jobConfig.normalizeChoices()
+		jobConfig.resolveSecrets()
+		jobs = append(jobs, newJob(name, &jobConfig))
+	}
+	if len(jobs) == 0 {
+		jobs = append(jobs, newJob("", base))
+	}
+	if selected == "" {
+		return withOutputJobs(jobs), nil
+	}
+	var selectedJobs []*job
+	for _, name := range strings.Split(selected, ",") {
+		found := false
+		for _, j := range jobs {
+			if j.name == strings.TrimSpace(name) {
+				selectedJobs = append(selectedJobs, j)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("job %s is not defined", name)
+		}
+	}
+	return withOutputJobs(selectedJobs), nil
+}
+
+// withOutputJobs adds a job for every extra folder of the jobs whose
+// OutputDir lists several folders, named like phone#2, so a folder
+// that can't be reached doesn't keep the others from being saved to
+// and what was saved to each folder is tracked apart
+func withOutputJobs(jobs []*job) []*job {
+	var all []*job
+	for _, j := range jobs {
+		all = append(all, j)
+		for i, dir := range j.config.extraOutputDirs {
+			outputConfig := *j.config
+			outputConfig.OutputDir = dir
+			outputConfig.extraOutputDirs = nil
+			all = append(all, newJob(fmt.Sprintf("%s#%d", j.name, i+2), &outputConfig))
+		}
+	}
+	return all
+}
+
+// runContext holds what the jobs of a run share
+type runContext struct {
+	names       *sharedIndex
+	journal     *runJournal
+	library     *libraryIndex
+	seen        *seenAssets
+	failed      *failedFiles
+	retry       *failedFiles
+	since       time.Time
+	cleanSource bool
+	dryRun      bool
+}
+
+// knownDirs returns the folders where the job may have saved
+// wallpapers, so they aren't copied again
+func (j *job) knownDirs() []string {
+	dirs := []string{j.config.OutputDir}
+	if j.config.Staging {
+		dirs = append(dirs, j.config.pendingDir())
+	}
+	if checkDirectory(j.config.ultrawideDir()) == nil {
+		dirs = append(dirs, j.config.ultrawideDir())
+	}
+	if j.config.TextOverlays == "folder" && checkDirectory(j.config.promotionsDir()) == nil {
+		dirs = append(dirs, j.config.promotionsDir())
+	}
+	if j.config.PairDirs {
+		for _, dir := range []string{filepath.Join(j.config.OutputDir, landscapeCategory), filepath.Join(j.config.OutputDir, portraitCategory)} {
+			if checkDirectory(dir) == nil {
+				dirs = append(dirs, dir)
+			}
+		}
+	}
+	var organizedDirs []string
+	switch j.config.OrganizeBy {
+	case "category":
+		organizedDirs = j.config.categoryDirs()
+	case "color":
+		organizedDirs = j.config.colorDirs()
+	}
+	for _, dir := range organizedDirs {
+		if checkDirectory(dir) == nil {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// runJobs runs the jobs at the same time and returns an error when
+// any of them failed, with the exit code of the first failure
+func runJobs(jobs []*job, ctx *runContext) error {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var failed error
+	for _, j := range jobs {
+		wg.Add(1)
+		go func(j *job) {
+			defer wg.Done()
+			if err := j.run(ctx); err != nil {
+				j.logger.Errorln(err)
+				mu.Lock()
+				if failed == nil {
+					failed = withExitCode(exitCode(err), fmt.Errorf("some jobs failed"))
+				}
+				mu.Unlock()
+			}
+		}(j)
+	}
+	wg.Wait()
+	for _, j := range jobs {
+		j.stats.Failures = j.logger.loggedErrors()
+		j.stats.Errors = len(j.stats.Failures)
+	}
+	return failed
+}
+
+// run copies the wallpapers of the job
+func (j *job) run(ctx *runContext) error {
+	j.config.matchDisplay(j.logger)
+	sourceDirs, err := findSourceDirs(j.config.SourceDir, j.logger)
+	if err != nil {
+		return withExitCode(exitSourceMissing, err)
+	}
+	outputDir := j.config.OutputDir
+	if err := checkDirectory(outputDir); err != nil {
+		return withExitCode(exitOutputMissing, err)
+	}
+	targetDir := outputDir
+	if j.config.Staging {
+		targetDir = j.config.pendingDir()
+		if !ctx.dryRun {
+			if err := os.MkdirAll(targetDir, 0755); err != nil {
+				return err
+			}
+		}
+	}
+	existingNames, err := ctx.names.names(targetDir, j.config.CaseSensitiveNames, j.knownDirs())
+	if err != nil {
+		return err
+	}
+	jobConfig := j.config
+	defer func() { j.config = jobConfig }()
+	for _, sourceDir := range sourceDirs {
+		sourceConfig, sourceName, err := jobConfig.forSource(sourceDir)
+		if err != nil {
+			return withExitCode(exitConfig, err)
+		}
+		if sourceName != "" {
+			j.logger.Printf("using the settings of source %s for %s\n", sourceName, sourceDir)
+		}
+		j.config = sourceConfig
+		if j.config.NameTemplate != "" {
+			nameTemplate, err := j.config.parseNameTemplate()
+			if err != nil {
+				return withExitCode(exitConfig, err)
+			}
+			j.nameTemplate = nameTemplate
+		}
+		if j.excludes, err = parseExcludePatterns(j.config.ExcludePatterns); err != nil {
+			return withExitCode(exitConfig, err)
+		}
+		j.metadata = spotlightMetadata(sourceDir)
+		err = filepath.WalkDir(sourceDir, j.copyWallpapersTo(targetDir, existingNames, ctx))
+		if err != nil {
+			return err
+		}
+	}
+	if ctx.dryRun {
+		j.logger.Printf("would copy %d of %d files\n", j.stats.Copied, j.stats.Scanned)
+		return nil
+	}
+	j.logger.Printf("copied %d of %d files\n", j.stats.Copied, j.stats.Scanned)
+	if err := j.applyRetention(ctx.library); err != nil {
+		return err
+	}
+	return j.refreshRotation(ctx.library)
+}
+
+// sharedIndex holds the names of the existing wallpapers of every
+// target folder, shared by all the jobs of a run so that two jobs
+// writing to the same folder never copy the same file
+type sharedIndex struct {
+	mu   sync.Mutex
+	sets map[string]*nameSet
+}
+
+// newSharedIndex returns an empty index
+func newSharedIndex() *sharedIndex {
+	return &sharedIndex{sets: make(map[string]*nameSet)}
+}
+
+// names returns the set of names of a target folder
+//
+// The first time a folder is requested the set is read from the
+// files in knownDirs and the list of rejected wallpapers
+func (i *sharedIndex) names(targetDir string, caseSensitive bool, knownDirs []string) (*nameSet, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if set, ok := i.sets[targetDir]; ok {
+		return set, nil
+	}
+	set := newNameSet(caseSensitive)
+	for _, dir := range knownDirs {
+		if err := set.addDir(dir); err != nil {
+			return nil, err
+		}
+	}
+	for _, name := range loadIgnored() {
+		set.add(name)
+	}
+	i.sets[targetDir] = set
+	return set, nil
+}
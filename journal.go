@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// runJournal records the files created during a run
+// so that the run can be undone
+type runJournal struct {
+	mu      sync.Mutex
+	Started time.Time
+	Files   []string
+}
+
+// newRunJournal returns an empty journal for a run starting now
+func newRunJournal() *runJournal {
+	return &runJournal{Started: time.Now()}
+}
+
+// journalDir returns the folder where the journals of the runs are kept
+func journalDir() string {
+	return filepath.Join(appDir(), "runs")
+}
+
+// add records a file created by the run
+func (j *runJournal) add(filePath string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.Files = append(j.Files, filePath)
+}
+
+// save writes the journal to the journal folder
+//
+// Runs that didn't create any file are not recorded
+func (j *runJournal) save() error {
+	if len(j.Files) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(journalDir(), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+	name := j.Started.Format("20060102-150405") + ".json"
+	return os.WriteFile(filepath.Join(journalDir(), name), data, 0644)
+}
+
+// lastJournalPath returns the path of the journal of the most recent run
+func lastJournalPath() (string, error) {
+	paths, err := filepath.Glob(filepath.Join(journalDir(), "*.json"))
+	if err != nil {
+		return "", err
+	}
+	if len(paths) == 0 {
+		return "", fmt.Errorf("there are no runs to undo")
+	}
+	sort.Strings(paths)
+	return paths[len(paths)-1], nil
+}
+
+// readJournal reads the journal of a run
+func readJournal(journalPath string) (*runJournal, error) {
+	data, err := os.ReadFile(journalPath)
+	if err != nil {
+		return nil, err
+	}
+	journal := new(runJournal)
+	if err := json.Unmarshal(data, journal); err != nil {
+		return nil, fmt.Errorf("couldn't read journal %s", journalPath)
+	}
+	return journal, nil
+}
+
+// changedSinceSaved tells whether the file of an entry no longer
+// has the checksum recorded when it was saved
+func changedSinceSaved(entry *indexEntry) bool {
+	if entry == nil || entry.Checksum == "" {
+		return false
+	}
+	checksum, err := fileChecksum(entry.Path)
+	return err == nil && hex.EncodeToString(checksum) != entry.Checksum
+}
+
+// undoLastRun removes the files created by the most recent run,
+// their index entries and the journal, so the run before can be undone next
+//
+// The assets saved by the run are forgotten, so the next run saves them again.
+// Files changed since the run, according to the checksum in the index, are
+// kept, since they are no longer exactly what the run created. With --dry-run
+// the files that would be removed and kept are only listed
+func undoLastRun(args []string) {
+	flags := newFlagSet("undo")
+	dryRun := flags.Bool("dry-run", false, "list the files that would be removed, without removing them")
+	flags.Parse(args)
+	if flags.NArg() != 0 {
+		flags.Usage()
+		os.Exit(1)
+	}
+	journalPath, err := lastJournalPath()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	journal, err := readJournal(journalPath)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	library, err := loadIndex()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if *dryRun {
+		var kept []string
+		fmt.Println(tr("undoing the run of %s would remove:", journal.Started.Format(time.DateTime)))
+		for _, filePath := range journal.Files {
+			if changedSinceSaved(library.get(filePath)) {
+				kept = append(kept, filePath)
+				continue
+			}
+			fmt.Printf("    %s\n", filePath)
+		}
+		if len(kept) > 0 {
+			fmt.Println(tr("and would keep, since they changed since the run:"))
+			for _, filePath := range kept {
+				fmt.Printf("    %s\n", filePath)
+			}
+		}
+		return
+	}
+	removed := 0
+	var undone []string
+	for _, filePath := range journal.Files {
+		if changedSinceSaved(library.get(filePath)) {
+			fmt.Println(tr("keeping %s, it changed since the run", filePath))
+			continue
+		}
+		undone = append(undone, filePath)
+		err := os.Remove(filePath)
+		if err != nil && !os.IsNotExist(err) {
+			log.Fatalf("couldn't remove %s: %s\n", filePath, err)
+		}
+		if err == nil {
+			removed++
+		}
+		library.remove(filePath)
+	}
+	if err := library.save(); err != nil {
+		log.Fatalln(err)
+	}
+	seen, err := loadSeen()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	seen.forgetSaved(undone)
+	if err := seen.save(); err != nil {
+		log.Fatalln(err)
+	}
+	if err := os.Remove(journalPath); err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Println(tr("removed %d files created by the run of %s", removed, journal.Started.Format(time.DateTime)))
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+)
+
+// listSources prints the files of the Spotlight folders with their size
+// and whether a run would copy them, without copying anything
+func listSources(args []string) {
+	flags := newFlagSet("list")
+	jobNames := flags.String("job", "", "comma separated names of the jobs to list, all jobs if empty")
+	flags.Parse(args)
+
+	iniConfig := loadIniConfig()
+	jobs, err := loadJobs(iniConfig, configFromIni(iniConfig), *jobNames)
+	if err != nil {
+		fatalConfig(err)
+	}
+	library, err := loadIndex()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	seen, err := loadSeen()
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	table := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, tr("NAME\tRESOLUTION\tFILE SIZE\tSTATUS"))
+	for _, j := range jobs {
+		if j.name != "" {
+			fmt.Fprintf(table, "%s\t\t\t\n", j.logger.Prefix())
+		}
+		sourceDirs, err := findSourceDirs(j.config.SourceDir, newLeveledLogger("", levelError))
+		if err != nil {
+			log.Fatalln(err)
+		}
+		var knownDirs []string
+		for _, dir := range j.knownDirs() {
+			if checkDirectory(dir) == nil {
+				knownDirs = append(knownDirs, dir)
+			}
+		}
+		existingNames, err := newSharedIndex().names(j.config.OutputDir, j.config.CaseSensitiveNames, knownDirs)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		for _, sourceDir := range sourceDirs {
+			err := filepath.WalkDir(sourceDir, func(imagePath string, d fs.DirEntry, err error) error {
+				if err != nil || d.IsDir() {
+					return err
+				}
+				info, err := d.Info()
+				if err != nil {
+					return err
+				}
+				resolution := "-"
+				if width, height, err := imageSize(imagePath); err == nil {
+					resolution = fmt.Sprintf("%dx%d", width, height)
+				}
+				status := j.sourceStatus(imagePath, d.Name(), existingNames, library, seen)
+				fmt.Fprintf(table, "%s\t%s\t%s\t%s\n", d.Name(), resolution, formatFileSize(info.Size()), status)
+				return nil
+			})
+			if err != nil {
+				log.Fatalln(err)
+			}
+		}
+	}
+	table.Flush()
+}
+
+// sourceStatus returns whether a run of the job would copy a file
+// of the Spotlight folder, or why not
+func (j *job) sourceStatus(imagePath string, name string, existingNames *nameSet, library *libraryIndex, seen *seenAssets) string {
+	if checksum, err := fileChecksum(imagePath); err == nil {
+		if asset := seen.get(j.stateKey(), checksum); asset != nil && !j.rescores(asset) {
+			switch asset.Decision {
+			case seenSmall:
+				return tr("too small")
+			case seenRendition:
+				return tr("other rendition")
+			case seenTheme:
+				return tr("other theme")
+			case seenText:
+				return tr("text overlay")
+			case seenPeople:
+				return tr("features people")
+			case seenLowScore:
+				return tr("low score")
+			default:
+				return tr("exists")
+			}
+		}
+	}
+	isWallpaper, err := isImageWallpaper(imagePath, j.config)
+	if err != nil {
+		return tr("not an image")
+	}
+	if !isWallpaper {
+		return tr("too small")
+	}
+	if library.bySource(name) != nil || (!j.config.customNames() && existingNames.has(j.config.defaultTargetName(name, imagePath))) {
+		return tr("exists")
+	}
+	return tr("new")
+}
+
+// formatFileSize returns a size in bytes in a readable unit
+func formatFileSize(size int64) string {
+	switch {
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%.0f KB", float64(size)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", size)
+}
@@ -0,0 +1,15 @@
+//go:build !windows
+
+package main
+
+import "os"
+
+// systemLocale returns the locale of the messages set in the environment
+func systemLocale() string {
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if locale := os.Getenv(name); locale != "" {
+			return locale
+		}
+	}
+	return ""
+}
@@ -0,0 +1,12 @@
+package main
+
+import "golang.org/x/sys/windows"
+
+// systemLocale returns the preferred display language of the user
+func systemLocale() string {
+	languages, err := windows.GetUserPreferredUILanguages(windows.MUI_LANGUAGE_NAME)
+	if err != nil || len(languages) == 0 {
+		return ""
+	}
+	return languages[0]
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// margins are the parts of the screen covered by overlays,
+// as percentages of each side
+type margins struct {
+	Top, Right, Bottom, Left int
+}
+
+// parseMargins reads margins written as "top,right,bottom,left"
+func parseMargins(text string) (margins, error) {
+	fields := strings.Split(text, ",")
+	if len(fields) != 4 {
+		return margins{}, fmt.Errorf("%s is not a list of margins like 0,0,30,0", text)
+	}
+	var values [4]int
+	for i, field := range fields {
+		value, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || value < 0 || value >= 100 {
+			return margins{}, fmt.Errorf("%s is not a list of margins like 0,0,30,0", text)
+		}
+		values[i] = value
+	}
+	if values[0]+values[2] >= 100 || values[1]+values[3] >= 100 {
+		return margins{}, fmt.Errorf("margins %s leave no room for the image", text)
+	}
+	return margins{values[0], values[1], values[2], values[3]}, nil
+}
+
+// safeCrop returns the part of an image shown on a screen of the given
+// size, moved so that the center of the image, where the subject of a
+// wallpaper usually is, falls in the middle of the area left free by
+// the margins instead of behind the clock
+func safeCrop(bounds image.Rectangle, width int, height int, safe margins) image.Rectangle {
+	crop := coverRect(bounds, width, height)
+	scale := float64(crop.Dx()) / float64(width)
+	safeX := float64(width) * (float64(safe.Left) + float64(100-safe.Left-safe.Right)/2) / 100
+	safeY := float64(height) * (float64(safe.Top) + float64(100-safe.Top-safe.Bottom)/2) / 100
+	centerX := float64(bounds.Min.X+bounds.Max.X) / 2
+	centerY := float64(bounds.Min.Y+bounds.Max.Y) / 2
+	x := min(max(int(centerX-safeX*scale), bounds.Min.X), bounds.Max.X-crop.Dx())
+	y := min(max(int(centerY-safeY*scale), bounds.Min.Y), bounds.Max.Y-crop.Dy())
+	return image.Rect(x, y, x+crop.Dx(), y+crop.Dy())
+}
+
+// lockScreenPath returns the path of the lock screen crop of a wallpaper
+func (c *config) lockScreenPath(wallpaperPath string) string {
+	return filepath.Join(c.LockScreenDir, filepath.Base(wallpaperPath))
+}
+
+// saveLockScreenCrop saves the lock screen companion of a wallpaper
+// in LockScreenDir, returning its path
+func saveLockScreenCrop(config *config, wallpaperPath string) (string, error) {
+	width, height, err := parseSize(config.LockScreenSize)
+	if err != nil {
+		return "", err
+	}
+	safe, err := parseMargins(config.LockScreenMargins)
+	if err != nil {
+		return "", err
+	}
+	img, err := decodeImage(wallpaperPath)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(config.LockScreenDir, 0755); err != nil {
+		return "", err
+	}
+	targetPath := config.lockScreenPath(wallpaperPath)
+	crop := resizeRect(img, safeCrop(img.Bounds(), width, height, safe), width, height)
+	return targetPath, encodeJPEG(crop, targetPath)
+}
+
+// lockScreenLibrary creates the lock screen crops missing
+// for the wallpapers of the library
+func lockScreenLibrary() {
+	config := loadConfig()
+	if config.LockScreenDir == "" {
+		log.Fatalln("LockScreenDir is not set in the configuration")
+	}
+	library, err := loadIndex()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	created := 0
+	entries := library.sorted(func(a, b *indexEntry) bool { return a.Path < b.Path })
+	for _, entry := range entries {
+		if config.isUltrawide(entry.Width, entry.Height) {
+			continue
+		}
+		if _, err := os.Stat(config.lockScreenPath(entry.Path)); err == nil {
+			continue
+		}
+		if _, err := saveLockScreenCrop(config, entry.Path); err != nil {
+			fmt.Println(err)
+			continue
+		}
+		created++
+	}
+	fmt.Println(tr("created %d lock screen crops", created))
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// log levels, from less to more verbose
+const (
+	levelError = iota
+	levelInfo
+	levelDebug
+)
+
+// logLevelNames are the values of LogLevel
+var logLevelNames = map[string]int{"error": levelError, "info": levelInfo, "debug": levelDebug}
+
+// logOptions are set by the --quiet, --verbose and --debug flags
+var logOptions struct {
+	quiet   bool
+	verbose bool
+	debug   bool
+}
+
+// logLevel returns the log level given by the flags,
+// or else by LogLevel in the configuration
+func (c *config) logLevel() int {
+	switch {
+	case logOptions.debug:
+		return levelDebug
+	case logOptions.quiet:
+		return levelError
+	case logOptions.verbose:
+		return levelInfo
+	}
+	if level, ok := logLevelNames[strings.ToLower(c.LogLevel)]; ok {
+		return level
+	}
+	return levelInfo
+}
+
+// leveledLogger is a logger that leaves out the messages
+// above its level
+//
+// Print, Printf and Println log informative messages,
+// errors and per-file details have their own methods
+type leveledLogger struct {
+	*log.Logger
+	level int
+
+	mu     sync.Mutex
+	errors []string
+}
+
+// newLeveledLogger returns a logger writing to the standard log
+func newLeveledLogger(prefix string, level int) *leveledLogger {
+	return &leveledLogger{Logger: log.New(log.Writer(), prefix, log.Flags()|log.Lmsgprefix), level: level}
+}
+
+// Print logs an informative message
+func (l *leveledLogger) Print(v ...any) {
+	if l.level >= levelInfo {
+		l.Logger.Print(v...)
+	}
+}
+
+// Printf logs an informative message
+func (l *leveledLogger) Printf(format string, v ...any) {
+	if l.level >= levelInfo {
+		l.Logger.Printf(format, v...)
+	}
+}
+
+// Println logs an informative message
+func (l *leveledLogger) Println(v ...any) {
+	if l.level >= levelInfo {
+		l.Logger.Println(v...)
+	}
+}
+
+// errorPrefix marks the errors in the log, so they can be told
+// apart from informative messages, e.g. in the event log
+const errorPrefix = "error: "
+
+// logError logs an error in the standard log
+func logError(err error) {
+	log.Output(2, errorPrefix+err.Error())
+}
+
+// Errorln logs an error, which is logged at any level
+func (l *leveledLogger) Errorln(v ...any) {
+	l.addError(fmt.Sprint(v...))
+	l.Logger.Output(2, errorPrefix+fmt.Sprintln(v...))
+}
+
+// Errorf logs an error, which is logged at any level
+func (l *leveledLogger) Errorf(format string, v ...any) {
+	l.addError(fmt.Sprintf(format, v...))
+	l.Logger.Output(2, errorPrefix+fmt.Sprintf(format, v...))
+}
+
+// addError keeps an error logged, so it can be reported after a run
+func (l *leveledLogger) addError(message string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.errors = append(l.errors, strings.TrimSuffix(message, "\n"))
+}
+
+// loggedErrors returns the errors logged so far
+func (l *leveledLogger) loggedErrors() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.errors...)
+}
+
+// debugImage logs what is read from an image to decide whether it's
+// a wallpaper, when debugging
+func (j *job) debugImage(imagePath string) {
+	if j.logger.level < levelDebug {
+		return
+	}
+	width, height, err := imageSize(imagePath)
+	if err != nil {
+		j.logger.Debugf("%s has no readable size: %s", filepath.Base(imagePath), err)
+		return
+	}
+	j.logger.Debugf("%s is %dx%d with EXIF orientation %d (minimum %dx%d)", filepath.Base(imagePath),
+		width, height, imageOrientation(imagePath), j.config.MinimumWidth, j.config.MinimumHeight)
+}
+
+// Debugf logs a detail only wanted when troubleshooting,
+// like the decisions about every file
+func (l *leveledLogger) Debugf(format string, v ...any) {
+	if l.level >= levelDebug {
+		l.Logger.Output(2, "debug: "+fmt.Sprintf(format, v...))
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// logBackupPath returns the path of a rotated log file, logs.1.txt
+// being the most recent
+func logBackupPath(n int) string {
+	return strings.TrimSuffix(logPath(), ".txt") + fmt.Sprintf(".%d.txt", n)
+}
+
+// rotateLogs keeps the log files within the limits of the configuration
+//
+// When logs.txt reaches MaxSizeMB it becomes logs.1.txt, shifting the
+// older backups, and only MaxBackups of them are kept. Backups older
+// than MaxAgeDays are removed. A limit of 0 disables it
+func rotateLogs(config *config) error {
+	stat, err := os.Stat(logPath())
+	if err == nil && config.MaxSizeMB > 0 && stat.Size() >= int64(config.MaxSizeMB)*1024*1024 {
+		if config.MaxBackups == 0 {
+			if err := os.Remove(logPath()); err != nil {
+				return err
+			}
+		} else {
+			os.Remove(logBackupPath(config.MaxBackups))
+			for n := config.MaxBackups - 1; n >= 1; n-- {
+				if err := os.Rename(logBackupPath(n), logBackupPath(n+1)); err != nil && !os.IsNotExist(err) {
+					return err
+				}
+			}
+			if err := os.Rename(logPath(), logBackupPath(1)); err != nil {
+				return err
+			}
+		}
+	}
+
+	backups, err := filepath.Glob(strings.TrimSuffix(logPath(), ".txt") + ".*.txt")
+	if err != nil {
+		return err
+	}
+	cutoff := time.Now().AddDate(0, 0, -config.MaxAgeDays)
+	for _, backup := range backups {
+		var n int
+		if _, err := fmt.Sscanf(filepath.Base(backup), "logs.%d.txt", &n); err != nil {
+			continue
+		}
+		stat, err := os.Stat(backup)
+		if err != nil {
+			continue
+		}
+		tooMany := n > config.MaxBackups
+		tooOld := config.MaxAgeDays > 0 && stat.ModTime().Before(cutoff)
+		if tooMany || tooOld {
+			if err := os.Remove(backup); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,6 @@
+package main
+
+// machineConfigDir returns the folder of the machine-wide configuration
+func machineConfigDir() string {
+	return "/Library/Application Support/wspotsave"
+}
@@ -0,0 +1,8 @@
+//go:build !windows && !darwin
+
+package main
+
+// machineConfigDir returns the folder of the machine-wide configuration
+func machineConfigDir() string {
+	return "/etc/wspotsave"
+}
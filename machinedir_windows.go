@@ -0,0 +1,16 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// machineConfigDir returns the folder of the machine-wide configuration,
+// %ProgramData%\wspotsave, which installers write and users can't change
+func machineConfigDir() string {
+	programData := os.Getenv("ProgramData")
+	if programData == "" {
+		programData = `C:\ProgramData`
+	}
+	return filepath.Join(programData, "wspotsave")
+}
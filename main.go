@@ -1,12 +1,17 @@
 package main
 
 import (
+	"encoding/hex"
+	"flag"
 	"fmt"
+	"image"
 	"io"
 	"io/fs"
 	"log"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"runtime"
 	"strconv"
 	"strings"
 
@@ -15,47 +20,337 @@ import (
 )
 
 type config struct {
-	SourceDir     string `comment:"Windows Spotlight's content delivery manager folder"`
-	OutputDir     string `comment:"Folder to save images"`
+	SourceDir     string `comment:"Windows Spotlight's content delivery manager folder, known locations are probed if it doesn't exist"`
+	OutputDir     string `comment:"Folder to save images, or several folders separated by ; to save them to each"`
 	MinimumWidth  int    `comment:"Minimum image width to be considered as a wallpaper"`
 	MinimumHeight int    `comment:"Minimum image height to be considered as a wallpaper"`
+	MatchDisplay  bool   `comment:"Use the resolution of the largest attached monitor as the minimum size instead of MinimumWidth and MinimumHeight"`
+
+	MinAspectRatio float64 `comment:"Minimum width to height ratio of a wallpaper, like 1.3 to skip square and portrait images. 0 disables it"`
+	MaxAspectRatio float64 `comment:"Maximum width to height ratio of a wallpaper, like 2.4 to skip ultrawide banners. 0 disables it"`
+	MinBrightness  float64 `comment:"Minimum average brightness of a wallpaper in percent, like 8 to skip nearly black frames. 0 disables it"`
+	MaxBrightness  float64 `comment:"Maximum average brightness of a wallpaper in percent, like 90 to skip washed out images. 0 disables it"`
+	MinContrast    float64 `comment:"Minimum contrast of a wallpaper, the standard deviation of its brightness in percent, like 8 to skip flat images. 0 disables it"`
+	MinSharpness   float64 `comment:"Minimum sharpness of a wallpaper, the variance of the Laplacian of its brightness, like 20 to skip blurry images. 0 disables it"`
+	Orientation    string  `comment:"Orientation of the wallpapers to keep: any, landscape or portrait"`
+	Theme          string  `comment:"Brightness of the wallpapers to keep: any, dark for those that go with a dark mode, or light"`
+
+	KeepRenditions string `comment:"Which renditions are kept of the photos Spotlight delivers both in landscape and in portrait: both, landscape or portrait"`
+	PairDirs       bool   `comment:"Save the renditions of those photos to the Landscape and Portrait folders inside OutputDir"`
+
+	ExcludePatterns   string `comment:"Source files to skip, by name or SHA-256 checksum, separated by ;. Globs like *promo* or regular expressions between slashes like /^ab12/"`
+	MinimumFileSizeKB int    `comment:"Source files smaller than this many kilobytes are skipped without opening them, 0 checks every file"`
+
+	CaseSensitiveNames bool   `comment:"Whether file names that only differ in case are different files when checking for existing wallpapers"`
+	OnExisting         string `comment:"What is done when a new image's file already exists: skip, overwrite, overwrite-if-different to repair saved copies that changed, or rename to save it beside with a suffix"`
+
+	Staging    bool   `comment:"Save new wallpapers to PendingDir to approve or reject them with wspotsave review"`
+	PendingDir string `comment:"Folder where new wallpapers wait for review, Pending inside OutputDir if empty"`
+
+	MaxImages        int     `comment:"Maximum number of wallpapers kept in OutputDir, the oldest are removed after each run. 0 keeps them all"`
+	MaxLibrarySizeGB float64 `comment:"Maximum size in gigabytes of the wallpapers in OutputDir, the oldest are removed after each run. 0 keeps them all"`
+	RetentionOrder   string  `comment:"Which wallpapers are removed first when OutputDir is over MaxImages or MaxLibrarySizeGB: oldest, or score for the lowest scored"`
+
+	RotationDir   string `comment:"Folder kept with only the newest landscape wallpapers, for slideshows. Disabled if empty"`
+	RotationCount int    `comment:"Number of wallpapers kept in RotationDir"`
+	RotationOrder string `comment:"Which wallpapers are kept in RotationDir: newest, or score for the highest scored"`
+
+	ScoreCommand    string  `comment:"Command that prints the aesthetic score of the image path it receives, wallpapers aren't scored if empty"`
+	MinScore        float64 `comment:"Score under which new images are skipped, requires ScoreCommand. 0 keeps them all"`
+	ClassifyCommand string  `comment:"Command that prints the scene labels of the image path it receives, separated by commas, wallpapers aren't tagged if empty"`
+	OrganizeBy      string  `comment:"How wallpapers are arranged in OutputDir: none, category to move them to the folders of the [categories] section, or color to move them to folders named after their most common color, like Blue"`
+
+	ExcludePeople       bool    `comment:"Skip images where people are prominent, requires DetectPeopleCommand"`
+	DetectPeopleCommand string  `comment:"Command that prints how prominent people are, from 0 to 1, in the image path it receives"`
+	PeopleThreshold     float64 `comment:"Prominence from which an image with people is skipped"`
+
+	TextOverlays      string  `comment:"What is done with images with prominent text, like promotional tiles: keep, skip, or folder to move them to the Promotions folder inside OutputDir"`
+	DetectTextCommand string  `comment:"Command that prints how prominent text is, from 0 to 1, in the image path it receives, like an OCR. If empty, edges and tones that look like text are looked for"`
+	TextThreshold     float64 `comment:"Prominence from which an image has text"`
+
+	StackDistance     int `comment:"Maximum number of different bits between the perceptual hashes of two wallpapers in the same stack"`
+	DuplicateDistance int `comment:"New images whose perceptual hash differs in at most this many bits from a saved wallpaper's are skipped as duplicates, like re-encoded or slightly cropped copies. -1 saves them"`
+
+	FixOrientation bool   `comment:"Rotate images that have an EXIF orientation so they are upright in viewers that ignore the tag"`
+	WebPToJPEG     bool   `comment:"Save WebP images as JPEG, for viewers and slideshows that can't open WebP"`
+	ConvertCommand string `comment:"Command that converts the image path it receives first to the path it receives second, in the format of its extension, like magick. Used for HEIC and AVIF images and to save WebP, which are copied as they are if empty"`
+	OutputFormat   string `comment:"Format every wallpaper is saved in, whatever it arrived as: jpeg, png, or webp with ConvertCommand. Empty keeps the format of each image"`
+	PNGCompression string `comment:"Compression of the PNG images that are encoded: default, fast, best or none"`
+
+	OutputSize       string `comment:"Size WIDTHxHEIGHT wallpapers are scaled and cropped to from the center when saved, like 2560x1440, to fit a monitor exactly. Ultrawide panoramas and images of the other orientation are saved as they are. Disabled if empty"`
+	JPEGQuality      int    `comment:"Quality from 1 to 100 JPEG wallpapers are re-encoded at to take less space, 0 copies them byte for byte"`
+	TargetFileSizeKB int    `comment:"Size in kilobytes JPEG wallpapers are re-encoded under by lowering their quality, down to 40. 0 doesn't limit it"`
+
+	StripMetadata bool   `comment:"Leave out of the saved images the EXIF, XMP, comments and texts they were delivered with, keeping only what affects the pixels"`
+	EmbedMetadata bool   `comment:"Write the title, description and copyright from Spotlight into the EXIF of saved JPEG images, for photo managers"`
+	XMPSidecars   bool   `comment:"Write an .xmp file beside each saved wallpaper with its title, description, keywords, source and capture date, for darktable and digiKam"`
+	IPTCCaption   string `comment:"Template of the IPTC caption written into saved JPEG images, e.g. {{.Title}}. {{.Description}}, with the fields Title, Description, Copyright, Source, Tags, Category, Colors, Tone (warm, cool or neutral) and Date and the functions of NameTemplate and join. Not written if empty"`
+	IPTCKeywords  string `comment:"Template of the IPTC keywords written into saved JPEG images, separated by ;, e.g. windows-spotlight;{{.Title}};{{join \";\" .Colors}}. Not written if empty"`
+
+	WatermarkText     string  `comment:"Template of a text stamped in a corner of saved JPEG and PNG wallpapers, like {{.Copyright}} or a personal tag, with the fields and functions of IPTCCaption. Nothing is stamped if empty"`
+	WatermarkFont     string  `comment:"TrueType or OpenType font file of the watermark, Go Regular if empty"`
+	WatermarkSize     float64 `comment:"Height of the watermark text in percent of the image height"`
+	WatermarkOpacity  float64 `comment:"Opacity of the watermark, from 0 for invisible to 1"`
+	WatermarkPosition string  `comment:"Corner of the watermark: top-left, top-right, bottom-left or bottom-right"`
+
+	BurnCaption bool   `comment:"Draw the title of the photo, usually where it was taken, as a subtle caption centered in the lower margin of saved JPEG and PNG wallpapers, with the font, size and opacity of the watermark"`
+	CaptionText string `comment:"Template of the caption, with the fields and functions of IPTCCaption"`
+
+	UltrawideRatio float64 `comment:"Width to height ratio from which an image is an ultrawide panorama, saved to UltrawideDir without the minimum height. 0 disables it"`
+	UltrawideDir   string  `comment:"Folder for ultrawide panoramas, Ultrawide inside OutputDir if empty"`
+
+	LockScreenDir     string `comment:"Folder where a crop of every new wallpaper is saved for the lock screen. Disabled if empty"`
+	LockScreenSize    string `comment:"Size of the lock screen crops, WIDTHxHEIGHT"`
+	LockScreenMargins string `comment:"Parts of the lock screen covered by the clock and status, in percent of the top,right,bottom,left sides. The center of the image is kept in the rest"`
+
+	LogLevel   string `comment:"How much is written to logs.txt: error for errors only, info, or debug for the decisions about every file"`
+	MaxSizeMB  int    `comment:"Size in megabytes from which logs.txt is rotated to logs.1.txt, 0 never rotates it"`
+	MaxBackups int    `comment:"Number of rotated log files kept"`
+	MaxAgeDays int    `comment:"Days after which rotated log files are removed, 0 keeps them"`
+	LogTarget  string `comment:"Where the log is written: file for logs.txt, eventlog for the Windows Event Log (Application, source wspotsave) or both"`
+
+	TelemetryURL string `comment:"Address where anonymous usage statistics are sent when telemetry is turned on, nothing is sent if empty"`
+
+	NameTemplate string `comment:"Template for the names of new wallpapers, e.g. {{.Date.Format \"2006-01-02\"}}-{{slugify .Title}}, with the functions slugify, shorten, lower, upper and lookup of the [names] section. The source name if empty"`
+	NameCommand  string `comment:"Command that receives the data of an image as JSON in its input and prints the name to save it as, used instead of NameTemplate"`
+
+	DaemonInterval string `comment:"Time between the runs of wspotsave daemon, like 6h or 1d"`
+	ControlAddress string `comment:"Address where wspotsave daemon serves its JSON-RPC control API, keep it on localhost"`
+
+	Language string `comment:"Language of the console messages (en, es), the language of the system is used if empty"`
+
+	ConfigVersion int `comment:"Version of the layout of this file, upgraded automatically by new releases, don't change it"`
+
+	categories      []category
+	names           map[string]string
+	sources         []sourceOverride
+	extraOutputDirs []string
+	secrets         map[string]resolvedSecret
+	// the minimum size comes from a monitor and applies to the long
+	// and short sides of images in any orientation
+	sidesMinimum bool
+}
+
+// runStats counts what happened during a run
+type runStats struct {
+	Scanned     int
+	Copied      int
+	Small       int
+	Existing    int
+	People      int
+	Theme       int
+	TextOverlay int
+	LowScore    int
+	Errors      int
+	SpaceSaved  int64
+	Files       []copiedFile
+	Failures    []string
+}
+
+// copiedFile is a wallpaper copied during a run
+type copiedFile struct {
+	Job    string `json:",omitempty"`
+	Source string
+	Path   string
+	Width  int
+	Height int
+}
+
+// add adds the counts of other stats
+func (s *runStats) add(other *runStats) {
+	s.Scanned += other.Scanned
+	s.Copied += other.Copied
+	s.Small += other.Small
+	s.Existing += other.Existing
+	s.People += other.People
+	s.Theme += other.Theme
+	s.TextOverlay += other.TextOverlay
+	s.LowScore += other.LowScore
+	s.Errors += other.Errors
+	s.SpaceSaved += other.SpaceSaved
+	s.Files = append(s.Files, other.Files...)
+	s.Failures = append(s.Failures, other.Failures...)
 }
 
+// portable tells whether configuration and logs are kept beside the
+// executable instead of the per-user configuration folder
+var portable bool
+
 func main() {
-	args := os.Args[1:]
-	if len(args) == 1 && args[0] == "restore" {
-		fmt.Println("restoring default configuration")
-		restoreConfig(configPath())
-		os.Exit(0)
-	}
-	if len(args) != 0 {
-		fmt.Printf("Unknown arguments %s\n", strings.Join(args, " "))
-		os.Exit(1)
+	flag.BoolVar(&portable, "portable", false, "keep configuration and logs beside the executable")
+	addRunFlags(flag.CommandLine)
+	addDryRunFlag(flag.CommandLine)
+	addJSONFlag(flag.CommandLine)
+	addSinceFlag(flag.CommandLine)
+	flag.BoolVar(&logOptions.quiet, "quiet", false, "log only errors")
+	flag.BoolVar(&logOptions.verbose, "verbose", false, "also print the log to the console")
+	flag.BoolVar(&logOptions.debug, "debug", false, "log the decisions about every file and print the log to the console")
+	flag.Usage = func() { showHelp(nil) }
+	flag.Parse()
+	portable = portable || isPortableInstall()
+	removeOldExecutable()
+	args := flag.Args()
+	if len(args) == 0 {
+		// running without a command saves the new wallpapers,
+		// like it always did
+		args = []string{"run"}
 	}
+	runSubcommand(args)
+}
 
-	logFilePath := filepath.Join(executablePath(), "logs.txt")
-	logFile, err := os.OpenFile(logFilePath, os.O_CREATE|os.O_APPEND|os.O_RDWR, 0644)
-	if err != nil {
-		log.Fatalln(err)
-	}
-	log.SetOutput(logFile)
+// logFile is the open log file
+var logFile *os.File
 
+// openLog sends the log to the log file, separating
+// the lines of this run from the ones before
+//
+// The log files are rotated first, so the daemon opens the log
+// again before every run, closing the one it had open since an
+// open file can't be renamed on Windows
+//
+// Depending on LogTarget the log goes to logs.txt, to the Windows
+// Event Log or to both
+func openLog() {
+	openLogFor(loadConfig())
+}
+
+// openLogFor opens the log as openLog does with a configuration
+// already read, so the daemon doesn't read the files again
+func openLogFor(config *config) {
+	if logFile != nil {
+		log.SetOutput(os.Stderr)
+		logFile.Close()
+		logFile = nil
+	}
+	var writers []io.Writer
+	if config.LogTarget != "eventlog" {
+		if err := rotateLogs(config); err != nil {
+			fmt.Println(err)
+		}
+		var err error
+		logFile, err = os.OpenFile(logPath(), os.O_CREATE|os.O_APPEND|os.O_RDWR, 0644)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		writers = append(writers, logFile)
+	}
+	if config.LogTarget == "eventlog" || config.LogTarget == "both" {
+		if systemLog == nil {
+			var err error
+			systemLog, err = openSystemLog()
+			if err != nil {
+				log.Fatalln(err)
+			}
+		}
+		writers = append(writers, systemLog)
+	}
+	if logOptions.verbose || logOptions.debug {
+		writers = append(writers, os.Stderr)
+	}
+	log.SetOutput(io.MultiWriter(writers...))
 	log.Default().Println()
+}
 
-	config := loadConfig()
+// systemLog is the open Windows Event Log
+var systemLog io.Writer
 
-	sourceDir := config.SourceDir
-	if err := checkDirectory(sourceDir); err != nil {
-		log.Fatalln(err)
+// runOnce runs the jobs once, reading the configuration again,
+// and returns the stats of all the jobs
+//
+// In a dry run nothing is written, only what would be copied is printed.
+// With retry only the files that couldn't be copied before are tried
+func runOnce(options runSettings) (*runStats, error) {
+	iniConfig := options.iniConfig
+	if iniConfig == nil {
+		iniConfig = loadIniConfig()
 	}
-	outputDir := config.OutputDir
-	if err := checkDirectory(outputDir); err != nil {
-		log.Fatalln(err)
+	config := configFromIni(iniConfig)
+	if problems := validateConfig(iniConfig); len(problems) > 0 {
+		return nil, withExitCode(exitConfig, configError(problems))
 	}
-	err = filepath.WalkDir(sourceDir, copyWallpapersTo(outputDir))
+	jobs, err := loadJobs(iniConfig, config, options.jobs)
 	if err != nil {
-		log.Fatalln(err)
+		return nil, withExitCode(exitConfig, err)
+	}
+	since, err := parseSince(options.since)
+	if err != nil {
+		return nil, err
+	}
+
+	library, err := loadIndex()
+	if err != nil {
+		return nil, err
+	}
+	seen, err := loadSeen()
+	if err != nil {
+		return nil, err
+	}
+	failed, err := loadFailed()
+	if err != nil {
+		return nil, err
+	}
+	ctx := &runContext{
+		names:       newSharedIndex(),
+		journal:     newRunJournal(),
+		library:     library,
+		seen:        seen,
+		failed:      newFailedFiles(),
+		since:       since,
+		cleanSource: options.cleanSource,
+		dryRun:      options.dryRun,
+	}
+	if options.retry {
+		ctx.retry = failed
+	}
+	runErr := runJobs(jobs, ctx)
+	stats := new(runStats)
+	for _, j := range jobs {
+		stats.add(j.stats)
+	}
+	if options.dryRun {
+		if !options.json {
+			fmt.Println(tr("would copy %d of %d files", stats.Copied, stats.Scanned))
+		}
+		return stats, stats.result(runErr)
+	}
+	// the index, journal and failed files of the configured library
+	// aren't about an output folder given for an ad-hoc run
+	if adHocOutput() {
+		recordTelemetry(config, stats)
+		return stats, stats.result(runErr)
+	}
+	if err := ctx.journal.save(); err != nil {
+		logError(err)
+	}
+	stackLibrary(library, config.StackDistance)
+	if err := library.save(); err != nil {
+		logError(err)
+	}
+	// decisions of ad-hoc runs with other folders or sizes
+	// mustn't change what the regular runs skip
+	if len(flagOverrides) == 0 {
+		if err := seen.save(); err != nil {
+			logError(err)
+		}
 	}
+	failed.replace(jobs, ctx.failed)
+	if err := failed.save(); err != nil {
+		logError(err)
+	}
+	recordTelemetry(config, stats)
+	return stats, stats.result(runErr)
+}
+
+// result returns the error a run ends with, given the error of the
+// jobs, so files that couldn't be copied make the run fail too
+func (s *runStats) result(jobsErr error) error {
+	if jobsErr != nil {
+		return jobsErr
+	}
+	if s.Errors > 0 {
+		return withExitCode(exitPartial, fmt.Errorf("%d files couldn't be processed", s.Errors))
+	}
+	return nil
 }
 
 // executablePath returns the path of the directory of the executable
@@ -68,68 +363,506 @@ func executablePath() string {
 	return exPath
 }
 
+// isPortableInstall tells whether the executable folder contains
+// the portable marker file or a configuration from an older version
+//
+// An older configuration in a folder that can't be written, like
+// Program Files, is copied to the per-user folder instead, since
+// the logs couldn't be written beside it
+func isPortableInstall() bool {
+	if _, err := os.Stat(filepath.Join(executablePath(), "wspotsave.portable")); err == nil {
+		return true
+	}
+	legacyPath := filepath.Join(executablePath(), "wspotsave.ini")
+	if _, err := os.Stat(legacyPath); err != nil {
+		return false
+	}
+	if isWritableDir(executablePath()) {
+		return true
+	}
+	if _, err := os.Stat(configPath()); os.IsNotExist(err) {
+		if err := copyFile(legacyPath, configPath()); err != nil {
+			logError(err)
+		} else {
+			fmt.Println(tr("copied the configuration %s to %s", legacyPath, configPath()))
+		}
+	}
+	return false
+}
+
+// isWritableDir tells whether files can be created in a directory
+func isWritableDir(dir string) bool {
+	probe, err := os.CreateTemp(dir, ".wspotsave-*")
+	if err != nil {
+		return false
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+	return true
+}
+
+// appDir returns the directory where the configuration and logs live
+//
+// In portable mode it is the directory of the executable, otherwise
+// it is the wspotsave folder inside the per-user configuration
+// directory (%APPDATA% on Windows), which is created if needed
+func appDir() string {
+	if portable {
+		return executablePath()
+	}
+	userDir, err := os.UserConfigDir()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	dir := filepath.Join(userDir, "wspotsave")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Fatalln(err)
+	}
+	return dir
+}
+
 // configPath returns the path of the configuration
 func configPath() string {
-	cfgFilePath := filepath.Join(executablePath(), "wspotsave.ini")
+	cfgFilePath := filepath.Join(appDir(), "wspotsave.ini")
 	return cfgFilePath
 }
 
+// applyWallpaper sets a saved wallpaper as the desktop background
+//
+// The image is looked up relative to the output directory
+// when the given path doesn't exist
+func applyWallpaper(imagePath string) {
+	if _, err := os.Stat(normalizePath(imagePath)); os.IsNotExist(err) {
+		imagePath = filepath.Join(loadConfig().OutputDir, imagePath)
+	}
+	imagePath = normalizePath(imagePath)
+	if err := setWallpaper(imagePath); err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Println(tr("wallpaper set to %s", imagePath))
+}
+
 // copyWallpapersTo returns a lambda function of type fs.WalkDirFunc
 // that copies a file from sourceDir to the outputDir
 //
 // It validates if the file can be a wallpapers and if it doesn't
-// already exists in the output directory
-func copyWallpapersTo(outputDir string) fs.WalkDirFunc {
-	walkDirFunc := func(imagePath string, d fs.DirEntry, _ error) error {
+// already exists in the output directory, according to the names
+// in existingNames. The outcome is counted in the stats of the job
+// and the copied files are recorded in the journal and the index
+func (j *job) copyWallpapersTo(outputDir string, existingNames *nameSet, ctx *runContext) fs.WalkDirFunc {
+	knownDirs := j.knownDirs()
+	walkDirFunc := func(imagePath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			j.logger.Errorln(err)
+			ctx.failed.add(j.stateKey(), imagePath, err)
+			return nil
+		}
 		if d.IsDir() {
 			return nil
 		}
-		isWallpaper, err := isImageWallpaper(imagePath)
+		if ctx.retry != nil && !ctx.retry.has(j.stateKey(), imagePath) {
+			return nil
+		}
+		if !ctx.since.IsZero() {
+			if info, err := d.Info(); err == nil && info.ModTime().Before(ctx.since) {
+				return nil
+			}
+		}
+		j.stats.Scanned++
+		if j.excluded(d.Name()) {
+			j.logger.Printf("%s is excluded\n", d.Name())
+			return nil
+		}
+		if j.config.MinimumFileSizeKB > 0 {
+			if info, err := d.Info(); err == nil && info.Size() < int64(j.config.MinimumFileSizeKB)*1024 {
+				j.logger.Debugf("%s file is too small", d.Name())
+				j.stats.Small++
+				return nil
+			}
+		}
+		checksum, err := fileChecksum(imagePath)
 		if err != nil {
-			log.Print(err)
+			j.logger.Errorln(err)
+			ctx.failed.add(j.stateKey(), imagePath, err)
 			return nil
 		}
-		if !isWallpaper {
-			log.Printf("%s size is too small\n", d.Name())
+		if j.excluded(hex.EncodeToString(checksum)) {
+			j.logger.Printf("%s is excluded by its checksum\n", d.Name())
 			return nil
 		}
-		targetPath := filepath.Join(outputDir, d.Name()+".jpg")
-		_, err = os.Stat(targetPath)
-		if os.IsNotExist(err) {
-			log.Printf("copying file %s\n", targetPath)
-			err = copyFile(imagePath, targetPath)
+		format, err := imageFormat(imagePath)
+		if err != nil {
+			j.logger.Errorln(err)
+			ctx.failed.add(j.stateKey(), imagePath, err)
+			return nil
+		}
+		if format == "" {
+			j.logger.Debugf("%s is not an image", d.Name())
+			return nil
+		}
+		if asset := ctx.seen.get(j.stateKey(), checksum); asset != nil && !j.rescores(asset) {
+			j.logger.Debugf("%s was already seen: %s", d.Name(), asset.Decision)
+			// a saved copy that was damaged since is saved again
+			if asset.Decision == seenSaved && j.config.onExisting() == existingOverwriteIfDifferent &&
+				j.replaceExisting(ctx, imagePath, d.Name(), asset.Path, nil, checksum) {
+				return nil
+			}
+			switch asset.Decision {
+			case seenSmall, seenRendition:
+				j.stats.Small++
+			case seenPeople:
+				j.stats.People++
+			case seenTheme:
+				j.stats.Theme++
+			case seenText:
+				j.stats.TextOverlay++
+			case seenLowScore:
+				j.stats.LowScore++
+			default:
+				j.stats.Existing++
+			}
+			if asset.Decision == seenSaved {
+				j.cleanSource(ctx, imagePath, asset.Path)
+			}
+			return nil
+		}
+		// the same image can come again under another name, or have
+		// been saved by another job to the same folders
+		if entry := ctx.library.savedFrom(hex.EncodeToString(checksum), knownDirs); entry != nil {
+			if _, err := os.Stat(entry.Path); err == nil {
+				j.logger.Printf("%s is already saved as %s\n", d.Name(), entry.Path)
+				j.stats.Existing++
+				ctx.seen.add(j.stateKey(), checksum, seenSaved, entry.Path)
+				j.cleanSource(ctx, imagePath, entry.Path)
+				return nil
+			}
+		}
+		j.debugImage(imagePath)
+		width, height, err := imageSize(imagePath)
+		if err != nil {
+			j.logger.Errorln(err)
+			ctx.failed.add(j.stateKey(), imagePath, err)
+			return nil
+		}
+		if !j.config.isWallpaperSize(width, height) {
+			j.logger.Printf("%s size is too small\n", d.Name())
+			j.stats.Small++
+			ctx.seen.add(j.stateKey(), checksum, seenSmall, "")
+			return nil
+		}
+		// the image is decoded once for every check of its pixels, which
+		// are left out for formats that can't be decoded, like HEIC
+		img, err := decodeImage(imagePath)
+		if err != nil && j.config.filtersPixels() {
+			j.logger.Errorln(err)
+			ctx.failed.add(j.stateKey(), imagePath, err)
+			return nil
+		} else if err != nil {
+			j.logger.Debugf("%s can't be decoded, its pixels aren't checked: %s", d.Name(), err)
+		}
+		if img != nil && !j.config.hasGoodPixels(img) {
+			j.logger.Printf("%s is too dark, too bright, too flat or too blurry\n", d.Name())
+			j.stats.Small++
+			ctx.seen.add(j.stateKey(), checksum, seenSmall, "")
+			return nil
+		}
+		pairChecksum := j.pairChecksum(hex.EncodeToString(checksum))
+		if j.skipsRendition(width, height, pairChecksum) {
+			j.logger.Printf("%s is the %s rendition of a pair\n", d.Name(), orientation(width, height))
+			j.stats.Small++
+			ctx.seen.add(j.stateKey(), checksum, seenRendition, "")
+			return nil
+		}
+		if j.skipsTheme(img) {
+			j.logger.Printf("%s doesn't go with the %s theme\n", d.Name(), j.config.Theme)
+			j.stats.Theme++
+			ctx.seen.add(j.stateKey(), checksum, seenTheme, "")
+			return nil
+		}
+		if duplicate := j.savedDuplicate(ctx.library, img); duplicate != nil {
+			j.logger.Printf("%s looks like %s\n", d.Name(), duplicate.Path)
+			j.stats.Existing++
+			ctx.seen.add(j.stateKey(), checksum, seenDuplicate, duplicate.Path)
+			return nil
+		}
+		targetName, err := j.targetName(d.Name(), imagePath, checksum)
+		if err != nil {
+			j.logger.Errorln(err)
+			return nil
+		}
+		isNew := false
+		if !j.config.customNames() {
+			isNew = existingNames.tryAdd(targetName)
+		} else if ctx.library.bySource(d.Name()) == nil {
+			// different images can get the same custom name
+			targetName = existingNames.addUnique(targetName)
+			isNew = true
+		}
+		existingPath := filepath.Join(outputDir, targetName)
+		if entry := ctx.library.bySource(d.Name()); entry != nil {
+			existingPath = entry.Path
+		}
+		if !isNew && j.config.onExisting() == existingRename && differsFromSaved(ctx.library, existingPath, checksum) {
+			// a different image with the same name is saved beside it
+			targetName = existingNames.addUnique(targetName)
+			isNew = true
+		}
+		targetPath := filepath.Join(outputDir, targetName)
+		j.logger.Debugf("%s is large enough, target %s", d.Name(), targetPath)
+		if isNew {
+			score, textOverlay, skipped := j.skipsContent(ctx, d.Name(), imagePath, img, checksum)
+			if skipped {
+				existingNames.remove(targetName)
+				return nil
+			}
+			if ctx.dryRun {
+				j.stats.Copied++
+				plannedPath := j.plannedPath(imagePath, targetPath, outputDir, pairChecksum, textOverlay)
+				j.logger.Printf("would copy %s to %s\n", imagePath, plannedPath)
+				if !runOptions.json {
+					fmt.Println(tr("would copy %s to %s", imagePath, plannedPath))
+				}
+				j.stats.Files = append(j.stats.Files, copiedFile{j.name, imagePath, plannedPath, width, height})
+				return nil
+			}
+			j.logger.Printf("copying file %s\n", targetPath)
+			err = saveWallpaper(j.config, imagePath, targetPath)
 			if err != nil {
-				log.Println(err)
+				j.logger.Errorln(err)
+				ctx.failed.add(j.stateKey(), imagePath, err)
+				existingNames.remove(targetName)
+			} else {
+				j.countSpaceSaved(imagePath, targetPath)
+				j.stats.Copied++
+				entry := j.indexEntry(targetPath, d.Name(), img)
+				entry.Score = score
+				entry.TextOverlay = textOverlay
+				if metadata, ok := j.metadata[hex.EncodeToString(checksum)]; ok {
+					entry.Title = metadata.Title
+					entry.Description = metadata.Description
+					entry.Copyright = metadata.Copyright
+				}
+				j.finishSaved(imagePath, entry, checksum)
+				if pairChecksum != "" {
+					linkPair(ctx.library, entry, pairChecksum, knownDirs)
+				}
+				if outputDir == j.config.OutputDir {
+					if err := organizeFile(j.config, entry); err != nil {
+						j.logger.Errorln(err)
+					}
+				}
+				ctx.journal.add(entry.Path)
+				j.writeSidecar(ctx, entry, imagePath)
+				ctx.library.put(entry)
+				j.stats.Files = append(j.stats.Files, copiedFile{j.name, imagePath, entry.Path, entry.Width, entry.Height})
+				ctx.seen.add(j.stateKey(), checksum, seenSaved, entry.Path)
+				j.cleanSource(ctx, imagePath, entry.Path)
+				if j.config.LockScreenDir != "" && !j.config.isUltrawide(entry.Width, entry.Height) {
+					lockScreenPath, err := saveLockScreenCrop(j.config, entry.Path)
+					if err != nil {
+						j.logger.Errorln(err)
+					} else {
+						ctx.journal.add(lockScreenPath)
+					}
+				}
+			}
+		} else if !j.replaceExisting(ctx, imagePath, d.Name(), existingPath, img, checksum) {
+			j.logger.Printf("File %s already exists\n", targetPath)
+			j.stats.Existing++
+			if entry := ctx.library.bySource(d.Name()); entry != nil {
+				ctx.seen.add(j.stateKey(), checksum, seenSaved, entry.Path)
+				j.cleanSource(ctx, imagePath, entry.Path)
+			} else {
+				ctx.seen.add(j.stateKey(), checksum, seenExisting, "")
 			}
-		} else {
-			log.Printf("File %s already exists\n", targetPath)
 		}
 		return nil
 	}
 	return walkDirFunc
 }
 
+// skipsContent runs the checks that look at what a new image shows,
+// people, text overlays and its score, recording why it is skipped
+// when one of them fails, and returns its score and whether it
+// has a text overlay
+func (j *job) skipsContent(ctx *runContext, name string, imagePath string, img image.Image, checksum []byte) (float64, bool, bool) {
+	if j.hasPeople(imagePath) {
+		j.logger.Printf("%s features people\n", name)
+		j.stats.People++
+		ctx.seen.add(j.stateKey(), checksum, seenPeople, "")
+		return 0, false, true
+	}
+	textOverlay := j.hasTextOverlay(imagePath, img)
+	if textOverlay && j.config.TextOverlays == "skip" {
+		j.logger.Printf("%s has a text overlay\n", name)
+		j.stats.TextOverlay++
+		ctx.seen.add(j.stateKey(), checksum, seenText, "")
+		return 0, true, true
+	}
+	score, scored := j.sourceScore(imagePath)
+	if scored && j.scoresTooLow(score) {
+		j.logger.Printf("%s scored under MinScore\n", name)
+		j.stats.LowScore++
+		ctx.seen.addLowScore(j.stateKey(), checksum, score)
+		return score, textOverlay, true
+	}
+	return score, textOverlay, false
+}
+
 // loadConfig loads the configurations that specifies folders
 //
-// It tries to read configuration file relative to the executable.
+// It tries to read configuration file from the application directory.
 // The name of the configuration file is wspotsave.ini.
 // If it doesn't exists, returns the default configuration
 // and creates the file.
 func loadConfig() *config {
+	return configFromIni(loadIniConfig())
+}
+
+// loadIniConfig reads the configuration, with the keys of the selected
+// profile overriding the default section, the keys set in environment
+// variables overriding the files, and the ones set in command-line
+// flags overriding everything
+func loadIniConfig() *ini.File {
+	iniConfig := loadConfigFiles()
+	applyProfile(iniConfig)
+	applyEnvOverrides(iniConfig)
+	applyFlagOverrides(iniConfig)
+	return iniConfig
+}
+
+// loadConfigFiles reads the configuration file, restoring
+// the default configuration if it doesn't exist
+//
+// When there is a machine-wide configuration, the per-user file
+// only overrides some of its keys, so a missing file is created
+// empty instead of with the default configuration
+//
+// A YAML or TOML file is used instead of the INI file when present.
+// Files written by older releases are upgraded to the current
+// ConfigVersion, the INI ones in place, and the files they name
+// in Include are read first
+func loadConfigFiles() *ini.File {
+	if alternateConfigPath() != "" {
+		iniConfig := ini.Empty()
+		for _, path := range configFiles() {
+			file, err := loadConfigWithIncludes(path)
+			if err != nil {
+				fatalConfig(fmt.Errorf("couldn't read the configuration: %s", err))
+			}
+			mergeConfig(iniConfig, file)
+		}
+		return iniConfig
+	}
 	cfgFilePath := configPath()
-	iniConfig, err := ini.Load(cfgFilePath)
-	if err != nil {
-		fmt.Println("restoring default config")
-		iniConfig = restoreConfig(cfgFilePath)
+	machinePath := machineConfigPath()
+	upgradeConfigFile(cfgFilePath)
+	if !portable {
+		upgradeConfigFile(machinePath)
 	}
-	config := new(config)
-	err = iniConfig.MapTo(config)
+	if _, err := os.Stat(machinePath); portable || err != nil {
+		iniConfig, err := loadINIConfig(cfgFilePath)
+		if err != nil {
+			fmt.Println(tr("restoring default configuration"))
+			iniConfig = restoreConfig(cfgFilePath)
+		}
+		iniConfig, err = includeConfigs(iniConfig, cfgFilePath, nil)
+		if err != nil {
+			fatalConfig(fmt.Errorf("couldn't read the configuration: %s", err))
+		}
+		return iniConfig
+	}
+	if _, err := os.Stat(cfgFilePath); os.IsNotExist(err) {
+		overrides := ini.Empty()
+		overrides.Section("").Comment = "Keys set here override the machine-wide configuration " + machinePath
+		overrides.Section("").Key("ConfigVersion").SetValue(fmt.Sprint(currentConfigVersion))
+		if err := overrides.SaveTo(cfgFilePath); err != nil {
+			log.Fatalln(err)
+		}
+	}
+	iniConfig := ini.Empty()
+	for _, path := range []string{machinePath, cfgFilePath} {
+		file, err := loadConfigWithIncludes(path)
+		if err != nil {
+			fatalConfig(fmt.Errorf("couldn't read the configuration: %s", err))
+		}
+		mergeConfig(iniConfig, file)
+	}
+	return iniConfig
+}
+
+// configFiles returns the existing configuration files,
+// the machine-wide one first
+func configFiles() []string {
+	var files []string
+	if _, err := os.Stat(machineConfigPath()); err == nil && !portable {
+		files = append(files, machineConfigPath())
+	}
+	if path := alternateConfigPath(); path != "" {
+		files = append(files, path)
+	} else if _, err := os.Stat(configPath()); err == nil {
+		files = append(files, configPath())
+	}
+	return files
+}
+
+// machineConfigPath returns the path of the machine-wide configuration
+func machineConfigPath() string {
+	return filepath.Join(machineConfigDir(), "wspotsave.ini")
+}
+
+// configFromIni returns the configuration in the default
+// section of the configuration file
+func configFromIni(iniConfig *ini.File) *config {
+	config := defaultConfig()
+	err := iniConfig.MapTo(config)
 	if err != nil {
-		log.Fatal(err)
+		fatalConfig(fmt.Errorf("couldn't read the configuration: %s", err))
 	}
+	setLanguage(config.Language)
+	config.normalizePaths()
+	config.normalizeChoices()
+	config.resolveSecrets()
+	config.categories = loadCategories(iniConfig)
+	config.names = loadNames(iniConfig)
+	config.sources = loadSourceOverrides(iniConfig)
 	return config
 }
 
+// normalizePaths normalizes the folders of the configuration
+//
+// When OutputDir lists several folders, the first one stays in OutputDir
+// and the others are kept apart, since each is saved to by its own job
+func (c *config) normalizePaths() {
+	c.SourceDir = normalizePath(c.SourceDir)
+	if dirs := strings.Split(c.OutputDir, ";"); len(dirs) > 1 {
+		c.OutputDir = dirs[0]
+		c.extraOutputDirs = nil
+		for _, dir := range dirs[1:] {
+			if dir = normalizePath(dir); dir != "" {
+				c.extraOutputDirs = append(c.extraOutputDirs, dir)
+			}
+		}
+	}
+	c.OutputDir = normalizePath(c.OutputDir)
+	c.PendingDir = normalizePath(c.PendingDir)
+	c.RotationDir = normalizePath(c.RotationDir)
+	c.UltrawideDir = normalizePath(c.UltrawideDir)
+	c.LockScreenDir = normalizePath(c.LockScreenDir)
+}
+
+// normalizeChoices lowercases the keys that take one of a list of
+// values, since they are validated ignoring case but compared as they are
+func (c *config) normalizeChoices() {
+	for _, value := range []*string{
+		&c.Orientation, &c.Theme, &c.KeepRenditions, &c.OnExisting, &c.RetentionOrder,
+		&c.RotationOrder, &c.OrganizeBy, &c.TextOverlays, &c.OutputFormat, &c.PNGCompression,
+		&c.WatermarkPosition, &c.LogLevel, &c.LogTarget,
+	} {
+		*value = strings.ToLower(strings.TrimSpace(*value))
+	}
+}
+
 // restoreConfig returns the default configuration
 // and save to the file
 func restoreConfig(filepath string) *ini.File {
@@ -141,42 +874,102 @@ func restoreConfig(filepath string) *ini.File {
 	return iniConfig
 }
 
+// defaultConfig returns the default configuration values
+//
+// Keys missing from the configuration file keep these values
+func defaultConfig() *config {
+	return &config{
+		SourceDir:          filepath.Join(localAppDataDir(), "Packages", "Microsoft.Windows.ContentDeliveryManager_cw5n1h2txyewy", "LocalState", "Assets"),
+		OutputDir:          picturesDir(),
+		MinimumWidth:       1080,
+		MinimumHeight:      1080,
+		CaseSensitiveNames: runtime.GOOS != "windows",
+		RotationCount:      20,
+		RotationOrder:      "newest",
+		OrganizeBy:         "none",
+		Orientation:        "any",
+		KeepRenditions:     "both",
+		Theme:              "any",
+		OnExisting:         existingSkip,
+		RetentionOrder:     "oldest",
+		PeopleThreshold:    0.2,
+		TextOverlays:       "keep",
+		TextThreshold:      0.03,
+		StackDistance:      10,
+		DuplicateDistance:  -1,
+		FixOrientation:     true,
+		PNGCompression:     "default",
+		WatermarkSize:      2,
+		WatermarkOpacity:   0.7,
+		WatermarkPosition:  "bottom-right",
+		CaptionText:        "{{.Title}}",
+		UltrawideRatio:     2.2,
+		LockScreenSize:     "1920x1080",
+		LockScreenMargins:  "0,0,30,0",
+		LogLevel:           "info",
+		MaxSizeMB:          5,
+		MaxBackups:         3,
+		MaxAgeDays:         90,
+		LogTarget:          "file",
+		DaemonInterval:     "6h",
+		ControlAddress:     "127.0.0.1:47615",
+		ConfigVersion:      currentConfigVersion,
+	}
+}
+
 // defaultIniConfig returns the default configuration
 func defaultIniConfig() *ini.File {
-	home := os.Getenv("USERPROFILE")
-	defaultConfig := &config{
-		SourceDir:     filepath.Join(home, "AppData", "Local", "Packages", "Microsoft.Windows.ContentDeliveryManager_cw5n1h2txyewy", "LocalState", "Assets"),
-		OutputDir:     filepath.Join(home, "Pictures"),
-		MinimumWidth:  1080,
-		MinimumHeight: 1080,
-	}
 	iniConfig := ini.Empty()
-	err := ini.ReflectFrom(iniConfig, defaultConfig)
+	err := ini.ReflectFrom(iniConfig, defaultConfig())
 	if err != nil {
 		log.Fatal(err)
 	}
+	addDefaultCategories(iniConfig)
 	return iniConfig
 }
 
-// imageSize returns the width and the height of
-// a given image path
+// imageSize returns the width and the height of a given
+// image path, as displayed according to its EXIF orientation
+//
+// The size is read from the image header when the EXIF
+// metadata doesn't have it, or there is no EXIF metadata,
+// and from the metadata boxes of HEIC and AVIF images
 func imageSize(imagePath string) (int, int, error) {
 	imageFile, err := os.Open(imagePath)
 	if err != nil {
 		return 0, 0, fmt.Errorf("couldn't open %s", imagePath)
 	}
 	defer imageFile.Close()
-	info, err := exif.Decode(imageFile)
-	if err != nil {
-		return 0, 0, fmt.Errorf("couldn't extract metadata of %s", imageFile.Name())
+	info, exifErr := exif.Decode(imageFile)
+	var width, height int
+	if exifErr == nil {
+		width, height, err = exifDimensions(info)
+	}
+	if exifErr != nil || err != nil {
+		// images without EXIF dimensions have them in their header
+		imageConfig, err := decodeImageConfig(imagePath)
+		if err == nil {
+			width, height = imageConfig.Width, imageConfig.Height
+		} else if width, height, err = heifSize(imagePath); err != nil {
+			return 0, 0, fmt.Errorf("couldn't get size of %s", imagePath)
+		}
+	}
+	if exifErr == nil && orientationSwapsSides(exifOrientation(info)) {
+		width, height = height, width
 	}
+	return width, height, nil
+}
+
+// exifDimensions returns the width and the height in the EXIF
+// metadata of an image, as stored
+func exifDimensions(info *exif.Exif) (int, int, error) {
 	widthTag, err := info.Get(exif.PixelXDimension)
 	if err != nil {
-		return 0, 0, fmt.Errorf("couldn't get width of %s", imageFile.Name())
+		return 0, 0, err
 	}
 	heightTag, err := info.Get(exif.PixelYDimension)
 	if err != nil {
-		return 0, 0, fmt.Errorf("couldn't get height of %s", imageFile.Name())
+		return 0, 0, err
 	}
 	width, err := strconv.Atoi(widthTag.String())
 	if err != nil {
@@ -190,21 +983,62 @@ func imageSize(imagePath string) (int, int, error) {
 }
 
 // isImageWallpaper tells whether the image in the given path
-// fulfills the requirements of minimum width and the
-// minimum height in the configuration
-func isImageWallpaper(imagePath string) (bool, error) {
-	config := loadConfig()
-	minimumWidth := config.MinimumWidth
-	minimumHeight := config.MinimumHeight
-
+// fulfills the requirements of minimum width, minimum height,
+// aspect ratio, orientation, brightness, contrast and sharpness in
+// the configuration
+func isImageWallpaper(imagePath string, config *config) (bool, error) {
 	width, height, err := imageSize(imagePath)
 	if err != nil {
 		return false, fmt.Errorf("couldn't get size of %s", imagePath)
 	}
-	if width < minimumWidth || height < minimumHeight {
-		return false, nil
+	if !config.isWallpaperSize(width, height) || !config.filtersPixels() {
+		return config.isWallpaperSize(width, height), nil
+	}
+	img, err := decodeImage(imagePath)
+	if err != nil {
+		return false, err
 	}
-	return true, nil
+	return config.hasGoodPixels(img), nil
+}
+
+// isWallpaperSize tells whether an image of the given size passes
+// the minimum size, aspect ratio and orientation of the configuration
+func (c *config) isWallpaperSize(width int, height int) bool {
+	return c.isLargeEnough(width, height) && c.fitsAspectRatio(width, height) && c.hasOrientation(width, height)
+}
+
+// isLargeEnough tells whether an image of the given size passes
+// the minimum size of the configuration
+func (c *config) isLargeEnough(width int, height int) bool {
+	long, short := width, height
+	if c.sidesMinimum {
+		long, short = max(width, height), min(width, height)
+	}
+	if long < c.MinimumWidth {
+		return false
+	}
+	// panoramas are much wider than tall, so only their width counts
+	return short >= c.MinimumHeight || c.isUltrawide(width, height)
+}
+
+// fitsAspectRatio tells whether an image of the given size is
+// within MinAspectRatio and MaxAspectRatio
+func (c *config) fitsAspectRatio(width int, height int) bool {
+	if height == 0 {
+		return false
+	}
+	ratio := float64(width) / float64(height)
+	if c.MinAspectRatio > 0 && ratio < c.MinAspectRatio {
+		return false
+	}
+	return c.MaxAspectRatio <= 0 || ratio <= c.MaxAspectRatio
+}
+
+// hasOrientation tells whether an image of the given size has the
+// Orientation of the configuration, square images have neither
+func (c *config) hasOrientation(width int, height int) bool {
+	wanted := strings.ToLower(strings.TrimSpace(c.Orientation))
+	return wanted == "" || wanted == "any" || wanted == orientation(width, height)
 }
 
 // checkDirectory checks if a path is a directory and exists
@@ -239,3 +1073,13 @@ func copyFile(sourcePath string, targetPath string) error {
 	}
 	return nil
 }
+
+// runCommand runs a program and includes its output in the error
+// when it fails
+func runCommand(name string, args ...string) error {
+	output, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("couldn't run %s: %s %s", name, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// translations maps a language code to the translations of the
+// console messages, keyed by the English message
+//
+// Messages without a translation are printed in English
+var translations = map[string]map[string]string{
+	"es": {
+		"restoring default configuration":                             "restaurando la configuración predeterminada",
+		"wallpaper set to %s":                                         "fondo de pantalla cambiado a %s",
+		"Unknown schedule action %s, use install or uninstall":        "Acción de programación desconocida %s, usa install o uninstall",
+		"schedule installed":                                          "programación instalada",
+		"schedule uninstalled":                                        "programación desinstalada",
+		"wspotsave %s is already the latest version":                  "wspotsave %s ya es la última versión",
+		"downloading wspotsave %s":                                    "descargando wspotsave %s",
+		"updated wspotsave from %s to %s":                             "wspotsave actualizado de %s a %s",
+		"telemetry is on":                                             "la telemetría está activada",
+		"telemetry is off":                                            "la telemetría está desactivada",
+		"TelemetryURL is empty, statistics are only kept locally":     "TelemetryURL está vacío, las estadísticas solo se guardan localmente",
+		"telemetry turned on":                                         "telemetría activada",
+		"telemetry turned off":                                        "telemetría desactivada",
+		"Unknown telemetry action %s, use status, on, off or preview": "Acción de telemetría desconocida %s, usa status, on, off o preview",
+		"removed %d files created by the run of %s":                   "se eliminaron %d archivos creados por la ejecución del %s",
+		"there are no wallpapers pending review":                      "no hay fondos de pantalla pendientes de revisión",
+		"[%d/%d] %s: (a)pprove, (r)eject, (o)pen, (s)kip, (q)uit? ":   "[%d/%d] %s: (a)probar, (r)echazar, (o)abrir, (s)altar, (q)salir? ",
+		"scored %d wallpapers":                                        "se puntuaron %d fondos de pantalla",
+		"Usage: wspotsave export [--top N] [--since date] [--until date] <folder or file.zip>": "Uso: wspotsave export [--top N] [--since fecha] [--until fecha] <carpeta o archivo.zip>",
+		"exported %d wallpapers to %s":    "se exportaron %d fondos de pantalla a %s",
+		"classified %d wallpapers":        "se clasificaron %d fondos de pantalla",
+		"reclassified %d wallpapers":      "se reclasificaron %d fondos de pantalla",
+		"%s (+%d similar)":                "%s (+%d similares)",
+		"removed %d similar wallpapers":   "se eliminaron %d fondos de pantalla similares",
+		"%d stacks of similar wallpapers": "%d grupos de fondos de pantalla similares",
+		"Usage: wspotsave find --color <#rrggbb|name|warm|cool|neutral> [--tolerance N]": "Uso: wspotsave find --color <#rrggbb|nombre|warm|cool|neutral> [--tolerance N]",
+		"no wallpapers found": "no se encontraron fondos de pantalla",
+		"Usage: wspotsave span [--size WxH] [--output file] [--apply] <image> [<image>]": "Uso: wspotsave span [--size AxA] [--output archivo] [--apply] <imagen> [<imagen>]",
+		"spanned wallpaper saved to %s":                           "fondo de pantalla extendido guardado en %s",
+		"created %d lock screen crops":                            "%d recortes para la pantalla de bloqueo creados",
+		"removed %d old log lines":                                "%d líneas antiguas del registro eliminadas",
+		"removed %d old run journals":                             "%d registros de ejecuciones antiguas eliminados",
+		"removed %d index entries of missing files":               "%d entradas del índice de archivos que ya no existen eliminadas",
+		"Usage: wspotsave search <words or filters>":              "Uso: wspotsave search <palabras o filtros>",
+		"couldn't listen on %s, is another daemon running?":       "no se pudo escuchar en %s, ¿hay otro demonio en ejecución?",
+		"daemon listening on %s":                                  "demonio escuchando en %s",
+		"Usage: wspotsave ctl scan|status|events":                 "Uso: wspotsave ctl scan|status|events",
+		"the daemon isn't running":                                "el demonio no está en ejecución",
+		"scan requested":                                          "búsqueda solicitada",
+		"a scan is already requested":                             "ya hay una búsqueda solicitada",
+		"version: %s":                                             "versión: %s",
+		"running: %t":                                             "en ejecución: %t",
+		"last run: %s, copied %d of %d files":                     "última ejecución: %s, %d de %d archivos copiados",
+		"next run: %s":                                            "próxima ejecución: %s",
+		"last error: %s":                                          "último error: %s",
+		"Unknown command %s":                                      "Comando desconocido %s",
+		"Usage: %s":                                               "Uso: %s",
+		"Commands:":                                               "Comandos:",
+		"Use wspotsave help <command> for the help of a command.": "Usa wspotsave help <comando> para ver la ayuda de un comando.",
+		"machine-wide configuration: %s":                          "configuración de la máquina: %s",
+		"configuration: %s":                                       "configuración: %s",
+		"save the new wallpapers, the default when no command is given":                                          "guardar los fondos de pantalla nuevos, lo que se hace si no se indica un comando",
+		"restore the default configuration":                                                                      "restaurar la configuración predeterminada",
+		"print the version":                                                                                      "mostrar la versión",
+		"show the commands or the help of a command":                                                             "mostrar los comandos o la ayuda de un comando",
+		"set a saved wallpaper as the desktop background":                                                        "usar un fondo de pantalla guardado como fondo del escritorio",
+		"approve or reject the wallpapers waiting in the pending folder":                                         "aprobar o rechazar los fondos de pantalla de la carpeta de pendientes",
+		"remove the files copied by the most recent run":                                                         "eliminar los archivos copiados por la última ejecución",
+		"list the wallpapers whose title, description, tags or name contain the words and that pass the filters": "listar los fondos de pantalla cuyo título, descripción, etiquetas o nombre contienen las palabras y que cumplen los filtros",
+		"list the wallpapers with a similar color":                                                               "listar los fondos de pantalla con un color parecido",
+		"list the groups of similar wallpapers":                                                                  "listar los grupos de fondos de pantalla parecidos",
+		"copy wallpapers of the library to a folder or archive":                                                  "copiar fondos de pantalla de la biblioteca a una carpeta o archivo",
+		"score the saved wallpapers with ScoreCommand":                                                           "puntuar los fondos de pantalla guardados con ScoreCommand",
+		"tag the saved wallpapers with ClassifyCommand":                                                          "etiquetar los fondos de pantalla guardados con ClassifyCommand",
+		"tag the library again and move it to the category folders":                                              "etiquetar de nuevo la biblioteca y moverla a las carpetas de categorías",
+		"create the missing lock screen crops":                                                                   "crear los recortes para la pantalla de bloqueo que faltan",
+		"compose a wallpaper that spans two monitors":                                                            "componer un fondo de pantalla que abarca dos monitores",
+		"remove old logs and state, or the wallpapers smaller than the minimum size":                             "eliminar registros y estado antiguos, o los fondos de pantalla menores que el tamaño mínimo",
+		"moved %d wallpapers smaller than the minimum size to %s":                                                "se movieron %d fondos de pantalla menores que el tamaño mínimo a %s",
+		"removed %d wallpapers smaller than the minimum size":                                                    "se eliminaron %d fondos de pantalla menores que el tamaño mínimo",
+		"run the program daily":                                                                                  "ejecutar el programa a diario",
+		"keep running and save new wallpapers periodically":                                                      "seguir en ejecución y guardar fondos de pantalla nuevos periódicamente",
+		"control the running daemon":                                                                             "controlar el demonio en ejecución",
+		"ask the running daemon to scan now":                                                                     "pedir al demonio en ejecución que busque ahora",
+		"show or change the anonymous usage statistics":                                                          "mostrar o cambiar las estadísticas de uso anónimas",
+		"replace the program with the latest release":                                                            "reemplazar el programa por la última versión publicada",
+		"would copy %s to %s":                                                                                    "se copiaría %s a %s",
+		"would replace %s with %s":                                                                               "se reemplazaría %s con %s",
+		"would copy %d of %d files":                                                                              "se copiarían %d de %d archivos",
+		"files scanned":                                                                                          "archivos revisados",
+		"wallpapers copied":                                                                                      "fondos de pantalla copiados",
+		"skipped as too small":                                                                                   "omitidos por pequeños",
+		"skipped as existing":                                                                                    "omitidos por existentes",
+		"skipped for people":                                                                                     "omitidos por personas",
+		"errors":                                                                                                 "errores",
+		"see %s for the errors":                                                                                  "consulta %s para ver los errores",
+		"commit: %s":                                                                                             "commit: %s",
+		"built: %s":                                                                                              "compilado: %s",
+		"go: %s %s/%s":                                                                                           "go: %s %s/%s",
+		"wspotsave %s is available, update with: wspotsave self-update":                                          "wspotsave %s está disponible, actualiza con: wspotsave self-update",
+		"check why wallpapers may not be copied and how to fix it":                                               "comprobar por qué no se copian fondos de pantalla y cómo solucionarlo",
+		"fix the job in the configuration":                                                                       "corrige la tarea en la configuración",
+		"Windows Spotlight is turned off by a group policy":                                                      "Windows Spotlight está desactivado por una directiva de grupo",
+		"Windows Spotlight is not chosen as the lock screen background":                                          "Windows Spotlight no está elegido como fondo de la pantalla de bloqueo",
+		"choose Windows spotlight in Settings > Personalization > Lock screen, or ask your administrator about the policy": "elige Windows spotlight en Configuración > Personalización > Pantalla de bloqueo, o consulta la directiva con tu administrador",
+		"Windows Spotlight is enabled": "Windows Spotlight está activado",
+		"%d problems found":            "se encontraron %d problemas",
+		"no problems found":            "no se encontraron problemas",
+		"there is no configuration file yet, the defaults are used":                    "aún no hay archivo de configuración, se usan los valores por defecto",
+		"the configuration can't be read: %s":                                          "no se puede leer la configuración: %s",
+		"fix the file or run wspotsave restore to write the defaults":                  "corrige el archivo o ejecuta wspotsave restore para escribir los valores por defecto",
+		"the configuration has a wrong value, %s":                                      "la configuración tiene un valor incorrecto, %s",
+		"the configuration has %d wrong values:":                                       "la configuración tiene %d valores incorrectos:",
+		"it isn't a whole number":                                                      "no es un número entero",
+		"write only digits, like 1920":                                                 "escribe solo dígitos, como 1920",
+		"it isn't a number":                                                            "no es un número",
+		"write a number with a dot, like 0.5":                                          "escribe un número con punto, como 0.5",
+		"it isn't true or false":                                                       "no es true ni false",
+		"write true or false":                                                          "escribe true o false",
+		"%c can't be in a folder path":                                                 "%c no puede estar en la ruta de una carpeta",
+		"remove it, paths with spaces don't need quotes":                               "quítalo, las rutas con espacios no necesitan comillas",
+		"it can't be negative":                                                         "no puede ser negativo",
+		"use 0 or a positive number":                                                   "usa 0 o un número positivo",
+		"it must be between %g and %g":                                                 "debe estar entre %g y %g",
+		"use a number in that range":                                                   "usa un número en ese rango",
+		"it isn't a known value":                                                       "no es un valor conocido",
+		"use one of %s":                                                                "usa uno de %s",
+		"use a size like 1920x1080":                                                    "usa un tamaño como 1920x1080",
+		"use four percents for the top, right, bottom and left, like 0,0,30,0":         "usa cuatro porcentajes para arriba, derecha, abajo e izquierda, como 0,0,30,0",
+		"use an interval like 6h or 1d":                                                "usa un intervalo como 6h o 1d",
+		"fix the template or leave it empty to keep the source names":                  "corrige la plantilla o déjala vacía para mantener los nombres de origen",
+		"write globs like *promo* or regular expressions between slashes like /^ab12/": "escribe patrones como *promo* o expresiones regulares entre barras como /^ab12/",
+		"it was written by a newer release of wspotsave":                               "fue escrita por una versión más nueva de wspotsave",
+		"update with wspotsave self-update":                                            "actualiza con wspotsave self-update",
+		"the secret %s can't be read: %s":                                              "el secreto %s no se puede leer: %s",
+		"store it with wspotsave secret set %s":                                        "guárdalo con wspotsave secret set %s",
+		"value of the secret %s: ":                                                     "valor del secreto %s: ",
+		"secret %s stored, use it in the configuration as %s%s":                        "secreto %s guardado, úsalo en la configuración como %s%s",
+		"secret %s deleted":                                                            "secreto %s eliminado",
+		"store a password or token in the Windows Credential Manager, to use in the configuration as secret:<name>": "guardar una contraseña o token en el Administrador de credenciales de Windows, para usarlo en la configuración como secret:<name>",
+		"upgraded the configuration to version %d, the old one is in %s":                                            "configuración actualizada a la versión %d, la anterior está en %s",
+		"the configuration is valid: %s":                                                                             "la configuración es válida: %s",
+		"no Spotlight folder found, %s doesn't exist":                                                                "no se encontró ninguna carpeta de Spotlight, %s no existe",
+		"set SourceDir to the folder where Spotlight keeps its images":                                               "configura SourceDir con la carpeta donde Spotlight guarda sus imágenes",
+		"SourceDir %s doesn't exist, the known Spotlight folders are used":                                           "SourceDir %s no existe, se usan las carpetas conocidas de Spotlight",
+		"the source folder can't be read: %s":                                                                        "no se puede leer la carpeta de origen: %s",
+		"check the permissions of the folder":                                                                        "revisa los permisos de la carpeta",
+		"the source folder %s is empty":                                                                              "la carpeta de origen %s está vacía",
+		"Spotlight hasn't downloaded images yet or is disabled, keep Windows spotlight as the lock screen for a day": "Spotlight aún no ha descargado imágenes o está desactivado, deja Windows spotlight como pantalla de bloqueo durante un día",
+		"the source folder %s has %d files":                                                                          "la carpeta de origen %s tiene %d archivos",
+		"the output folder is not usable: %s":                                                                        "no se puede usar la carpeta de destino: %s",
+		"create the folder or set OutputDir to an existing folder":                                                   "crea la carpeta o configura OutputDir con una carpeta existente",
+		"the output folder %s is not writable":                                                                       "no se puede escribir en la carpeta de destino %s",
+		"set OutputDir to a folder you can write to":                                                                 "configura OutputDir con una carpeta en la que puedas escribir",
+		"the output folder %s is writable":                                                                           "se puede escribir en la carpeta de destino %s",
+		"show the configuration, or get or set one of its keys":                                                      "mostrar la configuración, u obtener o cambiar una de sus claves",
+		"%s set to %s in %s":                                                                                         "%s cambiado a %s en %s",
+		"list the files of the Spotlight folder and whether they would be copied":                                    "listar los archivos de la carpeta de Spotlight y si se copiarían",
+		"NAME\tRESOLUTION\tFILE SIZE\tSTATUS":                                                                        "NOMBRE\tRESOLUCIÓN\tTAMAÑO\tESTADO",
+		"turned %s upright":                                                                                          "%s quedó derecha",
+		"turned %d wallpapers upright":                                                                               "%d fondos quedaron derechos",
+		"rotate upright the saved wallpapers that have an EXIF orientation":                                          "girar para que queden derechos los fondos guardados que tienen una orientación EXIF",
+		"fix the template or leave it empty to write nothing":                                                        "corrige la plantilla o déjala vacía para no escribir nada",
+		"saved by re-encoding":                                                                                       "ahorrado al recodificar",
+		"other theme":                                                                                                "otro tema",
+		"text overlay":                                                                                               "texto superpuesto",
+		"low score":                                                                                                  "puntuación baja",
+		"skipped for low score":                                                                                      "omitidos por puntuación",
+		"%s is already in the output folder":                                                                         "%s ya está en la carpeta de salida",
+		"it can't refer to a secret":                                                                                 "no puede hacer referencia a un secreto",
+		"write the value itself":                                                                                     "escribe el valor directamente",
+		"skipped for theme":                                                                                          "omitidos por tema",
+		"skipped for text":                                                                                           "omitidos por texto",
+		"this is a development build, it isn't compared with the releases":                                           "esta es una compilación de desarrollo, no se compara con las publicaciones",
+		"undoing the run of %s would remove:":                                                                        "deshacer la ejecución de %s eliminaría:",
+		"and would keep, since they changed since the run:":                                                          "y conservaría, porque cambiaron desde la ejecución:",
+		"forgot %d seen assets whose saved file is missing":                                                          "%d recursos vistos cuyo archivo guardado ya no existe olvidados",
+		"removed %d failed copies of missing sources":                                                                "%d copias fallidas de orígenes que ya no existen eliminadas",
+		"other rendition":                                                                                            "otra versión",
+		"too small":                                                                                                  "muy pequeño",
+		"features people":                                                                                            "tiene personas",
+		"exists":                                                                                                     "ya existe",
+		"not an image":                                                                                               "no es una imagen",
+		"new":                                                                                                        "nuevo",
+		"show how many wallpapers are saved, their disk usage and resolutions":                                       "mostrar cuántos fondos de pantalla hay guardados, el espacio que ocupan y sus resoluciones",
+		"no wallpapers saved in %s":                                                                                  "no hay fondos de pantalla guardados en %s",
+		"unknown":                                                                                                    "desconocida",
+		"wallpapers: %d":                                                                                             "fondos de pantalla: %d",
+		"disk usage: %s":                                                                                             "espacio en disco: %s",
+		"newest: %s (%s)":                                                                                            "más reciente: %s (%s)",
+		"oldest: %s (%s)":                                                                                            "más antiguo: %s (%s)",
+		"resolutions:":                                                                                               "resoluciones:",
+		"open the latest or a given saved wallpaper in the image viewer":                                             "abrir el último fondo de pantalla guardado, o uno dado, en el visor de imágenes",
+		"move the duplicated wallpapers of the output folder to a quarantine folder": "mover los fondos de pantalla duplicados de la carpeta de destino a una carpeta de cuarentena",
+		"keeping %s":                          "se conserva %s",
+		"deleted %d duplicates, %s freed":     "se eliminaron %d duplicados, %s liberados",
+		"moved %d duplicates to %s, %s freed": "se movieron %d duplicados a %s, %s liberados",
+		"check the saved wallpapers against the checksums recorded when copied": "comprobar los fondos de pantalla guardados con las sumas de verificación registradas al copiarlos",
+		"try again to copy the files that failed in the last run":               "intentar de nuevo copiar los archivos que fallaron en la última ejecución",
+		"there are no failed copies to retry":                                   "no hay copias fallidas para reintentar",
+		"keeping %s, it changed since the run":                                  "se conserva %s, cambió desde la ejecución",
+		"copied the configuration %s to %s":                                     "se copió la configuración %s a %s",
+		"missing: %s":                                                           "falta: %s",
+		"unreadable: %s: %s":                                                    "ilegible: %s: %s",
+		"modified or corrupted: %s":                                             "modificado o dañado: %s",
+		"%d wallpapers verified, %d with problems":                              "%d fondos de pantalla verificados, %d con problemas",
+		"recorded the checksum of %d wallpapers":                                "se registró la suma de verificación de %d fondos de pantalla",
+		"%d wallpapers have no recorded checksum, run wspotsave verify --record to record it": "%d fondos de pantalla no tienen suma de verificación registrada, ejecuta wspotsave verify --record para registrarla",
+	},
+}
+
+// language is the code of the language used for console messages
+var language = detectLanguage()
+
+// detectLanguage returns the language set in WSPOTSAVE_LANG
+// or, if it's empty, the language of the operating system
+func detectLanguage() string {
+	if lang := os.Getenv("WSPOTSAVE_LANG"); lang != "" {
+		return languageCode(lang)
+	}
+	return languageCode(systemLocale())
+}
+
+// setLanguage changes the language of the console messages
+// unless it was chosen with WSPOTSAVE_LANG
+func setLanguage(lang string) {
+	if lang == "" || os.Getenv("WSPOTSAVE_LANG") != "" {
+		return
+	}
+	language = languageCode(lang)
+}
+
+// languageCode returns the language part of a locale
+// name such as es-CO, es_ES.UTF-8 or en
+func languageCode(locale string) string {
+	code, _, _ := strings.Cut(locale, "_")
+	code, _, _ = strings.Cut(code, "-")
+	code, _, _ = strings.Cut(code, ".")
+	return strings.ToLower(code)
+}
+
+// tr returns a console message translated to the current language
+// and formatted with args in the manner of fmt.Sprintf
+func tr(message string, args ...any) string {
+	if translated, ok := translations[language][message]; ok {
+		message = translated
+	}
+	if len(args) == 0 {
+		return message
+	}
+	return fmt.Sprintf(message, args...)
+}
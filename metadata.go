@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// assetMetadata is the text Spotlight shows next to an image
+type assetMetadata struct {
+	Title       string
+	Description string
+	Copyright   string
+}
+
+// spotlightMetadata recovers the titles and descriptions of the images
+// of a source folder, by the hex SHA-256 of their content
+//
+// Spotlight keeps them in JSON files of TargetedContentCache, beside
+// the Assets folder, and newer builds keep JSON files among the images.
+// The format isn't documented and changes between builds, so any object
+// that has texts and images with a sha256 is used and the images
+// without a match simply have no metadata
+func spotlightMetadata(sourceDir string) map[string]assetMetadata {
+	metadata := make(map[string]assetMetadata)
+	cacheDirs := []string{filepath.Join(filepath.Dir(sourceDir), "TargetedContentCache"), sourceDir}
+	for _, cacheDir := range cacheDirs {
+		filepath.WalkDir(cacheDir, func(filePath string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return nil
+			}
+			if cacheDir == sourceDir && !strings.EqualFold(filepath.Ext(filePath), ".json") {
+				return nil
+			}
+			data, err := os.ReadFile(filePath)
+			if err != nil {
+				return nil
+			}
+			var document any
+			if json.Unmarshal(data, &document) == nil {
+				collectMetadata(document, metadata)
+			}
+			return nil
+		})
+	}
+	return metadata
+}
+
+// collectMetadata walks a JSON document adding the texts of every
+// object to the images with a sha256 found in its direct children
+func collectMetadata(value any, metadata map[string]assetMetadata) {
+	switch value := value.(type) {
+	case []any:
+		for _, item := range value {
+			collectMetadata(item, metadata)
+		}
+	case map[string]any:
+		var texts assetMetadata
+		var captions []string
+		var checksums []string
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			child := value[key]
+			text := jsonText(child)
+			name := strings.ToLower(key)
+			switch {
+			case text == "":
+			case name == "title" || name == "title_text":
+				texts.Title = text
+			case strings.Contains(name, "title"):
+				// the captions of the hotspots, e.g. hs1_title_text
+				captions = append(captions, text)
+			case strings.Contains(name, "copyright"):
+				texts.Copyright = text
+			case strings.Contains(name, "description") || strings.Contains(name, "body"):
+				texts.Description = text
+			}
+			if image, ok := child.(map[string]any); ok {
+				if checksum := jsonChecksum(image["sha256"]); checksum != "" {
+					checksums = append(checksums, checksum)
+				}
+			}
+			collectMetadata(child, metadata)
+		}
+		if texts.Description == "" {
+			texts.Description = strings.Join(captions, ". ")
+		}
+		if texts == (assetMetadata{}) {
+			return
+		}
+		for _, checksum := range checksums {
+			metadata[checksum] = texts
+		}
+	}
+}
+
+// jsonText returns the text of a JSON value, which Spotlight writes
+// either as a string or as an object with the text in "tx"
+func jsonText(value any) string {
+	switch value := value.(type) {
+	case string:
+		return strings.TrimSpace(value)
+	case map[string]any:
+		if text, ok := value["tx"].(string); ok {
+			return strings.TrimSpace(text)
+		}
+	}
+	return ""
+}
+
+// jsonChecksum returns as hex a SHA-256 written in base64 or hex
+func jsonChecksum(value any) string {
+	text, ok := value.(string)
+	if !ok {
+		return ""
+	}
+	if checksum, err := base64.StdEncoding.DecodeString(text); err == nil && len(checksum) == 32 {
+		return hex.EncodeToString(checksum)
+	}
+	if checksum, err := hex.DecodeString(text); err == nil && len(checksum) == 32 {
+		return hex.EncodeToString(checksum)
+	}
+	return ""
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/ini.v1"
+)
+
+// configMigrations upgrade the configuration from each version to
+// the next one, the first from the files written before ConfigVersion
+//
+// When a release renames or restructures keys it appends a migration
+// that moves the old keys, so old files aren't misread
+var configMigrations = []func(iniConfig *ini.File){
+	// version 1 only added ConfigVersion
+	func(iniConfig *ini.File) {},
+}
+
+// currentConfigVersion is the version of the configuration
+// written by this release
+var currentConfigVersion = len(configMigrations)
+
+// configVersion returns the version of a configuration file,
+// 0 if it was written before ConfigVersion existed
+func configVersion(iniConfig *ini.File) int {
+	return iniConfig.Section("").Key("ConfigVersion").MustInt(0)
+}
+
+// migrateConfig upgrades a configuration to the current version
+// and returns whether it changed
+func migrateConfig(iniConfig *ini.File) bool {
+	version := configVersion(iniConfig)
+	if version >= currentConfigVersion {
+		return false
+	}
+	for _, migrate := range configMigrations[version:] {
+		migrate(iniConfig)
+	}
+	iniConfig.Section("").Key("ConfigVersion").SetValue(fmt.Sprint(currentConfigVersion))
+	return true
+}
+
+// upgradeConfigFile upgrades an INI configuration file written by
+// an older release in place, keeping a copy of the old file
+//
+// Files the user can't write, like the machine-wide configuration
+// for users that aren't administrators, are left as they are since
+// they are upgraded in memory every time they are read
+func upgradeConfigFile(path string) {
+	iniConfig, err := loadINIConfig(path)
+	if err != nil || configVersion(iniConfig) >= currentConfigVersion {
+		return
+	}
+	if !isWritable(path) || !isWritableDir(filepath.Dir(path)) {
+		return
+	}
+	backupPath := fmt.Sprintf("%s.v%d.bak", path, configVersion(iniConfig))
+	migrateConfig(iniConfig)
+	if err := copyFile(path, backupPath); err != nil {
+		logError(fmt.Errorf("couldn't upgrade the configuration %s: %s", path, err))
+		return
+	}
+	if err := iniConfig.SaveTo(path); err != nil {
+		logError(fmt.Errorf("couldn't upgrade the configuration %s: %s", path, err))
+		os.Remove(backupPath)
+		return
+	}
+	fmt.Println(tr("upgraded the configuration to version %d, the old one is in %s", currentConfigVersion, backupPath))
+}
+
+// isWritable tells whether a file can be opened for writing
+func isWritable(path string) bool {
+	file, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return false
+	}
+	file.Close()
+	return true
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// nameSet is a set of file names that can ignore case
+// when comparing names
+//
+// It is safe to use by several jobs at the same time
+type nameSet struct {
+	mu            sync.Mutex
+	caseSensitive bool
+	names         map[string]bool
+}
+
+// newNameSet returns an empty set of names
+func newNameSet(caseSensitive bool) *nameSet {
+	return &nameSet{caseSensitive: caseSensitive, names: make(map[string]bool)}
+}
+
+// addDir adds the names of the files in a directory to the set
+func (s *nameSet) addDir(dirPath string) error {
+	entries, err := os.ReadDir(dirPath)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		s.add(entry.Name())
+	}
+	return nil
+}
+
+// key returns the name used as key in the set
+func (s *nameSet) key(name string) string {
+	if s.caseSensitive {
+		return name
+	}
+	return strings.ToLower(name)
+}
+
+// add adds a name to the set
+func (s *nameSet) add(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.names[s.key(name)] = true
+}
+
+// has tells whether the set contains the name
+func (s *nameSet) has(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.names[s.key(name)]
+}
+
+// tryAdd adds a name to the set and tells whether it wasn't
+// already there, so only one job gets to create the file
+func (s *nameSet) tryAdd(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.names[s.key(name)] {
+		return false
+	}
+	s.names[s.key(name)] = true
+	return true
+}
+
+// addUnique adds a name to the set, adding a number before the
+// extension when it's taken, e.g. aurora-2.jpg, and returns the
+// name added
+func (s *nameSet) addUnique(name string) string {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	unique := name
+	for i := 2; !s.tryAdd(unique); i++ {
+		unique = fmt.Sprintf("%s-%d%s", base, i, ext)
+	}
+	return unique
+}
+
+// remove removes a name from the set
+func (s *nameSet) remove(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.names, s.key(name))
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+	"gopkg.in/ini.v1"
+)
+
+// nameData is what a NameTemplate or NameCommand knows about an image
+type nameData struct {
+	Source      string
+	Title       string
+	Description string
+	Copyright   string
+	Width       int
+	Height      int
+	Date        time.Time
+	Checksum    string
+}
+
+// loadNames returns the values of the [names] section, used by
+// the lookup function of NameTemplate
+func loadNames(iniConfig *ini.File) map[string]string {
+	names := make(map[string]string)
+	section, err := iniConfig.GetSection("names")
+	if err != nil {
+		return names
+	}
+	for _, key := range section.Keys() {
+		names[strings.ToLower(key.Name())] = key.Value()
+	}
+	return names
+}
+
+// customNames tells whether wallpapers are named by
+// NameTemplate or NameCommand instead of their source name
+func (c *config) customNames() bool {
+	return c.NameTemplate != "" || c.NameCommand != ""
+}
+
+// slugDashes matches what is replaced by a dash in a slug
+var slugDashes = regexp.MustCompile(`[^a-z0-9]+`)
+
+// slugify turns a text into lowercase words without accents
+// separated by dashes, e.g. "Aurora over Þingvellir" is
+// aurora-over-ingvellir
+func slugify(text string) string {
+	var plain strings.Builder
+	for _, r := range norm.NFD.String(strings.ToLower(text)) {
+		if !unicode.Is(unicode.Mn, r) {
+			plain.WriteRune(r)
+		}
+	}
+	return strings.Trim(slugDashes.ReplaceAllString(plain.String(), "-"), "-")
+}
+
+// shorten cuts a text to at most length characters,
+// at a word boundary when there is one
+func shorten(length int, text string) string {
+	runes := []rune(text)
+	if len(runes) <= length {
+		return text
+	}
+	short := string(runes[:length])
+	if i := strings.LastIndexAny(short, " -_"); i > 0 {
+		short = short[:i]
+	}
+	return short
+}
+
+// templateFunctions returns the functions of the templates
+// of the configuration, like NameTemplate
+func (c *config) templateFunctions() template.FuncMap {
+	return template.FuncMap{
+		"slugify": slugify,
+		"shorten": shorten,
+		"lower":   strings.ToLower,
+		"upper":   strings.ToUpper,
+		"join":    func(separator string, texts []string) string { return strings.Join(texts, separator) },
+		"lookup": func(key string) string {
+			if value, ok := c.names[strings.ToLower(key)]; ok {
+				return value
+			}
+			return key
+		},
+	}
+}
+
+// parseNameTemplate parses NameTemplate with the naming functions
+func (c *config) parseNameTemplate() (*template.Template, error) {
+	nameTemplate, err := template.New("name").Funcs(c.templateFunctions()).Option("missingkey=error").Parse(c.NameTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read NameTemplate: %s", err)
+	}
+	return nameTemplate, nil
+}
+
+// unsafeNameChars matches the characters not allowed in file names
+var unsafeNameChars = regexp.MustCompile(`[<>:"/\\|?*\x00-\x1f]+`)
+
+// targetName returns the name of the file a source image is saved as
+//
+// By default it's the source name with a .jpg extension. NameCommand
+// receives the data of the image as JSON in its input and prints the
+// name, otherwise NameTemplate is used
+func (j *job) targetName(sourceName string, imagePath string, checksum []byte) (string, error) {
+	if !j.config.customNames() {
+		return j.config.defaultTargetName(sourceName, imagePath), nil
+	}
+	data := nameData{Source: sourceName, Date: time.Now(), Checksum: hex.EncodeToString(checksum)}
+	data.Width, data.Height, _ = imageSize(imagePath)
+	if metadata, ok := j.metadata[data.Checksum]; ok {
+		data.Title = metadata.Title
+		data.Description = metadata.Description
+		data.Copyright = metadata.Copyright
+	}
+
+	var name string
+	if j.config.NameCommand != "" {
+		input, err := json.Marshal(data)
+		if err != nil {
+			return "", err
+		}
+		output, err := runExternalInput(j.config.NameCommand, string(input))
+		if err != nil {
+			return "", err
+		}
+		name, _, _ = strings.Cut(output, "\n")
+	} else {
+		var output strings.Builder
+		if err := j.nameTemplate.Execute(&output, data); err != nil {
+			return "", fmt.Errorf("couldn't name %s: %s", sourceName, err)
+		}
+		name = output.String()
+	}
+
+	name = strings.Trim(unsafeNameChars.ReplaceAllString(strings.TrimSpace(name), "-"), " .-")
+	if name == "" {
+		name = sourceName
+	}
+	// titles like Mt. Fuji have a dot without being named with an extension
+	if !isWallpaperFile(name) {
+		name += j.config.savedExtension(imagePath)
+	}
+	return name, nil
+}
+
+// pngSignature are the first bytes of every PNG file
+const pngSignature = "\x89PNG\r\n\x1a\n"
+
+// imageFormat returns the format of an image from its first bytes,
+// jpeg, png, webp, heic or avif, or an empty string when the file
+// isn't an image
+func imageFormat(imagePath string) (string, error) {
+	file, err := os.Open(imagePath)
+	if err != nil {
+		return "", fmt.Errorf("couldn't open %s", imagePath)
+	}
+	defer file.Close()
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(file, header); err == io.EOF || err == io.ErrUnexpectedEOF {
+		// too short for any image
+		return "", nil
+	} else if err != nil {
+		return "", fmt.Errorf("couldn't read %s: %s", imagePath, err)
+	}
+	switch {
+	case string(header[:3]) == "\xff\xd8\xff":
+		return "jpeg", nil
+	case string(header[:len(pngSignature)]) == pngSignature:
+		return "png", nil
+	case string(header[:4]) == "RIFF" && string(header[8:]) == "WEBP":
+		return "webp", nil
+	case string(header[4:8]) == "ftyp" && heifFormat(string(header[8:])) != "":
+		return heifFormat(string(header[8:])), nil
+	}
+	return "", nil
+}
+
+// isJPEG tells whether a file is a JPEG image
+func isJPEG(imagePath string) bool {
+	format, err := imageFormat(imagePath)
+	return err == nil && format == "jpeg"
+}
+
+// savedExtension returns the extension to save an image with, the
+// one of the format it is saved in, with .jpg for JPEG
+//
+// Images that can't be read get .jpg, saving them reports the error
+func (c *config) savedExtension(imagePath string) string {
+	format, _ := imageFormat(imagePath)
+	switch format := c.outputFormat(format); format {
+	case "jpeg", "":
+		return ".jpg"
+	default:
+		return "." + format
+	}
+}
+
+// defaultTargetName returns the name an image is saved as without
+// NameTemplate or NameCommand, its source name with its extension
+//
+// JPEG images always get .jpg added, so the names of existing
+// libraries still match, while sources named like wall.png
+// don't end up as wall.png.png
+func (c *config) defaultTargetName(sourceName string, imagePath string) string {
+	extension := c.savedExtension(imagePath)
+	if extension != ".jpg" && strings.EqualFold(filepath.Ext(sourceName), extension) {
+		sourceName = strings.TrimSuffix(sourceName, filepath.Ext(sourceName))
+	}
+	return sourceName + extension
+}
@@ -0,0 +1,6 @@
+package main
+
+// openFile opens a file with its default application
+func openFile(filePath string) error {
+	return runCommand("open", filePath)
+}
@@ -0,0 +1,8 @@
+//go:build !windows && !darwin
+
+package main
+
+// openFile opens a file with its default application
+func openFile(filePath string) error {
+	return runCommand("xdg-open", filePath)
+}
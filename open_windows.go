@@ -0,0 +1,6 @@
+package main
+
+// openFile opens a file with its default application
+func openFile(filePath string) error {
+	return runCommand("rundll32", "url.dll,FileProtocolHandler", filePath)
+}
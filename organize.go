@@ -0,0 +1,203 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// unsortedCategory is the folder of wallpapers whose
+// labels don't belong to any category
+const unsortedCategory = "Unsorted"
+
+// category is a folder of the library and the labels that go into it
+type category struct {
+	name   string
+	labels []string
+}
+
+// defaultCategories are used when the configuration
+// doesn't have a [categories] section
+var defaultCategories = []category{
+	{"Nature", []string{"mountains", "mountain", "beach", "forest", "lake", "river", "waterfall", "desert", "aurora", "snow", "ocean", "sea", "sky", "flowers", "landscape"}},
+	{"Animals", []string{"animals", "animal", "bird", "birds", "wildlife"}},
+	{"Urban", []string{"city", "building", "buildings", "architecture", "street", "bridge", "skyline"}},
+	{"Aerial", []string{"aerial", "drone"}},
+	{"Abstract", []string{"abstract", "pattern", "texture"}},
+}
+
+// loadCategories returns the categories of the [categories] section,
+// where each key is a folder and its value the comma separated labels
+func loadCategories(iniConfig *ini.File) []category {
+	section, err := iniConfig.GetSection("categories")
+	if err != nil || len(section.Keys()) == 0 {
+		return defaultCategories
+	}
+	var categories []category
+	for _, key := range section.Keys() {
+		var labels []string
+		for _, label := range key.Strings(",") {
+			labels = append(labels, strings.ToLower(label))
+		}
+		categories = append(categories, category{key.Name(), labels})
+	}
+	return categories
+}
+
+// addDefaultCategories adds the [categories] section
+// with the default categories to a configuration
+func addDefaultCategories(iniConfig *ini.File) {
+	section := iniConfig.Section("categories")
+	section.Comment = "Folders used when OrganizeBy is category and the labels that go into each one"
+	for _, category := range defaultCategories {
+		section.Key(category.name).SetValue(strings.Join(category.labels, ", "))
+	}
+}
+
+// categoryOf returns the folder of the first category that
+// has any of the tags, or the unsorted folder
+func categoryOf(categories []category, tags []string) string {
+	for _, category := range categories {
+		for _, tag := range tags {
+			if slices.Contains(category.labels, tag) {
+				return category.name
+			}
+		}
+	}
+	return unsortedCategory
+}
+
+// categoryDirs returns the folders of all the categories
+func (c *config) categoryDirs() []string {
+	dirs := []string{filepath.Join(c.OutputDir, unsortedCategory)}
+	for _, category := range c.categories {
+		dirs = append(dirs, filepath.Join(c.OutputDir, category.name))
+	}
+	return dirs
+}
+
+// organizeFile moves a wallpaper to the ultrawide folder when it is
+// a panorama, to the promotions folder when it has a text overlay and
+// TextOverlays is folder, to the folder of its orientation when it is a rendition
+// of a pair and PairDirs is on, or to the folder of its category or
+// color depending on OrganizeBy, updating the path of the entry
+func organizeFile(config *config, entry *indexEntry) error {
+	var categoryDir string
+	if config.isUltrawide(entry.Width, entry.Height) {
+		categoryDir = config.ultrawideDir()
+	} else if config.TextOverlays == "folder" && entry.TextOverlay {
+		categoryDir = config.promotionsDir()
+	} else if config.PairDirs && entry.Pair != "" && config.pairDir(entry.Width, entry.Height) != "" {
+		categoryDir = config.pairDir(entry.Width, entry.Height)
+	} else if config.OrganizeBy == "category" {
+		categoryDir = filepath.Join(config.OutputDir, categoryOf(config.categories, entry.Tags))
+	} else if config.OrganizeBy == "color" {
+		categoryDir = config.colorDir(entry.Palette)
+	} else {
+		return nil
+	}
+	targetPath := filepath.Join(categoryDir, filepath.Base(entry.Path))
+	if targetPath == entry.Path {
+		return nil
+	}
+	if err := os.MkdirAll(categoryDir, 0755); err != nil {
+		return err
+	}
+	if err := moveWallpaper(entry.Path, targetPath); err != nil {
+		return fmt.Errorf("couldn't move %s to %s: %s", entry.Path, categoryDir, err)
+	}
+	entry.Path = targetPath
+	return nil
+}
+
+// plannedPath returns where a source image saved to targetPath would
+// end up once organized, for dry runs where nothing is saved
+func (j *job) plannedPath(imagePath string, targetPath string, outputDir string, pairChecksum string, textOverlay bool) string {
+	if outputDir != j.config.OutputDir {
+		return targetPath
+	}
+	name := filepath.Base(targetPath)
+	width, height, err := imageSize(imagePath)
+	if err == nil && j.config.isUltrawide(width, height) {
+		return filepath.Join(j.config.ultrawideDir(), name)
+	}
+	if j.config.TextOverlays == "folder" && textOverlay {
+		return filepath.Join(j.config.promotionsDir(), name)
+	}
+	if err == nil && j.config.PairDirs && pairChecksum != "" && j.config.pairDir(width, height) != "" {
+		return filepath.Join(j.config.pairDir(width, height), name)
+	}
+	if j.config.OrganizeBy == "color" {
+		if img, err := decodeImage(imagePath); err == nil {
+			return filepath.Join(j.config.colorDir(hexColors(imagePalette(img, paletteSize))), name)
+		}
+		return targetPath
+	}
+	if j.config.OrganizeBy != "category" {
+		return targetPath
+	}
+	var labels []string
+	if j.config.ClassifyCommand != "" {
+		labels, err = classifyImage(j.config, imagePath)
+		if err != nil {
+			j.logger.Errorln(err)
+		}
+	}
+	return filepath.Join(j.config.OutputDir, categoryOf(j.config.categories, labels), name)
+}
+
+// reclassifyLibrary labels again every wallpaper of the library
+// and moves it to the folder of its category
+func reclassifyLibrary() {
+	config := loadConfig()
+	if config.ClassifyCommand == "" {
+		log.Fatalln("ClassifyCommand is not set in the configuration")
+	}
+	library, err := loadIndex()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	// the category folders are inside the output folder, the
+	// ultrawide one may be anywhere
+	dirs := []string{config.OutputDir}
+	if !isInsideDir(config.ultrawideDir(), config.OutputDir) {
+		dirs = append(dirs, config.ultrawideDir())
+	}
+	var images []libraryImage
+	for _, dir := range dirs {
+		dirImages, err := libraryImages(config, dir)
+		if err != nil && !os.IsNotExist(err) {
+			log.Fatalln(err)
+		}
+		images = append(images, dirImages...)
+	}
+	reclassified := 0
+	for _, image := range images {
+		entry := library.get(image.path)
+		if entry == nil {
+			entry = newIndexEntry(image.path, "", nil)
+			entry.Saved = image.modTime
+		}
+		labels, err := classifyImage(config, image.path)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		config.tagEntry(entry, labels)
+		if err := organizeFile(config, entry); err != nil {
+			fmt.Println(err)
+		}
+		library.remove(image.path)
+		library.put(entry)
+		reclassified++
+	}
+	if err := library.save(); err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Println(tr("reclassified %d wallpapers", reclassified))
+}
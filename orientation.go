@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"log"
+	"os"
+
+	"github.com/rwcarlsen/goexif/exif"
+)
+
+// imageOrientation returns the EXIF orientation of an image,
+// which is 1 (upright) when the image doesn't have the tag
+func imageOrientation(imagePath string) int {
+	imageFile, err := os.Open(imagePath)
+	if err != nil {
+		return 1
+	}
+	defer imageFile.Close()
+	info, err := exif.Decode(imageFile)
+	if err != nil {
+		return 1
+	}
+	return exifOrientation(info)
+}
+
+// exifOrientation returns the orientation tag of decoded EXIF metadata
+func exifOrientation(info *exif.Exif) int {
+	tag, err := info.Get(exif.Orientation)
+	if err != nil {
+		return 1
+	}
+	orientation, err := tag.Int(0)
+	if err != nil || orientation < 1 || orientation > 8 {
+		return 1
+	}
+	return orientation
+}
+
+// orientationSwapsSides tells whether an orientation is rotated
+// a quarter turn, so width and height are swapped when displayed
+func orientationSwapsSides(orientation int) bool {
+	return orientation >= 5 && orientation <= 8
+}
+
+// orientImage returns the image transformed so that it is
+// upright, according to its EXIF orientation
+func orientImage(img image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+	bounds := img.Bounds()
+	source := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(source, source.Bounds(), img, bounds.Min, draw.Src)
+	width, height := bounds.Dx(), bounds.Dy()
+	targetWidth, targetHeight := width, height
+	if orientationSwapsSides(orientation) {
+		targetWidth, targetHeight = height, width
+	}
+	target := image.NewRGBA(image.Rect(0, 0, targetWidth, targetHeight))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			var tx, ty int
+			switch orientation {
+			case 2:
+				tx, ty = width-1-x, y
+			case 3:
+				tx, ty = width-1-x, height-1-y
+			case 4:
+				tx, ty = x, height-1-y
+			case 5:
+				tx, ty = y, x
+			case 6:
+				tx, ty = height-1-y, x
+			case 7:
+				tx, ty = height-1-y, width-1-x
+			case 8:
+				tx, ty = y, width-1-x
+			}
+			sourceOffset := source.PixOffset(x, y)
+			targetOffset := target.PixOffset(tx, ty)
+			copy(target.Pix[targetOffset:targetOffset+4], source.Pix[sourceOffset:sourceOffset+4])
+		}
+	}
+	return target
+}
+
+// uprightLibrary rotates upright the saved wallpapers that still have
+// an EXIF orientation, like those saved before FixOrientation was on,
+// re-encoding them without the tag and updating their index entries
+func uprightLibrary() {
+	library, err := loadIndex()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	rotated := 0
+	for _, entry := range library.sorted(func(a, b *indexEntry) bool { return a.Path < b.Path }) {
+		orientation := imageOrientation(entry.Path)
+		if orientation == 1 || !isJPEG(entry.Path) {
+			continue
+		}
+		if err := rewriteUpright(entry.Path, orientation); err != nil {
+			fmt.Println(err)
+			continue
+		}
+		updated := newIndexEntry(entry.Path, entry.Source, nil)
+		entry.Width, entry.Height = updated.Width, updated.Height
+		entry.Checksum, entry.Hash = updated.Checksum, updated.Hash
+		fmt.Println(tr("turned %s upright", entry.Path))
+		rotated++
+	}
+	if rotated > 0 {
+		if err := library.save(); err != nil {
+			log.Fatalln(err)
+		}
+	}
+	fmt.Println(tr("turned %d wallpapers upright", rotated))
+}
+
+// rewriteUpright replaces a JPEG image with its upright version,
+// writing it beside first so a failure leaves the original intact
+func rewriteUpright(imagePath string, orientation int) error {
+	img, err := decodeImage(imagePath)
+	if err != nil {
+		return err
+	}
+	temporaryPath := imagePath + ".tmp"
+	if err := encodeJPEG(orientImage(img, orientation), temporaryPath); err != nil {
+		os.Remove(temporaryPath)
+		return err
+	}
+	if err := os.Rename(temporaryPath, imagePath); err != nil {
+		os.Remove(temporaryPath)
+		return fmt.Errorf("couldn't replace %s: %s", imagePath, err)
+	}
+	return nil
+}
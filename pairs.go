@@ -0,0 +1,65 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// landscapeCategory and portraitCategory are the folders inside the
+// output folder where the renditions of a pair go when PairDirs is on
+const (
+	landscapeCategory = "Landscape"
+	portraitCategory  = "Portrait"
+)
+
+// pairChecksum returns the hex checksum of the other rendition of the
+// photo of an image, or an empty string if it has none
+//
+// Spotlight delivers a landscape and a portrait rendition of each
+// photo and describes both in the same object of its metadata, so
+// two images with the same texts are renditions of the same photo
+func (j *job) pairChecksum(checksum string) string {
+	metadata, ok := j.metadata[checksum]
+	if !ok || (metadata.Title == "" && metadata.Description == "") {
+		return ""
+	}
+	for otherChecksum, otherMetadata := range j.metadata {
+		if otherChecksum != checksum && otherMetadata == metadata {
+			return otherChecksum
+		}
+	}
+	return ""
+}
+
+// skipsRendition tells whether an image of the given size is the
+// rendition of a pair that KeepRenditions leaves out
+func (j *job) skipsRendition(width int, height int, pairChecksum string) bool {
+	keep := strings.ToLower(strings.TrimSpace(j.config.KeepRenditions))
+	if pairChecksum == "" || keep == "" || keep == "both" {
+		return false
+	}
+	return orientation(width, height) != keep
+}
+
+// pairDir returns the folder for a rendition of a pair, Landscape or
+// Portrait inside the output folder, or an empty string for square images
+func (c *config) pairDir(width int, height int) string {
+	switch orientation(width, height) {
+	case "landscape":
+		return filepath.Join(c.OutputDir, landscapeCategory)
+	case "portrait":
+		return filepath.Join(c.OutputDir, portraitCategory)
+	}
+	return ""
+}
+
+// linkPair records in the index entries of both renditions of a photo
+// the source checksum of the other one, once both are saved in the
+// given folders
+func linkPair(library *libraryIndex, entry *indexEntry, pairChecksum string, dirs []string) {
+	entry.Pair = pairChecksum
+	if pair := library.savedFrom(pairChecksum, dirs); pair != nil && entry.SourceChecksum != "" {
+		pair.Pair = entry.SourceChecksum
+		library.put(pair)
+	}
+}
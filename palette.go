@@ -0,0 +1,356 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// paletteSize is the number of colors kept in the palette of a wallpaper
+const paletteSize = 5
+
+// paletteSamples is the number of pixels per side sampled
+// from an image to compute its palette
+const paletteSamples = 100
+
+// colorBox is a group of pixels of similar color
+type colorBox []color.RGBA
+
+// channel returns a channel of a color: 0 red, 1 green, 2 blue
+func channel(c color.RGBA, index int) uint8 {
+	switch index {
+	case 0:
+		return c.R
+	case 1:
+		return c.G
+	default:
+		return c.B
+	}
+}
+
+// widestChannel returns the channel with the largest range
+// in the box and the size of that range
+func (b colorBox) widestChannel() (int, int) {
+	widest, widestRange := 0, -1
+	for index := 0; index < 3; index++ {
+		low, high := 255, 0
+		for _, c := range b {
+			value := int(channel(c, index))
+			low = min(low, value)
+			high = max(high, value)
+		}
+		if high-low > widestRange {
+			widest, widestRange = index, high-low
+		}
+	}
+	return widest, widestRange
+}
+
+// average returns the average color of the box
+func (b colorBox) average() color.RGBA {
+	var r, g, bl int
+	for _, c := range b {
+		r += int(c.R)
+		g += int(c.G)
+		bl += int(c.B)
+	}
+	n := max(len(b), 1)
+	return color.RGBA{uint8(r / n), uint8(g / n), uint8(bl / n), 255}
+}
+
+// imagePalette returns the dominant colors of an image, from
+// the most to the least common, using median cut quantization
+// over a sample of the pixels
+func imagePalette(img image.Image, count int) []color.RGBA {
+	bounds := img.Bounds()
+	var pixels colorBox
+	for sy := 0; sy < paletteSamples; sy++ {
+		y := bounds.Min.Y + sy*bounds.Dy()/paletteSamples
+		for sx := 0; sx < paletteSamples; sx++ {
+			x := bounds.Min.X + sx*bounds.Dx()/paletteSamples
+			pixels = append(pixels, color.RGBAModel.Convert(img.At(x, y)).(color.RGBA))
+		}
+	}
+	boxes := []colorBox{pixels}
+	for len(boxes) < count {
+		// split the box with the widest color range at its median
+		split, splitChannel, splitRange := -1, 0, 0
+		for i, box := range boxes {
+			index, colorRange := box.widestChannel()
+			if len(box) > 1 && colorRange > splitRange {
+				split, splitChannel, splitRange = i, index, colorRange
+			}
+		}
+		if split < 0 {
+			break
+		}
+		box := boxes[split]
+		sort.Slice(box, func(i, k int) bool {
+			return channel(box[i], splitChannel) < channel(box[k], splitChannel)
+		})
+		middle := len(box) / 2
+		boxes[split] = box[:middle]
+		boxes = append(boxes, box[middle:])
+	}
+	sort.SliceStable(boxes, func(i, k int) bool {
+		return len(boxes[i]) > len(boxes[k])
+	})
+	palette := make([]color.RGBA, len(boxes))
+	for i, box := range boxes {
+		palette[i] = box.average()
+	}
+	return palette
+}
+
+// hexColors returns colors written as #rrggbb
+func hexColors(colors []color.RGBA) []string {
+	hexes := make([]string, len(colors))
+	for i, c := range colors {
+		hexes[i] = fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+	}
+	return hexes
+}
+
+// parseHexColor reads a color written as #rrggbb or rrggbb
+func parseHexColor(hex string) (color.RGBA, error) {
+	var c color.RGBA
+	_, err := fmt.Sscanf(strings.TrimPrefix(hex, "#"), "%02x%02x%02x", &c.R, &c.G, &c.B)
+	if err != nil {
+		return c, fmt.Errorf("%s is not a color like #1a3c5e", hex)
+	}
+	c.A = 255
+	return c, nil
+}
+
+// colorDistance returns the euclidean distance between two colors
+func colorDistance(a color.RGBA, b color.RGBA) float64 {
+	dr := float64(a.R) - float64(b.R)
+	dg := float64(a.G) - float64(b.G)
+	db := float64(a.B) - float64(b.B)
+	return math.Sqrt(dr*dr + dg*dg + db*db)
+}
+
+// namedColors are the colors a palette is described with in words
+var namedColors = []struct {
+	name  string
+	color color.RGBA
+}{
+	{"black", color.RGBA{20, 20, 20, 255}},
+	{"gray", color.RGBA{128, 128, 128, 255}},
+	{"white", color.RGBA{240, 240, 240, 255}},
+	{"red", color.RGBA{200, 30, 30, 255}},
+	{"orange", color.RGBA{240, 140, 30, 255}},
+	{"yellow", color.RGBA{240, 220, 50, 255}},
+	{"brown", color.RGBA{120, 75, 40, 255}},
+	{"green", color.RGBA{50, 150, 50, 255}},
+	{"teal", color.RGBA{30, 140, 140, 255}},
+	{"blue", color.RGBA{40, 90, 200, 255}},
+	{"purple", color.RGBA{120, 60, 160, 255}},
+	{"pink", color.RGBA{240, 140, 180, 255}},
+}
+
+// colorNames returns the names of the colors of a palette,
+// the nearest named color of each, without repeating them
+func colorNames(palette []string) []string {
+	var names []string
+	for _, hex := range palette {
+		c, err := parseHexColor(hex)
+		if err != nil {
+			continue
+		}
+		nearest := namedColors[0]
+		for _, named := range namedColors[1:] {
+			if colorDistance(c, named.color) < colorDistance(c, nearest.color) {
+				nearest = named
+			}
+		}
+		if !slices.Contains(names, nearest.name) {
+			names = append(names, nearest.name)
+		}
+	}
+	return names
+}
+
+// the tones of a palette
+const (
+	warmTone    = "warm"
+	coolTone    = "cool"
+	neutralTone = "neutral"
+)
+
+// hueSaturation returns the hue of a color in degrees
+// and its saturation from 0 to 1
+func hueSaturation(c color.RGBA) (float64, float64) {
+	r, g, b := float64(c.R)/255, float64(c.G)/255, float64(c.B)/255
+	high, low := max(r, g, b), min(r, g, b)
+	if high == low {
+		return 0, 0
+	}
+	var hue float64
+	switch high {
+	case r:
+		hue = math.Mod((g-b)/(high-low), 6)
+	case g:
+		hue = (b-r)/(high-low) + 2
+	default:
+		hue = (r-g)/(high-low) + 4
+	}
+	return math.Mod(hue*60+360, 360), (high - low) / high
+}
+
+// paletteTone returns whether a palette is warm, with reds, oranges
+// and yellows, cool, with greens, blues and purples, or neutral when
+// its colors are grayish or balanced. The most common colors weigh more
+func paletteTone(palette []string) string {
+	var warm, cool, total float64
+	for i, hex := range palette {
+		c, err := parseHexColor(hex)
+		if err != nil {
+			continue
+		}
+		weight := float64(len(palette) - i)
+		total += weight
+		hue, saturation := hueSaturation(c)
+		if saturation < 0.2 {
+			continue
+		}
+		if hue < 75 || hue >= 330 {
+			warm += weight * saturation
+		} else {
+			cool += weight * saturation
+		}
+	}
+	switch {
+	case total == 0 || max(warm, cool) < total*0.2:
+		return neutralTone
+	case warm > cool*1.5:
+		return warmTone
+	case cool > warm*1.5:
+		return coolTone
+	}
+	return neutralTone
+}
+
+// colorDir returns the folder of a wallpaper when OrganizeBy is color,
+// named after the most common color of its palette
+func (c *config) colorDir(palette []string) string {
+	name := unsortedCategory
+	if names := colorNames(palette); len(names) > 0 {
+		name = strings.ToUpper(names[0][:1]) + names[0][1:]
+	}
+	return filepath.Join(c.OutputDir, name)
+}
+
+// colorDirs returns the folders of all the named colors
+func (c *config) colorDirs() []string {
+	dirs := []string{filepath.Join(c.OutputDir, unsortedCategory)}
+	for _, named := range namedColors {
+		dirs = append(dirs, c.colorDir([]string{hexColors([]color.RGBA{named.color})[0]}))
+	}
+	return dirs
+}
+
+// swatches returns the colors of a palette as colored blocks
+// for terminals, or as hex codes when not printing to a terminal
+func swatches(palette []string) string {
+	if !supportsColor(os.Stdout) {
+		return strings.Join(palette, " ")
+	}
+	var blocks strings.Builder
+	for _, hex := range palette {
+		c, err := parseHexColor(hex)
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(&blocks, "\x1b[48;2;%d;%d;%dm  \x1b[0m", c.R, c.G, c.B)
+	}
+	return blocks.String()
+}
+
+// paletteLibrary computes the palette of the
+// indexed wallpapers that don't have one
+func paletteLibrary(library *libraryIndex) {
+	for _, entry := range library.sorted(func(a, b *indexEntry) bool { return a.Path < b.Path }) {
+		if len(entry.Palette) > 0 {
+			continue
+		}
+		img, err := decodeImage(entry.Path)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		library.mu.Lock()
+		entry.Palette = hexColors(imagePalette(img, paletteSize))
+		library.mu.Unlock()
+	}
+}
+
+// paletteMatcher returns whether a palette matches a color given to
+// find, a hex color within the tolerance, the name of a color like
+// blue, or a tone like warm
+func paletteMatcher(wanted string, tolerance float64) (func(palette []string) bool, error) {
+	wanted = strings.ToLower(strings.TrimSpace(wanted))
+	if wanted == warmTone || wanted == coolTone || wanted == neutralTone {
+		return func(palette []string) bool { return paletteTone(palette) == wanted }, nil
+	}
+	for _, named := range namedColors {
+		if named.name == wanted {
+			return func(palette []string) bool { return slices.Contains(colorNames(palette), wanted) }, nil
+		}
+	}
+	wantedColor, err := parseHexColor(wanted)
+	if err != nil {
+		return nil, err
+	}
+	return func(palette []string) bool {
+		for _, hex := range palette {
+			c, err := parseHexColor(hex)
+			if err == nil && colorDistance(c, wantedColor) <= tolerance {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+// findByColor prints the wallpapers whose palette has a color close
+// to the one given with --color, has a color of that name, or the tone
+func findByColor(args []string) {
+	flags := newFlagSet("find")
+	wanted := flags.String("color", "", "color to look for, like #1a3c5e, blue or warm")
+	tolerance := flags.Float64("tolerance", 20, "maximum distance between the colors")
+	flags.Parse(args)
+	if *wanted == "" {
+		fmt.Println(tr("Usage: wspotsave find --color <#rrggbb|name|warm|cool|neutral> [--tolerance N]"))
+		os.Exit(1)
+	}
+	matches, err := paletteMatcher(*wanted, *tolerance)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	library, err := loadIndex()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	paletteLibrary(library)
+	if err := library.save(); err != nil {
+		log.Fatalln(err)
+	}
+	found := 0
+	for _, entry := range library.sorted(func(a, b *indexEntry) bool { return a.Path < b.Path }) {
+		if matches(entry.Palette) {
+			fmt.Printf("%s %s\n", swatches(entry.Palette), entry.Path)
+			found++
+		}
+	}
+	if found == 0 {
+		fmt.Println(tr("no wallpapers found"))
+	}
+}
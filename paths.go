@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// windowsVariablePattern matches environment variables written as %NAME%
+var windowsVariablePattern = regexp.MustCompile(`%([A-Za-z_][A-Za-z0-9_()]*)%`)
+
+// expandVariables replaces environment variables written as %NAME%,
+// $NAME or ${NAME} with their values
+//
+// Variables that are not defined are kept as they are
+func expandVariables(path string) string {
+	path = windowsVariablePattern.ReplaceAllStringFunc(path, func(match string) string {
+		if value, ok := os.LookupEnv(strings.Trim(match, "%")); ok {
+			return value
+		}
+		return match
+	})
+	return os.Expand(path, func(name string) string {
+		if value, ok := os.LookupEnv(name); ok {
+			return value
+		}
+		return "$" + name
+	})
+}
+
+// normalizePath returns the cleaned absolute form of a path
+// coming from the configuration or the command line
+//
+// It expands environment variables, converts forward slashes to the
+// separator of the system, removes trailing separators and resolves
+// relative and drive-relative paths against the working directory.
+// UNC paths keep their \\server\share prefix. Empty paths stay empty
+func normalizePath(path string) string {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return ""
+	}
+	path = filepath.FromSlash(expandVariables(path))
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.Clean(path)
+	}
+	return absPath
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// hasPeople tells whether people are prominent in an image,
+// according to the DetectPeopleCommand of the configuration
+//
+// The command receives the path of the image as last argument and must
+// print how prominent the people are, from 0 (nobody) to 1 (the whole
+// image). Images at or above PeopleThreshold are excluded. It is only
+// run when ExcludePeople is true
+func (j *job) hasPeople(imagePath string) bool {
+	if !j.config.ExcludePeople || j.config.DetectPeopleCommand == "" {
+		return false
+	}
+	prominence, err := detectPeople(j.config, imagePath)
+	if err != nil {
+		j.logger.Errorln(err)
+		return false
+	}
+	j.logger.Debugf("people prominence in %s is %.2f (threshold %.2f)", filepath.Base(imagePath), prominence, j.config.PeopleThreshold)
+	return prominence >= j.config.PeopleThreshold
+}
+
+// detectPeople runs the people detector on an image
+func detectPeople(config *config, imagePath string) (float64, error) {
+	output, err := runExternal(config.DetectPeopleCommand, imagePath)
+	if err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(output)
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("people detector printed nothing for %s", imagePath)
+	}
+	prominence, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, fmt.Errorf("couldn't read people detection of %s: %s", imagePath, err)
+	}
+	return prominence, nil
+}
@@ -0,0 +1,23 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// picturesDir returns the user's Pictures folder
+//
+// It asks xdg-user-dir for the localized folder and falls back
+// to Pictures inside the home directory
+func picturesDir() string {
+	output, err := exec.Command("xdg-user-dir", "PICTURES").Output()
+	if dir := strings.TrimSpace(string(output)); err == nil && dir != "" {
+		return dir
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, "Pictures")
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+
+	"golang.org/x/sys/windows"
+)
+
+// picturesDir returns the user's Pictures folder
+//
+// It is resolved through the shell known folders API, so localized
+// and relocated folders are found. The English default inside the
+// user profile is used if the lookup fails
+func picturesDir() string {
+	dir, err := windows.KnownFolderPath(windows.FOLDERID_Pictures, 0)
+	if err != nil {
+		return filepath.Join(os.Getenv("USERPROFILE"), "Pictures")
+	}
+	return dir
+}
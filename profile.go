@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+
+	"gopkg.in/ini.v1"
+)
+
+// profileSectionPrefix is the prefix of the configuration sections
+// of the profiles, like [profile.phone]
+const profileSectionPrefix = "profile."
+
+// selectedProfile is the profile chosen with --profile, whose keys
+// override the default section
+var selectedProfile string
+
+// applyProfile sets the keys of the selected profile in the default section
+func applyProfile(iniConfig *ini.File) {
+	if selectedProfile == "" {
+		return
+	}
+	profile, err := iniConfig.GetSection(profileSectionPrefix + selectedProfile)
+	if err != nil {
+		fatalConfig(fmt.Errorf("profile %s is not defined", selectedProfile))
+	}
+	for _, key := range profile.Keys() {
+		iniConfig.Section("").Key(key.Name()).SetValue(key.Value())
+	}
+}
+
+// stateKey returns the name the decisions about the files of a job are
+// kept under, which includes the profile since each profile can have
+// different folders and sizes
+func (j *job) stateKey() string {
+	if selectedProfile == "" {
+		return j.name
+	}
+	return profileSectionPrefix + selectedProfile + "/" + j.name
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// pruneLibrary removes the duplicated wallpapers of the output folder,
+// keeping the largest copy of each
+//
+// Copies are byte-identical files or images whose perceptual hashes
+// differ in at most --distance bits. They are moved to a quarantine
+// folder unless --delete is given
+func pruneLibrary(args []string) {
+	flags := newFlagSet("prune")
+	distance := flags.Int("distance", 4, "the most bits the perceptual hashes of near-identical images differ in, -1 to only prune byte-identical files")
+	quarantineDir := flags.String("quarantine", filepath.Join(appDir(), "quarantine"), "the folder the duplicates are moved to")
+	remove := flags.Bool("delete", false, "delete the duplicates instead of moving them to the quarantine folder")
+	flags.Parse(args)
+
+	config := loadConfig()
+	library, err := loadIndex()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	files, err := libraryFiles(config, library)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	groups := duplicateGroups(files, library, *distance)
+	if !*remove {
+		if err := os.MkdirAll(*quarantineDir, 0755); err != nil {
+			log.Fatalln(err)
+		}
+	}
+
+	pruned := 0
+	var freed int64
+	for _, group := range groups {
+		fmt.Println(tr("keeping %s", group[0].path))
+		for _, file := range group[1:] {
+			var err error
+			if *remove {
+				err = removeWallpaper(file.path)
+			} else {
+				err = moveWallpaper(file.path, freePath(*quarantineDir, filepath.Base(file.path)))
+			}
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+			fmt.Printf("    %s\n", file.path)
+			library.remove(file.path)
+			pruned++
+			freed += file.size
+		}
+	}
+	if err := library.save(); err != nil {
+		log.Fatalln(err)
+	}
+	if *remove {
+		fmt.Println(tr("deleted %d duplicates, %s freed", pruned, formatFileSize(freed)))
+	} else {
+		fmt.Println(tr("moved %d duplicates to %s, %s freed", pruned, *quarantineDir, formatFileSize(freed)))
+	}
+}
+
+// freePath returns a path for a file in a folder that isn't taken,
+// adding a number before the extension when needed
+func freePath(dir string, name string) string {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	filePath := filepath.Join(dir, name)
+	for i := 2; ; i++ {
+		if _, err := os.Stat(filePath); os.IsNotExist(err) {
+			return filePath
+		}
+		filePath = filepath.Join(dir, fmt.Sprintf("%s-%d%s", base, i, ext))
+	}
+}
+
+// duplicateGroups returns the groups of copies of the same wallpaper,
+// the one to keep first, which is the largest and then the oldest
+func duplicateGroups(files []libraryFile, library *libraryIndex, maxDistance int) [][]libraryFile {
+	checksums := make([]string, len(files))
+	hashes := make([]uint64, len(files))
+	for i, file := range files {
+		if checksum, err := fileChecksum(file.path); err == nil {
+			checksums[i] = hex.EncodeToString(checksum)
+		}
+		if maxDistance < 0 {
+			continue
+		}
+		if entry := library.get(file.path); entry != nil && entry.Hash != 0 {
+			hashes[i] = entry.Hash
+			continue
+		}
+		img, err := decodeImage(file.path)
+		if err != nil {
+			continue
+		}
+		hashes[i] = perceptualHash(img)
+		if entry := library.get(file.path); entry != nil {
+			entry.Hash = hashes[i]
+		}
+	}
+
+	parents := make([]int, len(files))
+	for i := range parents {
+		parents[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parents[i] != i {
+			parents[i] = find(parents[i])
+		}
+		return parents[i]
+	}
+	for i := range files {
+		for k := i + 1; k < len(files); k++ {
+			identical := checksums[i] != "" && checksums[i] == checksums[k]
+			similar := hashes[i] != 0 && hashes[k] != 0 && hammingDistance(hashes[i], hashes[k]) <= maxDistance
+			if identical || similar {
+				parents[find(k)] = find(i)
+			}
+		}
+	}
+
+	members := make(map[int][]libraryFile)
+	var roots []int
+	for i, file := range files {
+		root := find(i)
+		if _, ok := members[root]; !ok {
+			roots = append(roots, root)
+		}
+		members[root] = append(members[root], file)
+	}
+	var groups [][]libraryFile
+	for _, root := range roots {
+		group := members[root]
+		if len(group) < 2 {
+			continue
+		}
+		sort.SliceStable(group, func(a, b int) bool {
+			areaA, areaB := group[a].width*group[a].height, group[b].width*group[b].height
+			if areaA != areaB {
+				return areaA > areaB
+			}
+			return group[a].saved.Before(group[b].saved)
+		})
+		groups = append(groups, group)
+	}
+	return groups
+}
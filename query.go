@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// searchFilter is a condition on a field of the saved wallpapers,
+// like resolution>=3840x2160 or orientation=portrait
+type searchFilter struct {
+	field string
+	op    string
+	value string
+	match func(entry *indexEntry) bool
+}
+
+// searchFilterPattern matches a filter of a search query
+var searchFilterPattern = regexp.MustCompile(`^([a-z-]+)(>=|<=|!=|=|>|<)(.+)$`)
+
+// isSearchFilter tells whether a word of a query is a filter
+func isSearchFilter(word string) bool {
+	return searchFilterPattern.MatchString(strings.ToLower(word))
+}
+
+// parseSearchFilter parses a filter of a search query
+//
+// The fields are resolution, width, height, orientation (landscape,
+// portrait or square), theme (dark or light), score, tag, category,
+// copied-after and copied-before
+func parseSearchFilter(word string) (*searchFilter, error) {
+	parts := searchFilterPattern.FindStringSubmatch(strings.ToLower(word))
+	if parts == nil {
+		return nil, fmt.Errorf("%s is not a filter like resolution>=3840x2160", word)
+	}
+	filter := &searchFilter{field: parts[1], op: parts[2], value: parts[3]}
+	switch filter.field {
+	case "resolution":
+		width, height, err := parseSize(filter.value)
+		if err != nil {
+			return nil, err
+		}
+		// sizes are compared side by side, so >= means at least as wide and as high
+		filter.match = func(entry *indexEntry) bool {
+			same := entry.Width == width && entry.Height == height
+			switch filter.op {
+			case "=":
+				return same
+			case "!=":
+				return !same
+			}
+			return compare(entry.Width, filter.op, width) && compare(entry.Height, filter.op, height)
+		}
+	case "width", "height":
+		size, err := strconv.Atoi(filter.value)
+		if err != nil {
+			return nil, fmt.Errorf("%s is not a number", filter.value)
+		}
+		filter.match = func(entry *indexEntry) bool {
+			if filter.field == "width" {
+				return compare(entry.Width, filter.op, size)
+			}
+			return compare(entry.Height, filter.op, size)
+		}
+	case "score":
+		score, err := strconv.ParseFloat(filter.value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s is not a number", filter.value)
+		}
+		filter.match = func(entry *indexEntry) bool {
+			return compareFloat(entry.Score, filter.op, score)
+		}
+	case "orientation":
+		if filter.value != "landscape" && filter.value != "portrait" && filter.value != "square" {
+			return nil, fmt.Errorf("orientation must be landscape, portrait or square")
+		}
+		if err := filter.equalityOnly(); err != nil {
+			return nil, err
+		}
+		filter.match = func(entry *indexEntry) bool {
+			return (entryOrientation(entry) == filter.value) == (filter.op == "=")
+		}
+	case "theme":
+		if filter.value != darkTheme && filter.value != lightTheme {
+			return nil, fmt.Errorf("theme must be dark or light")
+		}
+		if err := filter.equalityOnly(); err != nil {
+			return nil, err
+		}
+		filter.match = func(entry *indexEntry) bool {
+			return (entryTheme(entry) == filter.value) == (filter.op == "=")
+		}
+	case "tag":
+		if err := filter.equalityOnly(); err != nil {
+			return nil, err
+		}
+		filter.match = func(entry *indexEntry) bool {
+			hasTag := false
+			for _, tag := range entry.Tags {
+				hasTag = hasTag || strings.EqualFold(tag, filter.value)
+			}
+			return hasTag == (filter.op == "=")
+		}
+	case "category":
+		if err := filter.equalityOnly(); err != nil {
+			return nil, err
+		}
+		filter.match = func(entry *indexEntry) bool {
+			return strings.EqualFold(entry.Category, filter.value) == (filter.op == "=")
+		}
+	case "copied-after", "copied-before":
+		date, err := parseDate(filter.value)
+		if err != nil {
+			return nil, err
+		}
+		if filter.op != "=" {
+			return nil, fmt.Errorf("%s only takes =, e.g. %s=2024-01-31", filter.field, filter.field)
+		}
+		filter.match = func(entry *indexEntry) bool {
+			if filter.field == "copied-after" {
+				return !entry.Saved.Before(date)
+			}
+			return entry.Saved.Before(date)
+		}
+	default:
+		return nil, fmt.Errorf("unknown search field %s", filter.field)
+	}
+	return filter, nil
+}
+
+// equalityOnly returns an error when a filter compares with
+// anything other than = or !=
+func (f *searchFilter) equalityOnly() error {
+	if f.op != "=" && f.op != "!=" {
+		return fmt.Errorf("%s only takes = or !=", f.field)
+	}
+	return nil
+}
+
+// entryOrientation returns landscape, portrait or square
+func entryOrientation(entry *indexEntry) string {
+	return orientation(entry.Width, entry.Height)
+}
+
+// orientation returns landscape, portrait or square for a size
+func orientation(width int, height int) string {
+	switch {
+	case width > height:
+		return "landscape"
+	case width < height:
+		return "portrait"
+	}
+	return "square"
+}
+
+// compare compares two numbers with an operator of a filter
+func compare(a int, op string, b int) bool {
+	return compareFloat(float64(a), op, float64(b))
+}
+
+// compareFloat compares two numbers with an operator of a filter
+func compareFloat(a float64, op string, b float64) bool {
+	switch op {
+	case "=":
+		return a == b
+	case "!=":
+		return a != b
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	}
+	return false
+}
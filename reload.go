@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+// configPollInterval is how often the daemon reads the configuration
+// again to find out whether it changed
+const configPollInterval = 5 * time.Second
+
+// liveConfig is the configuration used by the daemon, replaced as a
+// whole when the files change, so a run never mixes old and new settings
+type liveConfig struct {
+	mu        sync.Mutex
+	iniConfig *ini.File
+	text      string
+	failed    string
+}
+
+// newLiveConfig returns the configuration of the daemon, read
+// the same way as for any other command
+func newLiveConfig() *liveConfig {
+	iniConfig := loadIniConfig()
+	return &liveConfig{iniConfig: iniConfig, text: configText(iniConfig)}
+}
+
+// current returns the configuration to start a run with
+func (l *liveConfig) current() *ini.File {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.iniConfig
+}
+
+// watch reads the configuration every configPollInterval and replaces
+// it when it changed and is valid, recording what changed as events
+//
+// A wrong configuration is reported once and the last good one is
+// kept, so a half-saved file doesn't stop the daemon
+func (l *liveConfig) watch(control *Control) {
+	for range time.Tick(configPollInterval) {
+		iniConfig, err := readIniConfig()
+		if err == nil {
+			if problems := validateConfig(iniConfig); len(problems) > 0 {
+				err = configError(problems)
+			}
+		}
+		l.mu.Lock()
+		if err != nil {
+			if err.Error() != l.failed {
+				l.failed = err.Error()
+				control.event("configuration change ignored: %s", err)
+			}
+			l.mu.Unlock()
+			continue
+		}
+		l.failed = ""
+		text := configText(iniConfig)
+		if text == l.text {
+			l.mu.Unlock()
+			continue
+		}
+		changes := configChanges(l.iniConfig, iniConfig)
+		oldAddress, _ := configValue(l.iniConfig, ini.DefaultSection, "ControlAddress")
+		newAddress, _ := configValue(iniConfig, ini.DefaultSection, "ControlAddress")
+		l.iniConfig, l.text = iniConfig, text
+		l.mu.Unlock()
+		for _, change := range changes {
+			control.event("configuration reloaded: %s", change)
+		}
+		if oldAddress != newAddress {
+			control.event("ControlAddress changes when the daemon is restarted")
+		}
+	}
+}
+
+// readIniConfig reads the configuration like loadIniConfig, but
+// returns the errors instead of ending the program and doesn't
+// write any file
+func readIniConfig() (*ini.File, error) {
+	iniConfig := ini.Empty()
+	for _, path := range configFiles() {
+		file, err := loadConfigWithIncludes(path)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't read the configuration: %s", err)
+		}
+		mergeConfig(iniConfig, file)
+	}
+	if selectedProfile != "" {
+		if _, err := iniConfig.GetSection(profileSectionPrefix + selectedProfile); err != nil {
+			return nil, fmt.Errorf("profile %s is not defined", selectedProfile)
+		}
+	}
+	applyProfile(iniConfig)
+	applyEnvOverrides(iniConfig)
+	applyFlagOverrides(iniConfig)
+	return iniConfig, nil
+}
+
+// configText returns a configuration as the text of an INI file,
+// to compare it with another one
+func configText(iniConfig *ini.File) string {
+	var text bytes.Buffer
+	if _, err := iniConfig.WriteTo(&text); err != nil {
+		return ""
+	}
+	return text.String()
+}
+
+// configChanges describes the keys that differ between two configurations
+func configChanges(old *ini.File, new *ini.File) []string {
+	var changes []string
+	for _, section := range new.Sections() {
+		for _, key := range section.Keys() {
+			name := configKeyName(section.Name(), key.Name())
+			oldValue, ok := configValue(old, section.Name(), key.Name())
+			switch {
+			case !ok:
+				changes = append(changes, fmt.Sprintf("%s set to %s", name, key.Value()))
+			case oldValue != key.Value():
+				changes = append(changes, fmt.Sprintf("%s changed from %s to %s", name, oldValue, key.Value()))
+			}
+		}
+	}
+	for _, section := range old.Sections() {
+		for _, key := range section.Keys() {
+			if _, ok := configValue(new, section.Name(), key.Name()); !ok {
+				changes = append(changes, fmt.Sprintf("%s removed", configKeyName(section.Name(), key.Name())))
+			}
+		}
+	}
+	return changes
+}
+
+// configValue returns the value of a key without adding it, or
+// its section, to the configuration when it is missing
+func configValue(iniConfig *ini.File, section string, key string) (string, bool) {
+	s, err := iniConfig.GetSection(section)
+	if err != nil || !s.HasKey(key) {
+		return "", false
+	}
+	return s.Key(key).Value(), true
+}
+
+// configKeyName returns the name of a key as shown in the log,
+// with its section unless it is the default one
+func configKeyName(section string, key string) string {
+	if section == ini.DefaultSection {
+		return key
+	}
+	return "[" + section + "] " + key
+}
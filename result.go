@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// runResult is the result of a run printed with --json, for
+// scripts wrapping the program
+type runResult struct {
+	Result      string
+	Error       string `json:",omitempty"`
+	DryRun      bool   `json:",omitempty"`
+	Scanned     int
+	Copied      int
+	Small       int
+	Existing    int
+	People      int
+	Theme       int
+	TextOverlay int
+	LowScore    int
+	SpaceSaved  int64 `json:",omitempty"`
+	Errors      []string
+	Files       []copiedFile
+}
+
+// printRunResult prints the result of a run as JSON to stdout
+func printRunResult(stats *runStats, runErr error) error {
+	result := runResult{Result: "ok", DryRun: runOptions.dryRun, Errors: []string{}, Files: []copiedFile{}}
+	if stats != nil {
+		result.Scanned, result.Copied = stats.Scanned, stats.Copied
+		result.Small, result.Existing, result.People = stats.Small, stats.Existing, stats.People
+		result.Theme, result.TextOverlay = stats.Theme, stats.TextOverlay
+		result.LowScore, result.SpaceSaved = stats.LowScore, stats.SpaceSaved
+		result.Errors = append(result.Errors, stats.Failures...)
+		result.Files = append(result.Files, stats.Files...)
+	}
+	if runErr != nil {
+		result.Result = "failed"
+		result.Error = runErr.Error()
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(result)
+}
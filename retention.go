@@ -0,0 +1,84 @@
+package main
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// isInsideDir tells whether a path is in a folder or its subfolders
+func isInsideDir(path string, dir string) bool {
+	if dir == "" {
+		return false
+	}
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// retainedFiles returns the wallpapers of the output folder that the
+// retention policy may remove, from the first to remove to the last
+func (j *job) retainedFiles(library *libraryIndex) ([]libraryFile, error) {
+	files, err := libraryFiles(j.config, library)
+	if err != nil {
+		return nil, err
+	}
+	var retained []libraryFile
+	for _, file := range files {
+		if !isInsideDir(file.path, j.config.OutputDir) {
+			continue
+		}
+		retained = append(retained, file)
+	}
+	score := func(file libraryFile) float64 {
+		if entry := library.get(file.path); entry != nil {
+			return entry.Score
+		}
+		return 0
+	}
+	sort.SliceStable(retained, func(i, k int) bool {
+		if j.config.RetentionOrder == "score" && score(retained[i]) != score(retained[k]) {
+			return score(retained[i]) < score(retained[k])
+		}
+		return retained[i].saved.Before(retained[k].saved)
+	})
+	return retained, nil
+}
+
+// applyRetention removes the oldest wallpapers of the output folder,
+// or the lowest scored when RetentionOrder is score, while there are
+// more than MaxImages or they take more than MaxLibrarySizeGB
+func (j *job) applyRetention(library *libraryIndex) error {
+	if j.config.MaxImages <= 0 && j.config.MaxLibrarySizeGB <= 0 {
+		return nil
+	}
+	files, err := j.retainedFiles(library)
+	if err != nil {
+		return err
+	}
+	var size int64
+	for _, file := range files {
+		size += file.size
+	}
+	maxSize := int64(j.config.MaxLibrarySizeGB * 1024 * 1024 * 1024)
+	count, removed := len(files), 0
+	for _, file := range files {
+		tooMany := j.config.MaxImages > 0 && count > j.config.MaxImages
+		tooLarge := maxSize > 0 && size > maxSize
+		if !tooMany && !tooLarge {
+			break
+		}
+		if err := removeWallpaper(file.path); err != nil {
+			j.logger.Errorln(err)
+			continue
+		}
+		library.remove(file.path)
+		j.logger.Debugf("removed %s to keep the library within its limits", file.path)
+		count--
+		size -= file.size
+		removed++
+	}
+	if removed > 0 {
+		j.logger.Printf("removed %d wallpapers to keep the library within its limits\n", removed)
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ignoredPath returns the path of the list of rejected wallpapers
+func ignoredPath() string {
+	return filepath.Join(appDir(), "ignored.txt")
+}
+
+// loadIgnored returns the names of the rejected wallpapers,
+// which are never copied again
+func loadIgnored() []string {
+	data, err := os.ReadFile(ignoredPath())
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if name := strings.TrimSpace(line); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// addIgnored adds the name of a rejected wallpaper to the ignore list
+func addIgnored(name string) error {
+	ignoredFile, err := os.OpenFile(ignoredPath(), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer ignoredFile.Close()
+	_, err = fmt.Fprintln(ignoredFile, name)
+	return err
+}
+
+// pendingDir returns the folder where new wallpapers wait
+// for review when staging is enabled
+func (c *config) pendingDir() string {
+	if c.PendingDir != "" {
+		return c.PendingDir
+	}
+	return filepath.Join(c.OutputDir, "Pending")
+}
+
+// review goes through the wallpapers waiting in the pending folder
+// asking whether to approve them into the output folder or reject them
+func review() {
+	config := loadConfig()
+	pendingDir := config.pendingDir()
+	entries, err := os.ReadDir(pendingDir)
+	if err != nil && !os.IsNotExist(err) {
+		log.Fatalln(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && isWallpaperFile(entry.Name()) {
+			names = append(names, entry.Name())
+		}
+	}
+	if len(names) == 0 {
+		fmt.Println(tr("there are no wallpapers pending review"))
+		return
+	}
+
+	library, err := loadIndex()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer library.save()
+	seen, err := loadSeen()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer seen.save()
+
+	input := bufio.NewReader(os.Stdin)
+	for i, name := range names {
+		pendingPath := filepath.Join(pendingDir, name)
+		for {
+			fmt.Print(tr("[%d/%d] %s: (a)pprove, (r)eject, (o)pen, (s)kip, (q)uit? ", i+1, len(names), name))
+			answer, err := input.ReadString('\n')
+			if err == io.EOF {
+				return
+			}
+			action := strings.ToLower(strings.TrimSpace(answer))
+			if action == "o" {
+				if err := openFile(pendingPath); err != nil {
+					fmt.Println(err)
+				}
+				continue
+			}
+			switch action {
+			case "a":
+				approvedPath := approvedPath(config, library, pendingPath)
+				if approvedPath == "" {
+					fmt.Println(tr("%s is already in the output folder", name))
+					break
+				}
+				err = moveWallpaper(pendingPath, approvedPath)
+				if err == nil {
+					library.move(pendingPath, approvedPath)
+					seen.moveSaved(pendingPath, approvedPath)
+					if entry := library.get(approvedPath); entry != nil {
+						err = organizeFile(config, entry)
+						library.move(approvedPath, entry.Path)
+						seen.moveSaved(approvedPath, entry.Path)
+					}
+				}
+			case "r":
+				err = removeWallpaper(pendingPath)
+				if err == nil {
+					library.remove(pendingPath)
+					// the ignore list keeps it from being saved again
+					seen.forgetSaved([]string{pendingPath})
+					err = addIgnored(name)
+				}
+			case "s":
+			case "q":
+				return
+			default:
+				continue
+			}
+			if err != nil {
+				fmt.Println(err)
+			}
+			break
+		}
+	}
+}
+
+// approvedPath returns where a pending wallpaper is moved when it is
+// approved, following OnExisting when a file with its name is already
+// in the output folder, or an empty path when it stays pending
+func approvedPath(config *config, library *libraryIndex, pendingPath string) string {
+	name := filepath.Base(pendingPath)
+	approvedPath := filepath.Join(config.OutputDir, name)
+	if _, err := os.Stat(approvedPath); err != nil {
+		return approvedPath
+	}
+	switch config.onExisting() {
+	case existingOverwrite:
+		return approvedPath
+	case existingOverwriteIfDifferent:
+		if checksum, err := fileChecksum(pendingPath); err == nil && differsFromSaved(library, approvedPath, checksum) {
+			return approvedPath
+		}
+	case existingRename:
+		return freePath(config.OutputDir, name)
+	}
+	return ""
+}
+
+// moveFile moves a file, copying it when the target is in another drive
+func moveFile(sourcePath string, targetPath string) error {
+	if err := os.Rename(sourcePath, targetPath); err == nil {
+		return nil
+	}
+	if err := copyFile(sourcePath, targetPath); err != nil {
+		return err
+	}
+	return os.Remove(sourcePath)
+}
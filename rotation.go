@@ -0,0 +1,117 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// libraryImage is a wallpaper saved in the output folder
+type libraryImage struct {
+	path    string
+	modTime time.Time
+}
+
+// libraryImages returns the wallpapers in a folder of the library
+// and its subfolders, leaving out the aside folders, from the
+// newest to the oldest
+func libraryImages(config *config, dir string) ([]libraryImage, error) {
+	var images []libraryImage
+	err := filepath.WalkDir(dir, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && filePath != dir && config.isAside(filePath) {
+			return filepath.SkipDir
+		}
+		if d.IsDir() || !isWallpaperFile(d.Name()) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		images = append(images, libraryImage{path: filePath, modTime: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(images, func(i, k int) bool {
+		return images[i].modTime.After(images[k].modTime)
+	})
+	return images, nil
+}
+
+// sortByScore orders the images from the highest to the lowest
+// score in the index, keeping the newest first on ties
+func sortByScore(images []libraryImage, library *libraryIndex) {
+	score := func(image libraryImage) float64 {
+		if entry := library.get(image.path); entry != nil {
+			return entry.Score
+		}
+		return 0
+	}
+	sort.SliceStable(images, func(i, k int) bool {
+		return score(images[i]) > score(images[k])
+	})
+}
+
+// refreshRotation fills the rotation folder with the newest
+// landscape wallpapers of the output folder, or the ones with
+// the highest score when RotationOrder is score
+//
+// Wallpapers that are no longer among the newest are removed from
+// the rotation folder so it can be used for a slideshow
+func (j *job) refreshRotation(library *libraryIndex) error {
+	rotationDir := j.config.RotationDir
+	if rotationDir == "" || j.config.RotationCount <= 0 {
+		return nil
+	}
+	if err := os.MkdirAll(rotationDir, 0755); err != nil {
+		return err
+	}
+	images, err := libraryImages(j.config, j.config.OutputDir)
+	if err != nil {
+		return err
+	}
+	if j.config.RotationOrder == "score" {
+		sortByScore(images, library)
+	}
+	selected := make(map[string]bool)
+	for _, image := range images {
+		if len(selected) == j.config.RotationCount {
+			break
+		}
+		width, height, err := imageSize(image.path)
+		if err != nil || width <= height {
+			continue
+		}
+		name := filepath.Base(image.path)
+		selected[name] = true
+		targetPath := filepath.Join(rotationDir, name)
+		if _, err := os.Stat(targetPath); err == nil {
+			continue
+		}
+		if err := copyFile(image.path, targetPath); err != nil {
+			j.logger.Errorln(err)
+		}
+	}
+
+	entries, err := os.ReadDir(rotationDir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || selected[entry.Name()] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(rotationDir, entry.Name())); err != nil {
+			j.logger.Errorln(err)
+		}
+	}
+	j.logger.Printf("rotation folder %s has %d wallpapers\n", rotationDir, len(selected))
+	return nil
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"strings"
+
+	_ "golang.org/x/image/webp"
+)
+
+// jpegQuality is the quality used when a wallpaper has to be re-encoded
+const jpegQuality = 95
+
+// saveWallpaper saves a wallpaper from the source folder to the target path
+//
+// The file is copied byte by byte unless it needs processing. Images
+// are converted to the format of outputFormat, HEIC, AVIF and WebP
+// ones by ConvertCommand since they can't be encoded here. Images
+// with an EXIF orientation are rotated upright when FixOrientation is
+// true, which re-encodes them without the orientation tag. With
+// StripMetadata the copies leave out the metadata, and images with an
+// EXIF orientation are rotated upright anyway since their tag is lost.
+// JPEG images are re-encoded to take less space when JPEGQuality or
+// TargetFileSizeKB are set, and images are resized to OutputSize when
+// set
+func saveWallpaper(config *config, sourcePath string, targetPath string) error {
+	format, err := imageFormat(sourcePath)
+	if err != nil {
+		return err
+	}
+	output := config.outputFormat(format)
+	if output != format && (format == "heic" || format == "avif" || output == "webp") {
+		_, err := runExternal(config.ConvertCommand, sourcePath, targetPath)
+		return err
+	}
+	if output == format && (format == "heic" || format == "avif" || format == "webp") {
+		return config.copyOriginal(sourcePath, targetPath)
+	}
+	resize := config.resizesToOutputSize(sourcePath)
+	orientation := 1
+	// converted and resized images lose their orientation tag like stripped ones
+	if config.FixOrientation || config.StripMetadata || resize || output != format {
+		orientation = imageOrientation(sourcePath)
+	}
+	reencode := output == "jpeg" && config.reencodesJPEG(sourcePath)
+	if output == format && orientation == 1 && !reencode && !resize {
+		return config.copyOriginal(sourcePath, targetPath)
+	}
+
+	img, err := decodeImage(sourcePath)
+	if err != nil {
+		return err
+	}
+	img = config.fitOutputSize(orientImage(img, orientation))
+	if output == "png" {
+		return config.encodePNG(img, targetPath)
+	}
+	if err := config.encodeWallpaper(img, targetPath); err != nil {
+		return err
+	}
+	// a source that was already smaller is kept as it is
+	if output == format && orientation == 1 && !resize && fileSize(targetPath) >= fileSize(sourcePath) {
+		return config.copyOriginal(sourcePath, targetPath)
+	}
+	return nil
+}
+
+// outputFormat returns the format an image of the given format is
+// saved in: OutputFormat, or JPEG for WebP images when WebPToJPEG is
+// true and for HEIC and AVIF ones when ConvertCommand is set
+//
+// Images that would need ConvertCommand when it isn't set keep their format
+func (c *config) outputFormat(format string) string {
+	wanted := strings.ToLower(strings.TrimSpace(c.OutputFormat))
+	if wanted == "jpg" {
+		wanted = "jpeg"
+	}
+	if wanted == "" {
+		switch {
+		case format == "webp" && c.WebPToJPEG:
+			return "jpeg"
+		case (format == "heic" || format == "avif") && c.ConvertCommand != "":
+			return "jpeg"
+		}
+		return format
+	}
+	if wanted != format && (format == "heic" || format == "avif" || wanted == "webp") && c.ConvertCommand == "" {
+		return format
+	}
+	return wanted
+}
+
+// pngCompressionLevels are the values of PNGCompression
+var pngCompressionLevels = map[string]png.CompressionLevel{
+	"default": png.DefaultCompression,
+	"fast":    png.BestSpeed,
+	"best":    png.BestCompression,
+	"none":    png.NoCompression,
+}
+
+// encodePNG writes an image to a PNG file at PNGCompression
+func (c *config) encodePNG(img image.Image, targetPath string) error {
+	targetFile, err := os.Create(targetPath)
+	if err != nil {
+		return fmt.Errorf("couldn't create file %s", targetPath)
+	}
+	defer targetFile.Close()
+	encoder := png.Encoder{CompressionLevel: pngCompressionLevels[strings.ToLower(strings.TrimSpace(c.PNGCompression))]}
+	if err := encoder.Encode(targetFile, img); err != nil {
+		return fmt.Errorf("couldn't encode %s", targetPath)
+	}
+	return nil
+}
+
+// copyOriginal copies an image without processing it, but
+// leaving out its metadata when StripMetadata is true
+func (c *config) copyOriginal(sourcePath string, targetPath string) error {
+	if c.StripMetadata {
+		return copyWithoutMetadata(sourcePath, targetPath)
+	}
+	return copyFile(sourcePath, targetPath)
+}
+
+// minimumJPEGQuality is the lowest quality JPEG images are
+// re-encoded at to bring them under TargetFileSizeKB
+const minimumJPEGQuality = 40
+
+// reencodesJPEG tells whether a JPEG image is re-encoded when saved
+// to take less space, instead of copied byte for byte
+func (c *config) reencodesJPEG(sourcePath string) bool {
+	if c.JPEGQuality > 0 {
+		return true
+	}
+	return c.TargetFileSizeKB > 0 && fileSize(sourcePath) > int64(c.TargetFileSizeKB)*1024
+}
+
+// encodeWallpaper writes an image to a JPEG file at JPEGQuality,
+// lowering it in steps until the file is under TargetFileSizeKB
+func (c *config) encodeWallpaper(img image.Image, targetPath string) error {
+	quality := jpegQuality
+	if c.JPEGQuality > 0 {
+		quality = c.JPEGQuality
+	}
+	for {
+		if err := encodeJPEGQuality(img, targetPath, quality); err != nil {
+			return err
+		}
+		if c.TargetFileSizeKB <= 0 || quality <= minimumJPEGQuality ||
+			fileSize(targetPath) <= int64(c.TargetFileSizeKB)*1024 {
+			return nil
+		}
+		quality = max(quality-5, minimumJPEGQuality)
+	}
+}
+
+// finishSaved applies to a saved wallpaper the steps that need its
+// index entry, the watermark, the caption and the EXIF and IPTC metadata, and
+// updates the checksum of the entry when they rewrote it
+func (j *job) finishSaved(sourcePath string, entry *indexEntry, checksum []byte) {
+	entry.SourceChecksum = hex.EncodeToString(checksum)
+	// the watermark and caption go first, since they encode the image again
+	changed := j.stampTexts(sourcePath, entry)
+	changed = j.embedMetadata(entry.Path, checksum) || changed
+	changed = j.embedIPTC(entry) || changed
+	if !changed {
+		return
+	}
+	if savedChecksum, err := fileChecksum(entry.Path); err == nil {
+		entry.Checksum = hex.EncodeToString(savedChecksum)
+	}
+}
+
+// countSpaceSaved adds to the stats of the job how much smaller
+// a saved wallpaper is than its source when JPEGs are re-encoded
+func (j *job) countSpaceSaved(sourcePath string, targetPath string) {
+	if j.config.JPEGQuality <= 0 && j.config.TargetFileSizeKB <= 0 {
+		return
+	}
+	if saved := fileSize(sourcePath) - fileSize(targetPath); saved > 0 {
+		j.stats.SpaceSaved += saved
+	}
+}
+
+// fileSize returns the size of a file in bytes, or 0 if it can't be read
+func fileSize(filePath string) int64 {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// decodeImage decodes the image in the given path
+func decodeImage(imagePath string) (image.Image, error) {
+	imageFile, err := os.Open(imagePath)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't open %s", imagePath)
+	}
+	defer imageFile.Close()
+	img, _, err := image.Decode(imageFile)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't decode %s", imagePath)
+	}
+	return img, nil
+}
+
+// decodeImageConfig decodes the dimensions of the image in the given path
+func decodeImageConfig(imagePath string) (image.Config, error) {
+	imageFile, err := os.Open(imagePath)
+	if err != nil {
+		return image.Config{}, fmt.Errorf("couldn't open %s", imagePath)
+	}
+	defer imageFile.Close()
+	imageConfig, _, err := image.DecodeConfig(imageFile)
+	if err != nil {
+		return image.Config{}, fmt.Errorf("couldn't decode %s", imagePath)
+	}
+	return imageConfig, nil
+}
+
+// encodeJPEG writes an image to a JPEG file
+func encodeJPEG(img image.Image, targetPath string) error {
+	return encodeJPEGQuality(img, targetPath, jpegQuality)
+}
+
+// encodeJPEGQuality writes an image to a JPEG file at a quality
+func encodeJPEGQuality(img image.Image, targetPath string, quality int) error {
+	targetFile, err := os.Create(targetPath)
+	if err != nil {
+		return fmt.Errorf("couldn't create file %s", targetPath)
+	}
+	defer targetFile.Close()
+	if err := jpeg.Encode(targetFile, img, &jpeg.Options{Quality: quality}); err != nil {
+		return fmt.Errorf("couldn't encode %s", targetPath)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+)
+
+// schedule installs or uninstalls the periodic run of the program
+// in the scheduler of the operating system
+func schedule(action string) {
+	var err error
+	switch action {
+	case "install":
+		err = installSchedule()
+	case "uninstall":
+		err = uninstallSchedule()
+	default:
+		fmt.Println(tr("Unknown schedule action %s, use install or uninstall", action))
+		os.Exit(1)
+	}
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if action == "install" {
+		fmt.Println(tr("schedule installed"))
+	} else {
+		fmt.Println(tr("schedule uninstalled"))
+	}
+}
+
+// scheduledCommand returns the executable and the arguments
+// that the scheduler runs
+func scheduledCommand() (string, []string) {
+	executable, err := os.Executable()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	var args []string
+	if portable {
+		args = append(args, "--portable")
+	}
+	return executable, args
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const launchdLabel = "com.github.liconaj.wspotsave"
+
+const launchdPlist = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>%s</string>
+	<key>ProgramArguments</key>
+	<array>
+%s	</array>
+	<key>StartCalendarInterval</key>
+	<dict>
+		<key>Hour</key>
+		<integer>12</integer>
+		<key>Minute</key>
+		<integer>0</integer>
+	</dict>
+	<key>RunAtLoad</key>
+	<true/>
+</dict>
+</plist>
+`
+
+// launchdPlistPath returns the path of the launch agent of the program
+func launchdPlistPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, "Library", "LaunchAgents", launchdLabel+".plist"), nil
+}
+
+// installSchedule writes a launchd agent that runs the program
+// daily and loads it
+func installSchedule() error {
+	plistPath, err := launchdPlistPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(plistPath), 0755); err != nil {
+		return err
+	}
+	executable, args := scheduledCommand()
+	var programArgs bytes.Buffer
+	for _, arg := range append([]string{executable}, args...) {
+		programArgs.WriteString("\t\t<string>")
+		xml.EscapeText(&programArgs, []byte(arg))
+		programArgs.WriteString("</string>\n")
+	}
+	plist := fmt.Sprintf(launchdPlist, launchdLabel, programArgs.String())
+	if err := os.WriteFile(plistPath, []byte(plist), 0644); err != nil {
+		return fmt.Errorf("couldn't write launch agent: %s", err)
+	}
+	runCommand("launchctl", "unload", plistPath)
+	return runCommand("launchctl", "load", "-w", plistPath)
+}
+
+// uninstallSchedule unloads and removes the launchd agent
+func uninstallSchedule() error {
+	plistPath, err := launchdPlistPath()
+	if err != nil {
+		return err
+	}
+	runCommand("launchctl", "unload", "-w", plistPath)
+	err = os.Remove(plistPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
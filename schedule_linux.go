@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const systemdService = `[Unit]
+Description=Save Windows Spotlight wallpapers
+
+[Service]
+Type=oneshot
+ExecStart=%s
+`
+
+const systemdTimer = `[Unit]
+Description=Save Windows Spotlight wallpapers daily
+
+[Timer]
+OnCalendar=daily
+OnBootSec=5min
+Persistent=true
+
+[Install]
+WantedBy=timers.target
+`
+
+// systemdUnitDir returns the folder of the systemd user units
+func systemdUnitDir() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "systemd", "user"), nil
+}
+
+// installSchedule writes a systemd user service and timer
+// that run the program daily and enables the timer
+func installSchedule() error {
+	unitDir, err := systemdUnitDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(unitDir, 0755); err != nil {
+		return err
+	}
+	executable, args := scheduledCommand()
+	execStart := []string{strconv.Quote(executable)}
+	for _, arg := range args {
+		execStart = append(execStart, strconv.Quote(arg))
+	}
+	service := fmt.Sprintf(systemdService, strings.Join(execStart, " "))
+	if err := os.WriteFile(filepath.Join(unitDir, "wspotsave.service"), []byte(service), 0644); err != nil {
+		return fmt.Errorf("couldn't write systemd service: %s", err)
+	}
+	if err := os.WriteFile(filepath.Join(unitDir, "wspotsave.timer"), []byte(systemdTimer), 0644); err != nil {
+		return fmt.Errorf("couldn't write systemd timer: %s", err)
+	}
+	if err := runCommand("systemctl", "--user", "daemon-reload"); err != nil {
+		return err
+	}
+	return runCommand("systemctl", "--user", "enable", "--now", "wspotsave.timer")
+}
+
+// uninstallSchedule disables the systemd timer and removes the units
+func uninstallSchedule() error {
+	unitDir, err := systemdUnitDir()
+	if err != nil {
+		return err
+	}
+	runCommand("systemctl", "--user", "disable", "--now", "wspotsave.timer")
+	for _, name := range []string{"wspotsave.timer", "wspotsave.service"} {
+		err := os.Remove(filepath.Join(unitDir, name))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return runCommand("systemctl", "--user", "daemon-reload")
+}
@@ -0,0 +1,18 @@
+//go:build !linux && !darwin && !windows
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// installSchedule is not supported on this platform
+func installSchedule() error {
+	return fmt.Errorf("scheduling is not supported on %s", runtime.GOOS)
+}
+
+// uninstallSchedule is not supported on this platform
+func uninstallSchedule() error {
+	return fmt.Errorf("scheduling is not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"strings"
+	"syscall"
+)
+
+const scheduledTaskName = "wspotsave"
+
+// installSchedule creates a Task Scheduler task that runs
+// the program daily
+func installSchedule() error {
+	executable, args := scheduledCommand()
+	taskCommand := []string{syscall.EscapeArg(executable)}
+	for _, arg := range args {
+		taskCommand = append(taskCommand, syscall.EscapeArg(arg))
+	}
+	return runCommand("schtasks", "/Create", "/F", "/SC", "DAILY", "/TN", scheduledTaskName, "/TR", strings.Join(taskCommand, " "))
+}
+
+// uninstallSchedule deletes the Task Scheduler task
+func uninstallSchedule() error {
+	return runCommand("schtasks", "/Delete", "/F", "/TN", scheduledTaskName)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"log"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// scoreImage returns the aesthetic score of an image given by the
+// ScoreCommand of the configuration
+//
+// The command receives the path of the image as last argument and
+// must print a number, higher is better. It can run any local model,
+// for instance a NIMA model with ONNX Runtime
+func scoreImage(config *config, imagePath string) (float64, error) {
+	output, err := runExternal(config.ScoreCommand, imagePath)
+	if err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(output)
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("score command printed nothing for %s", imagePath)
+	}
+	score, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, fmt.Errorf("couldn't read score of %s: %s", imagePath, err)
+	}
+	return score, nil
+}
+
+// indexEntry returns the index entry of a copied wallpaper, tagged
+// when the job has a ClassifyCommand
+func (j *job) indexEntry(filePath string, source string, img image.Image) *indexEntry {
+	entry := newIndexEntry(filePath, source, img)
+	if j.config.ClassifyCommand != "" {
+		labels, err := classifyImage(j.config, filePath)
+		if err != nil {
+			j.logger.Errorln(err)
+		} else {
+			j.config.tagEntry(entry, labels)
+		}
+	}
+	return entry
+}
+
+// sourceScore returns the score of a new image when the job has a
+// ScoreCommand, and whether it was scored. Images are scored once,
+// before they are saved, to compare the score with MinScore and
+// then record it in the index
+func (j *job) sourceScore(imagePath string) (float64, bool) {
+	if j.config.ScoreCommand == "" {
+		return 0, false
+	}
+	score, err := scoreImage(j.config, imagePath)
+	if err != nil {
+		j.logger.Errorln(err)
+		return 0, false
+	}
+	j.logger.Debugf("%s scored %.2f (minimum %.2f)", filepath.Base(imagePath), score, j.config.MinScore)
+	return score, true
+}
+
+// scoresTooLow tells whether a score is under the MinScore of the
+// configuration, so only the best images are saved
+func (j *job) scoresTooLow(score float64) bool {
+	return j.config.MinScore > 0 && score < j.config.MinScore
+}
+
+// rescores tells whether an image skipped for its score is considered
+// again, because MinScore was lowered to its score or images are no
+// longer skipped for their score
+func (j *job) rescores(asset *seenAsset) bool {
+	return asset.Decision == seenLowScore && (j.config.ScoreCommand == "" || !j.scoresTooLow(asset.Score))
+}
+
+// scoreLibrary scores the wallpapers in the output folder that
+// don't have a score yet and adds them to the index
+func scoreLibrary() {
+	config := loadConfig()
+	if config.ScoreCommand == "" {
+		log.Fatalln("ScoreCommand is not set in the configuration")
+	}
+	library, err := loadIndex()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	images, err := libraryImages(config, config.OutputDir)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	scored := 0
+	for _, image := range images {
+		entry := library.get(image.path)
+		if entry == nil {
+			entry = newIndexEntry(image.path, "", nil)
+			entry.Saved = image.modTime
+		}
+		if entry.Score != 0 {
+			continue
+		}
+		score, err := scoreImage(config, image.path)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		entry.Score = score
+		library.put(entry)
+		scored++
+	}
+	if err := library.save(); err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Println(tr("scored %d wallpapers", scored))
+}
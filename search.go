@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// searchText returns the text of an entry that searches look in
+func searchText(entry *indexEntry) string {
+	fields := []string{entry.Title, entry.Description, entry.Copyright, filepath.Base(entry.Path), entry.Source}
+	fields = append(fields, entry.Tags...)
+	return strings.ToLower(strings.Join(fields, " "))
+}
+
+// searchLibrary prints the wallpapers whose title, description,
+// copyright, tags or file name contain all the words of the query
+// and that pass all its filters, like orientation=portrait
+func searchLibrary(args []string) {
+	flags := newFlagSet("search")
+	flags.Parse(args)
+	var words []string
+	var filters []*searchFilter
+	for _, word := range strings.Fields(strings.ToLower(strings.Join(flags.Args(), " "))) {
+		if !isSearchFilter(word) {
+			words = append(words, word)
+			continue
+		}
+		filter, err := parseSearchFilter(word)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		filters = append(filters, filter)
+	}
+	if len(words) == 0 && len(filters) == 0 {
+		fmt.Println(tr("Usage: wspotsave search <words or filters>"))
+		os.Exit(1)
+	}
+	library, err := loadIndex()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	found := 0
+	for _, entry := range library.sorted(func(a, b *indexEntry) bool { return a.Saved.After(b.Saved) }) {
+		text := searchText(entry)
+		matches := true
+		for _, word := range words {
+			if !strings.Contains(text, word) {
+				matches = false
+				break
+			}
+		}
+		for _, filter := range filters {
+			matches = matches && filter.match(entry)
+		}
+		if !matches {
+			continue
+		}
+		found++
+		if err := printThumbnail(entry.Path); err != nil {
+			fmt.Println(err)
+		}
+		fmt.Println(entry.Path)
+		if entry.Title != "" {
+			fmt.Printf("  %s\n", entry.Title)
+		}
+		if entry.Description != "" {
+			fmt.Printf("  %s\n", entry.Description)
+		}
+	}
+	if found == 0 {
+		fmt.Println(tr("no wallpapers found"))
+	}
+}
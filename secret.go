@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"reflect"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// secretPrefix marks a configuration value that is the name of a
+// secret kept by the system, like secret:nas, instead of the password
+// or token itself
+const secretPrefix = "secret:"
+
+// secretTargetPrefix is the prefix of the names the secrets are
+// stored under, so they are told apart from other programs' ones
+const secretTargetPrefix = "wspotsave:"
+
+// secretKeys are the configuration keys whose values may refer to a
+// secret, the ones that can hold a token: the address statistics are
+// sent to and the external commands, which may call online services
+var secretKeys = []string{
+	"TelemetryURL",
+	"ScoreCommand",
+	"ClassifyCommand",
+	"DetectPeopleCommand",
+	"DetectTextCommand",
+	"ConvertCommand",
+	"NameCommand",
+}
+
+// errSecretNotFound is returned when a secret isn't stored
+var errSecretNotFound = errors.New("the secret isn't stored")
+
+// secretName returns the name of the secret that a configuration
+// value refers to, and whether it refers to one
+func secretName(value string) (string, bool) {
+	name, ok := strings.CutPrefix(strings.TrimSpace(value), secretPrefix)
+	return strings.TrimSpace(name), ok
+}
+
+// resolveSecret returns a configuration value with the secret it
+// refers to, if any, read from the system's credential store
+func resolveSecret(value string) (string, error) {
+	name, ok := secretName(value)
+	if !ok {
+		return value, nil
+	}
+	secret, err := readSecret(name)
+	if err != nil {
+		return "", fmt.Errorf("couldn't read the secret %s: %s", name, err)
+	}
+	return secret, nil
+}
+
+// resolvedSecret is a configuration value read from a secret
+type resolvedSecret struct {
+	name  string
+	value string
+}
+
+// resolveSecrets replaces the values of the configuration that refer
+// to a secret with the secret, remembering which ones so they aren't
+// shown
+//
+// Secrets that can't be read are left as they are, validating the
+// configuration reports them
+func (c *config) resolveSecrets() {
+	resolved := make(map[string]resolvedSecret)
+	maps.Copy(resolved, c.secrets)
+	fields := reflect.ValueOf(c).Elem()
+	for _, key := range secretKeys {
+		field := fields.FieldByName(key)
+		name, ok := secretName(field.String())
+		if !ok {
+			continue
+		}
+		if secret, err := readSecret(name); err == nil {
+			field.SetString(secret)
+			resolved[key] = resolvedSecret{name, secret}
+		}
+	}
+	c.secrets = resolved
+}
+
+// hideSecrets sets the keys of a section whose values were read
+// from a secret back to the name of the secret
+func (c *config) hideSecrets(section *ini.Section) {
+	for key, secret := range c.secrets {
+		if section.Key(key).Value() == secret.value {
+			section.Key(key).SetValue(secretPrefix + secret.name)
+		}
+	}
+}
+
+// secretCommand stores or deletes a secret to use in the configuration
+func secretCommand(args []string) {
+	name := args[1]
+	switch args[0] {
+	case "set":
+		fmt.Print(tr("value of the secret %s: ", name))
+		restore := hideInput(os.Stdin)
+		value, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		restore()
+		if err != nil && value == "" {
+			log.Fatalln(err)
+		}
+		if err := storeSecret(name, strings.TrimRight(value, "\r\n")); err != nil {
+			log.Fatalln(err)
+		}
+		fmt.Println(tr("secret %s stored, use it in the configuration as %s%s", name, secretPrefix, name))
+	case "delete":
+		if err := deleteSecret(name); err != nil {
+			log.Fatalln(err)
+		}
+		fmt.Println(tr("secret %s deleted", name))
+	default:
+		c, _ := findCommand("secret")
+		fmt.Println(tr("Usage: %s", commandUsage(c)))
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,23 @@
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// storeSecret is not supported on this platform
+func storeSecret(name string, value string) error {
+	return fmt.Errorf("storing secrets is not supported on %s", runtime.GOOS)
+}
+
+// readSecret is not supported on this platform
+func readSecret(name string) (string, error) {
+	return "", fmt.Errorf("reading secrets is not supported on %s", runtime.GOOS)
+}
+
+// deleteSecret is not supported on this platform
+func deleteSecret(name string) error {
+	return fmt.Errorf("deleting secrets is not supported on %s", runtime.GOOS)
+}
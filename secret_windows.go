@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+const (
+	credTypeGeneric         = 1
+	credPersistLocalMachine = 2
+)
+
+var (
+	procCredWriteW  = windows.NewLazySystemDLL("advapi32.dll").NewProc("CredWriteW")
+	procCredReadW   = windows.NewLazySystemDLL("advapi32.dll").NewProc("CredReadW")
+	procCredDeleteW = windows.NewLazySystemDLL("advapi32.dll").NewProc("CredDeleteW")
+	procCredFree    = windows.NewLazySystemDLL("advapi32.dll").NewProc("CredFree")
+)
+
+// credential is the CREDENTIALW structure
+type credential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        windows.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+// storeSecret saves a secret in the Windows Credential Manager,
+// which encrypts it for the current user with DPAPI
+func storeSecret(name string, value string) error {
+	target, err := windows.UTF16PtrFromString(secretTargetPrefix + name)
+	if err != nil {
+		return err
+	}
+	userName, err := windows.UTF16PtrFromString(name)
+	if err != nil {
+		return err
+	}
+	cred := credential{
+		Type:               credTypeGeneric,
+		TargetName:         target,
+		CredentialBlobSize: uint32(len(value)),
+		Persist:            credPersistLocalMachine,
+		UserName:           userName,
+	}
+	if len(value) > 0 {
+		blob := []byte(value)
+		cred.CredentialBlob = &blob[0]
+	}
+	if r, _, err := procCredWriteW.Call(uintptr(unsafe.Pointer(&cred)), 0); r == 0 {
+		return err
+	}
+	return nil
+}
+
+// readSecret returns a secret saved in the Windows Credential Manager
+func readSecret(name string) (string, error) {
+	target, err := windows.UTF16PtrFromString(secretTargetPrefix + name)
+	if err != nil {
+		return "", err
+	}
+	var cred *credential
+	r, _, err := procCredReadW.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0, uintptr(unsafe.Pointer(&cred)))
+	if r == 0 {
+		if errors.Is(err, windows.ERROR_NOT_FOUND) {
+			return "", errSecretNotFound
+		}
+		return "", err
+	}
+	defer procCredFree.Call(uintptr(unsafe.Pointer(cred)))
+	if cred.CredentialBlobSize == 0 {
+		return "", nil
+	}
+	return string(unsafe.Slice(cred.CredentialBlob, cred.CredentialBlobSize)), nil
+}
+
+// deleteSecret removes a secret from the Windows Credential Manager
+func deleteSecret(name string) error {
+	target, err := windows.UTF16PtrFromString(secretTargetPrefix + name)
+	if err != nil {
+		return err
+	}
+	if r, _, err := procCredDeleteW.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0); r == 0 {
+		if errors.Is(err, windows.ERROR_NOT_FOUND) {
+			return errSecretNotFound
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// decisions about a source asset
+const (
+	seenSaved     = "saved"
+	seenSmall     = "small"
+	seenPeople    = "people"
+	seenExisting  = "existing"
+	seenDuplicate = "duplicate"
+	seenRendition = "rendition"
+	seenTheme     = "theme"
+	seenText      = "text"
+	seenLowScore  = "score"
+)
+
+// seenAsset records what was decided about a source asset
+type seenAsset struct {
+	Decision string
+	Path     string  `json:",omitempty"`
+	Score    float64 `json:",omitempty"`
+	Seen     time.Time
+}
+
+// seenAssets keeps every source asset evaluated by each job, by the
+// SHA-256 of its content, so the same asset is never decoded or
+// considered again whatever is left in the output folder
+//
+// It is safe to use by several jobs at the same time
+type seenAssets struct {
+	mu   sync.Mutex
+	Jobs map[string]map[string]*seenAsset
+}
+
+// seenPath returns the path of the file of seen assets
+func seenPath() string {
+	return filepath.Join(appDir(), "seen.json")
+}
+
+// loadSeen reads the seen assets, which are none if the file doesn't exist
+func loadSeen() (*seenAssets, error) {
+	seen := &seenAssets{Jobs: make(map[string]map[string]*seenAsset)}
+	data, err := os.ReadFile(seenPath())
+	if os.IsNotExist(err) {
+		return seen, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, seen); err != nil {
+		return nil, err
+	}
+	if seen.Jobs == nil {
+		seen.Jobs = make(map[string]map[string]*seenAsset)
+	}
+	return seen, nil
+}
+
+// save writes the file of seen assets
+func (s *seenAssets) save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(seenPath(), data, 0644)
+}
+
+// get returns what a job decided about an asset, or nil if it wasn't seen
+func (s *seenAssets) get(jobName string, checksum []byte) *seenAsset {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Jobs[jobName][hex.EncodeToString(checksum)]
+}
+
+// add records what a job decided about an asset
+func (s *seenAssets) add(jobName string, checksum []byte, decision string, savedPath string) {
+	s.put(jobName, checksum, &seenAsset{Decision: decision, Path: savedPath, Seen: time.Now()})
+}
+
+// addLowScore records that a job skipped an asset for its score
+func (s *seenAssets) addLowScore(jobName string, checksum []byte, score float64) {
+	s.put(jobName, checksum, &seenAsset{Decision: seenLowScore, Score: score, Seen: time.Now()})
+}
+
+// put records an asset seen by a job
+func (s *seenAssets) put(jobName string, checksum []byte, asset *seenAsset) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Jobs[jobName] == nil {
+		s.Jobs[jobName] = make(map[string]*seenAsset)
+	}
+	s.Jobs[jobName][hex.EncodeToString(checksum)] = asset
+}
+
+// moveSaved points the assets saved to a path to the path
+// their file was moved to
+func (s *seenAssets) moveSaved(oldPath string, newPath string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, assets := range s.Jobs {
+		for _, asset := range assets {
+			if asset.Path == oldPath {
+				asset.Path = newPath
+			}
+		}
+	}
+}
+
+// forgetSaved forgets the assets saved to the given paths,
+// so they are considered again after their files are removed
+func (s *seenAssets) forgetSaved(paths []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	removed := make(map[string]bool)
+	for _, filePath := range paths {
+		removed[filePath] = true
+	}
+	for _, assets := range s.Jobs {
+		for checksum, asset := range assets {
+			if asset.Path != "" && removed[asset.Path] {
+				delete(assets, checksum)
+			}
+		}
+	}
+}
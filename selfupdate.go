@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+const latestReleaseURL = "https://api.github.com/repos/liconaj/wspotsave/releases/latest"
+
+// checksumsAssetName is the release asset listing the SHA-256
+// checksum of every other asset, in the format of sha256sum
+const checksumsAssetName = "checksums.txt"
+
+var httpClient = &http.Client{Timeout: 60 * time.Second}
+
+type release struct {
+	TagName string         `json:"tag_name"`
+	Assets  []releaseAsset `json:"assets"`
+}
+
+type releaseAsset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+}
+
+// latestRelease returns the latest published release on GitHub
+func latestRelease() (*release, error) {
+	response, err := httpClient.Get(latestReleaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't check latest release: %s", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("couldn't check latest release: %s", response.Status)
+	}
+	latest := new(release)
+	if err := json.NewDecoder(response.Body).Decode(latest); err != nil {
+		return nil, fmt.Errorf("couldn't read latest release: %s", err)
+	}
+	return latest, nil
+}
+
+// asset returns the release asset with the given name
+func (r *release) asset(name string) (*releaseAsset, error) {
+	for i := range r.Assets {
+		if r.Assets[i].Name == name {
+			return &r.Assets[i], nil
+		}
+	}
+	return nil, fmt.Errorf("release %s has no %s asset", r.TagName, name)
+}
+
+// version returns the release version without the v prefix
+func (r *release) version() string {
+	return strings.TrimPrefix(r.TagName, "v")
+}
+
+// executableAssetName returns the name of the release asset
+// built for this operating system and architecture
+func executableAssetName() string {
+	name := fmt.Sprintf("wspotsave_%s_%s", runtime.GOOS, runtime.GOARCH)
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// selfUpdate replaces the running executable with the one
+// of the latest release after verifying its checksum
+//
+// The running executable can't be overwritten on Windows, so it is
+// renamed to .old first and removed the next time the program starts
+func selfUpdate() {
+	if _, ok := parseVersion(version); !ok {
+		log.Fatalf("wspotsave %s is a development build and can't be updated\n", version)
+	}
+	latest, err := latestRelease()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if !isNewerVersion(latest.version(), version) {
+		fmt.Println(tr("wspotsave %s is already the latest version", version))
+		return
+	}
+	asset, err := latest.asset(executableAssetName())
+	if err != nil {
+		log.Fatalln(err)
+	}
+	checksums, err := latest.asset(checksumsAssetName)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	expectedSum, err := releaseChecksum(checksums.URL, asset.Name)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	executable, err = filepath.EvalSymlinks(executable)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	newPath := executable + ".new"
+	fmt.Println(tr("downloading wspotsave %s", latest.version()))
+	actualSum, err := downloadFile(asset.URL, newPath)
+	if err != nil {
+		os.Remove(newPath)
+		log.Fatalln(err)
+	}
+	if actualSum != expectedSum {
+		os.Remove(newPath)
+		log.Fatalf("checksum of %s doesn't match, expected %s but got %s\n", asset.Name, expectedSum, actualSum)
+	}
+
+	oldPath := executable + ".old"
+	os.Remove(oldPath)
+	if err := os.Rename(executable, oldPath); err != nil {
+		os.Remove(newPath)
+		log.Fatalf("couldn't move %s: %s\n", executable, err)
+	}
+	if err := os.Rename(newPath, executable); err != nil {
+		os.Rename(oldPath, executable)
+		log.Fatalf("couldn't replace %s: %s\n", executable, err)
+	}
+	removeOldExecutable()
+	fmt.Println(tr("updated wspotsave from %s to %s", version, latest.version()))
+}
+
+// releaseChecksum returns the expected SHA-256 checksum of
+// an asset from the checksums file of a release
+func releaseChecksum(checksumsURL string, assetName string) (string, error) {
+	response, err := httpClient.Get(checksumsURL)
+	if err != nil {
+		return "", fmt.Errorf("couldn't download checksums: %s", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("couldn't download checksums: %s", response.Status)
+	}
+	scanner := bufio.NewScanner(response.Body)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == assetName {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", fmt.Errorf("couldn't find checksum of %s", assetName)
+}
+
+// downloadFile saves the content of an URL to a file with
+// executable permissions and returns its SHA-256 checksum
+func downloadFile(url string, targetPath string) (string, error) {
+	response, err := httpClient.Get(url)
+	if err != nil {
+		return "", fmt.Errorf("couldn't download %s: %s", url, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("couldn't download %s: %s", url, response.Status)
+	}
+	targetFile, err := os.OpenFile(targetPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0755)
+	if err != nil {
+		return "", fmt.Errorf("couldn't create file %s", targetPath)
+	}
+	defer targetFile.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(targetFile, hash), response.Body); err != nil {
+		return "", fmt.Errorf("couldn't download %s: %s", url, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// removeOldExecutable removes the executable left by a previous update
+func removeOldExecutable() {
+	executable, err := os.Executable()
+	if err != nil {
+		return
+	}
+	os.Remove(executable + ".old")
+}
@@ -0,0 +1,34 @@
+package main
+
+import "image"
+
+// sharpnessSamples is the number of pixels per side where the
+// Laplacian is measured to tell how sharp an image is
+const sharpnessSamples = 300
+
+// imageSharpness returns the variance of the Laplacian of the luma of
+// an image, which is low when it is blurry since its edges are soft
+//
+// The Laplacian is measured at full resolution on a grid of pixels,
+// so soft images that were upscaled to a large size are caught too
+func imageSharpness(img image.Image) float64 {
+	bounds := img.Bounds()
+	if bounds.Dx() < 3 || bounds.Dy() < 3 {
+		return 0
+	}
+	columns, rows := min(sharpnessSamples, bounds.Dx()-2), min(sharpnessSamples, bounds.Dy()-2)
+	var sum, squares float64
+	for sy := 0; sy < rows; sy++ {
+		y := bounds.Min.Y + 1 + sy*(bounds.Dy()-2)/rows
+		for sx := 0; sx < columns; sx++ {
+			x := bounds.Min.X + 1 + sx*(bounds.Dx()-2)/columns
+			laplacian := brightness(img, x-1, y) + brightness(img, x+1, y) +
+				brightness(img, x, y-1) + brightness(img, x, y+1) - 4*brightness(img, x, y)
+			sum += laplacian
+			squares += laplacian * laplacian
+		}
+	}
+	samples := float64(columns * rows)
+	mean := sum / samples
+	return squares/samples - mean*mean
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// showWallpaper opens a saved wallpaper in the default image viewer,
+// either the latest one or the one with the given name
+func showWallpaper(args []string) {
+	flags := newFlagSet("show")
+	printPath := flags.Bool("print-path", false, "print the path of the wallpaper instead of opening it")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(1)
+	}
+	config := loadConfig()
+	library, err := loadIndex()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	files, err := libraryFiles(config, library)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	filePath, err := findWallpaper(files, library, flags.Arg(0))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if *printPath {
+		fmt.Println(filePath)
+		return
+	}
+	if err := openFile(filePath); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// findWallpaper returns the path of the latest saved wallpaper, or of the
+// one whose file name, with or without extension, or Spotlight name is name
+func findWallpaper(files []libraryFile, library *libraryIndex, name string) (string, error) {
+	if len(files) == 0 {
+		return "", fmt.Errorf("there are no saved wallpapers")
+	}
+	if name == "latest" {
+		latest := files[0]
+		for _, file := range files[1:] {
+			if file.saved.After(latest.saved) {
+				latest = file
+			}
+		}
+		return latest.path, nil
+	}
+	for _, file := range files {
+		base := filepath.Base(file.path)
+		if strings.EqualFold(base, name) || strings.EqualFold(strings.TrimSuffix(base, filepath.Ext(base)), name) {
+			return file.path, nil
+		}
+		if entry := library.get(file.path); entry != nil && entry.Source == name {
+			return file.path, nil
+		}
+	}
+	return "", fmt.Errorf("no saved wallpaper is named %s", name)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// sourceSectionPrefix is the prefix of the configuration sections
+// with the settings of a source folder, like [source.IrisService]
+const sourceSectionPrefix = "source."
+
+// sourceOverride is a section of settings for the source folders
+// whose path contains its Match key, or its name if not set
+type sourceOverride struct {
+	name    string
+	match   string
+	section *ini.Section
+}
+
+// loadSourceOverrides returns the [source.*] sections of the configuration
+func loadSourceOverrides(iniConfig *ini.File) []sourceOverride {
+	var overrides []sourceOverride
+	for _, section := range iniConfig.Sections() {
+		name, ok := strings.CutPrefix(section.Name(), sourceSectionPrefix)
+		if !ok {
+			continue
+		}
+		match := section.Key("Match").String()
+		if match == "" {
+			match = name
+		}
+		overrides = append(overrides, sourceOverride{name, strings.ToLower(match), section})
+	}
+	return overrides
+}
+
+// forSource returns the configuration for a source folder, with the
+// keys of the first [source.*] section that matches it, and the name
+// of the section, or the configuration itself if none matches
+//
+// Only filters and naming can change per source, the folders are
+// the ones of the job
+func (c *config) forSource(sourceDir string) (*config, string, error) {
+	for _, override := range c.sources {
+		if !strings.Contains(strings.ToLower(sourceDir), override.match) {
+			continue
+		}
+		sourceConfig := *c
+		if err := override.section.MapTo(&sourceConfig); err != nil {
+			return nil, "", fmt.Errorf("couldn't read source %s: %s", override.name, err)
+		}
+		sourceConfig.normalizeChoices()
+		sourceConfig.resolveSecrets()
+		sourceConfig.SourceDir, sourceConfig.OutputDir = c.SourceDir, c.OutputDir
+		sourceConfig.Staging, sourceConfig.PendingDir = c.Staging, c.PendingDir
+		sourceConfig.UltrawideDir, sourceConfig.LockScreenDir = c.UltrawideDir, c.LockScreenDir
+		return &sourceConfig, override.name, nil
+	}
+	return c, "", nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// knownSourcePatterns are the glob patterns, relative to the local
+// application data folder, where Windows variants keep Spotlight images
+//
+// The package suffix is matched with a wildcard because OEM and Insider
+// builds sometimes ship the packages with a different publisher id
+var knownSourcePatterns = []string{
+	filepath.Join("Packages", "Microsoft.Windows.ContentDeliveryManager*", "LocalState", "Assets"),
+	filepath.Join("Packages", "MicrosoftWindows.Client.CBS_*", "LocalCache", "Microsoft", "IrisService"),
+}
+
+// localAppDataDir returns the path of the local application data folder
+func localAppDataDir() string {
+	if dir := os.Getenv("LOCALAPPDATA"); dir != "" {
+		return dir
+	}
+	return filepath.Join(os.Getenv("USERPROFILE"), "AppData", "Local")
+}
+
+// knownSourceDirs returns the existing folders that match
+// any of the known Spotlight locations
+func knownSourceDirs() []string {
+	var dirs []string
+	seen := make(map[string]bool)
+	for _, pattern := range knownSourcePatterns {
+		matches, err := filepath.Glob(filepath.Join(localAppDataDir(), pattern))
+		if err != nil {
+			continue
+		}
+		for _, match := range matches {
+			if seen[match] || checkDirectory(match) != nil {
+				continue
+			}
+			seen[match] = true
+			dirs = append(dirs, match)
+		}
+	}
+	return dirs
+}
+
+// findSourceDirs returns the folders to look for wallpapers in
+//
+// The configured folder is used when it exists. Otherwise the known
+// Spotlight locations are probed and every folder found is used.
+// Each decision is written to the logger so it's clear which sources
+// were used
+func findSourceDirs(configuredDir string, logger *leveledLogger) ([]string, error) {
+	if configuredDir != "" {
+		err := checkDirectory(configuredDir)
+		if err == nil {
+			logger.Printf("using source %s\n", configuredDir)
+			warnIfEmpty(configuredDir, logger)
+			return []string{configuredDir}, nil
+		}
+		logger.Printf("%s, probing known Spotlight locations\n", err)
+	}
+	dirs := knownSourceDirs()
+	if len(dirs) == 0 {
+		return nil, fmt.Errorf("couldn't find any Spotlight folder in %s, Spotlight may not be available on this system", localAppDataDir())
+	}
+	for _, dir := range dirs {
+		logger.Printf("using source %s\n", dir)
+		warnIfEmpty(dir, logger)
+	}
+	return dirs, nil
+}
+
+// warnIfEmpty logs a warning when a source folder has no files,
+// which happens when Spotlight is disabled by policy
+func warnIfEmpty(dir string, logger *leveledLogger) {
+	entries, err := os.ReadDir(dir)
+	if err == nil && len(entries) == 0 {
+		logger.Printf("source %s is empty, Windows Spotlight may be disabled\n", dir)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/image/draw"
+)
+
+// spannedCategory is the folder inside the output folder where
+// composed wallpapers are saved when no output is given
+const spannedCategory = "Spanned"
+
+// spannedDir returns the folder for composed wallpapers
+func (c *config) spannedDir() string {
+	return filepath.Join(c.OutputDir, spannedCategory)
+}
+
+// spanWallpaper composes a wallpaper that spans several monitors
+//
+// Two images are placed side by side, each one filling half of the
+// combined size, the last one taking the pixel left over by an odd
+// width. A single image, usually a very large one, is cropped and
+// scaled to fill the whole size, which by default is the one of the
+// attached monitors side by side. With --apply the result is set
+// as the spanned desktop wallpaper
+func spanWallpaper(args []string) {
+	flags := newFlagSet("span")
+	size := flags.String("size", "", "combined size of the monitors, WIDTHxHEIGHT, the attached ones if empty")
+	output := flags.String("output", "", "path of the composed image, a file in the Spanned folder of OutputDir if empty")
+	apply := flags.Bool("apply", false, "set the composed image as the spanned wallpaper")
+	flags.Parse(args)
+	if flags.NArg() < 1 || flags.NArg() > 2 {
+		fmt.Println(tr("Usage: wspotsave span [--size WxH] [--output file] [--apply] <image> [<image>]"))
+		os.Exit(1)
+	}
+	var width, height int
+	var err error
+	if *size != "" {
+		width, height, err = parseSize(*size)
+	} else if width, height, err = combinedDisplaySize(); err != nil {
+		err = fmt.Errorf("couldn't query the monitors, pass their combined size with --size: %s", err)
+	}
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	config := loadConfig()
+	var images []image.Image
+	for _, imagePath := range flags.Args() {
+		if _, err := os.Stat(normalizePath(imagePath)); os.IsNotExist(err) {
+			imagePath = filepath.Join(config.OutputDir, imagePath)
+		}
+		img, err := decodeImage(normalizePath(imagePath))
+		if err != nil {
+			log.Fatalln(err)
+		}
+		images = append(images, img)
+	}
+
+	composed := image.NewRGBA(image.Rect(0, 0, width, height))
+	partWidth := width / len(images)
+	for i, img := range images {
+		left, right := i*partWidth, (i+1)*partWidth
+		if i == len(images)-1 {
+			right = width
+		}
+		part := coverResize(img, right-left, height)
+		draw.Draw(composed, image.Rect(left, 0, right, height), part, image.Point{}, draw.Src)
+	}
+
+	targetPath := normalizePath(*output)
+	if targetPath == "" {
+		if err := os.MkdirAll(config.spannedDir(), 0755); err != nil {
+			log.Fatalln(err)
+		}
+		targetPath = filepath.Join(config.spannedDir(), "spanned-"+time.Now().Format("20060102-150405")+".jpg")
+	}
+	if err := encodeJPEG(composed, targetPath); err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Println(tr("spanned wallpaper saved to %s", targetPath))
+	if *apply {
+		if err := setSpannedWallpaper(targetPath); err != nil {
+			log.Fatalln(err)
+		}
+		fmt.Println(tr("wallpaper set to %s", targetPath))
+	}
+}
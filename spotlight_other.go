@@ -0,0 +1,11 @@
+//go:build !windows
+
+package main
+
+import "fmt"
+
+// spotlightDisabledReason returns why Windows Spotlight doesn't download
+// images, which can only be known on Windows
+func spotlightDisabledReason() (string, error) {
+	return "", fmt.Errorf("Windows Spotlight settings are only available on Windows")
+}
@@ -0,0 +1,34 @@
+package main
+
+import "golang.org/x/sys/windows/registry"
+
+// spotlightDisabledReason returns why Windows Spotlight doesn't download
+// images, or an empty string when it is enabled
+func spotlightDisabledReason() (string, error) {
+	const policyPath = `Software\Policies\Microsoft\Windows\CloudContent`
+	for _, root := range []registry.Key{registry.LOCAL_MACHINE, registry.CURRENT_USER} {
+		if registryDword(root, policyPath, "DisableWindowsSpotlightFeatures") == 1 {
+			return tr("Windows Spotlight is turned off by a group policy"), nil
+		}
+	}
+	const settingsPath = `Software\Microsoft\Windows\CurrentVersion\ContentDeliveryManager`
+	if registryDword(registry.CURRENT_USER, settingsPath, "RotatingLockScreenEnabled") == 0 {
+		return tr("Windows Spotlight is not chosen as the lock screen background"), nil
+	}
+	return "", nil
+}
+
+// registryDword returns a DWORD value of the registry,
+// or -1 when it isn't set
+func registryDword(root registry.Key, path string, name string) int64 {
+	key, err := registry.OpenKey(root, path, registry.QUERY_VALUE)
+	if err != nil {
+		return -1
+	}
+	defer key.Close()
+	value, _, err := key.GetIntegerValue(name)
+	if err != nil {
+		return -1
+	}
+	return int64(value)
+}
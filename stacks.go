@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// stackLibrary groups the similar wallpapers of the index in stacks
+//
+// Two wallpapers are in the same stack when the distance between
+// their perceptual hashes is at most maxDistance, directly or through
+// other wallpapers of the stack. Wallpapers without similar ones get
+// no stack. It returns the stacks, each one ordered from the best
+// wallpaper, the highest score or else the largest, to the worst
+func stackLibrary(library *libraryIndex, maxDistance int) [][]*indexEntry {
+	entries := library.sorted(func(a, b *indexEntry) bool {
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		return a.Width*a.Height > b.Width*b.Height
+	})
+	parents := make([]int, len(entries))
+	for i := range parents {
+		parents[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parents[i] != i {
+			parents[i] = find(parents[i])
+		}
+		return parents[i]
+	}
+	for i := range entries {
+		for k := i + 1; k < len(entries); k++ {
+			if entries[i].Hash == 0 || entries[k].Hash == 0 {
+				continue
+			}
+			if hammingDistance(entries[i].Hash, entries[k].Hash) <= maxDistance {
+				parents[find(k)] = find(i)
+			}
+		}
+	}
+
+	groups := make(map[int][]*indexEntry)
+	var roots []int
+	for i, entry := range entries {
+		root := find(i)
+		if _, ok := groups[root]; !ok {
+			roots = append(roots, root)
+		}
+		groups[root] = append(groups[root], entry)
+	}
+	var stacks [][]*indexEntry
+	library.mu.Lock()
+	defer library.mu.Unlock()
+	for _, root := range roots {
+		group := groups[root]
+		stack := 0
+		if len(group) > 1 {
+			stacks = append(stacks, group)
+			stack = len(stacks)
+		}
+		for _, entry := range group {
+			entry.Stack = stack
+		}
+	}
+	return stacks
+}
+
+// hashLibrary computes the perceptual hash of the
+// indexed wallpapers that don't have one
+func hashLibrary(library *libraryIndex) {
+	for _, entry := range library.sorted(func(a, b *indexEntry) bool { return a.Path < b.Path }) {
+		if entry.Hash != 0 {
+			continue
+		}
+		img, err := decodeImage(entry.Path)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		library.mu.Lock()
+		entry.Hash = perceptualHash(img)
+		library.mu.Unlock()
+	}
+}
+
+// showStacks prints the stacks of similar wallpapers collapsed
+// to their best wallpaper
+//
+// With --keep-best every wallpaper of a stack except the best is removed
+func showStacks(args []string) {
+	flags := newFlagSet("stacks")
+	keepBest := flags.Bool("keep-best", false, "remove every wallpaper of a stack except the best")
+	flags.Parse(args)
+
+	config := loadConfig()
+	library, err := loadIndex()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	hashLibrary(library)
+	stacks := stackLibrary(library, config.StackDistance)
+	removed := 0
+	for _, stack := range stacks {
+		fmt.Println(tr("%s (+%d similar)", stack[0].Path, len(stack)-1))
+		for _, entry := range stack[1:] {
+			if !*keepBest {
+				fmt.Printf("    %s\n", filepath.Base(entry.Path))
+				continue
+			}
+			if err := removeWallpaper(entry.Path); err != nil && !os.IsNotExist(err) {
+				fmt.Println(err)
+				continue
+			}
+			library.remove(entry.Path)
+			removed++
+		}
+	}
+	if err := library.save(); err != nil {
+		log.Fatalln(err)
+	}
+	if *keepBest {
+		fmt.Println(tr("removed %d similar wallpapers", removed))
+	} else {
+		fmt.Println(tr("%d stacks of similar wallpapers", len(stacks)))
+	}
+}
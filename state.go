@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// runState is the state of the program written after every run, so
+// monitoring tools can report on it without running the program
+type runState struct {
+	Version    string
+	LastRun    time.Time
+	LastResult string
+	LastError  string `json:",omitempty"`
+	Scanned    int
+	Copied     int
+	Running    bool       `json:",omitempty"`
+	NextRun    *time.Time `json:",omitempty"`
+	DaemonPID  int        `json:",omitempty"`
+}
+
+// statePath returns the path of the state file
+func statePath() string {
+	return filepath.Join(appDir(), "state.json")
+}
+
+// newRunState returns the state after a run with the given result
+func newRunState(stats *runStats, runErr error) *runState {
+	state := &runState{Version: version, LastRun: time.Now(), LastResult: "ok"}
+	if stats != nil {
+		state.Scanned, state.Copied = stats.Scanned, stats.Copied
+	}
+	if runErr != nil {
+		state.LastResult = "failed"
+		state.LastError = runErr.Error()
+	}
+	return state
+}
+
+// loadRunState reads the state file, which is nil if it doesn't exist
+func loadRunState() (*runState, error) {
+	data, err := os.ReadFile(statePath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	state := new(runState)
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// parseSince returns the time from which Spotlight files are considered,
+// given as a date, an age like 7d or last-run for the end of the last run
+//
+// It is the zero time when all files are considered
+func parseSince(since string) (time.Time, error) {
+	switch {
+	case since == "":
+		return time.Time{}, nil
+	case since == "last-run":
+		state, err := loadRunState()
+		if err != nil || state == nil {
+			return time.Time{}, err
+		}
+		return state.LastRun, nil
+	case strings.Contains(since, "-"):
+		return parseDate(since)
+	}
+	age, err := parseAge(since)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s is not a date like 2024-06-01, an age like 7d or last-run", since)
+	}
+	return time.Now().Add(-age), nil
+}
+
+// save writes the state file, replacing it at once so readers
+// never see it half written
+func (s *runState) save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tempPath := statePath() + ".tmp"
+	if err := os.WriteFile(tempPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tempPath, statePath())
+}
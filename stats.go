@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// libraryFile is a saved wallpaper counted by the stats command
+type libraryFile struct {
+	path   string
+	size   int64
+	width  int
+	height int
+	saved  time.Time
+}
+
+// isWallpaperFile tells whether a file name looks like a saved wallpaper
+func isWallpaperFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".jpg", ".jpeg", ".png", ".webp", ".heic", ".avif":
+		return true
+	}
+	return false
+}
+
+// asideDirs returns the folders with images that aren't part of the
+// library, the pending, rotation, lock screen, promotions and spanned folders
+func (c *config) asideDirs() []string {
+	return []string{c.pendingDir(), c.RotationDir, c.LockScreenDir, c.promotionsDir(), c.spannedDir()}
+}
+
+// isAside tells whether a path is in one of the aside folders
+func (c *config) isAside(path string) bool {
+	return slices.ContainsFunc(c.asideDirs(), func(dir string) bool { return isInsideDir(path, dir) })
+}
+
+// libraryFiles returns the wallpapers in the output folder and its
+// subfolders, plus the indexed ones saved elsewhere, leaving out
+// the aside folders
+//
+// The resolution and date come from the index when the file is
+// indexed, otherwise they are read from the file
+func libraryFiles(config *config, library *libraryIndex) ([]libraryFile, error) {
+	var files []libraryFile
+	counted := make(map[string]bool)
+	add := func(filePath string, info fs.FileInfo) {
+		file := libraryFile{path: filePath, size: info.Size(), saved: info.ModTime()}
+		if entry := library.get(filePath); entry != nil {
+			file.width, file.height, file.saved = entry.Width, entry.Height, entry.Saved
+		} else {
+			file.width, file.height, _ = imageSize(filePath)
+		}
+		counted[filePath] = true
+		files = append(files, file)
+	}
+	err := filepath.WalkDir(config.OutputDir, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && filePath != config.OutputDir && config.isAside(filePath) {
+			return filepath.SkipDir
+		}
+		if d.IsDir() || !isWallpaperFile(d.Name()) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		add(filePath, info)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range library.sorted(func(a, b *indexEntry) bool { return a.Path < b.Path }) {
+		if counted[entry.Path] || config.isAside(entry.Path) {
+			continue
+		}
+		if info, err := os.Stat(entry.Path); err == nil {
+			add(entry.Path, info)
+		}
+	}
+	return files, nil
+}
+
+// showStats prints how many wallpapers are saved, the space they use,
+// how common each resolution is and the newest and oldest wallpaper
+func showStats() {
+	config := loadConfig()
+	library, err := loadIndex()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	files, err := libraryFiles(config, library)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if len(files) == 0 {
+		fmt.Println(tr("no wallpapers saved in %s", config.OutputDir))
+		return
+	}
+
+	var totalSize int64
+	resolutions := make(map[string]int)
+	newest, oldest := files[0], files[0]
+	for _, file := range files {
+		totalSize += file.size
+		resolution := tr("unknown")
+		if file.width > 0 {
+			resolution = fmt.Sprintf("%dx%d", file.width, file.height)
+		}
+		resolutions[resolution]++
+		if file.saved.After(newest.saved) {
+			newest = file
+		}
+		if file.saved.Before(oldest.saved) {
+			oldest = file
+		}
+	}
+
+	fmt.Println(tr("wallpapers: %d", len(files)))
+	fmt.Println(tr("disk usage: %s", formatFileSize(totalSize)))
+	fmt.Println(tr("newest: %s (%s)", newest.path, newest.saved.Format(time.DateOnly)))
+	fmt.Println(tr("oldest: %s (%s)", oldest.path, oldest.saved.Format(time.DateOnly)))
+	fmt.Println()
+	fmt.Println(tr("resolutions:"))
+	names := make([]string, 0, len(resolutions))
+	for name := range resolutions {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(a, b int) bool {
+		if resolutions[names[a]] != resolutions[names[b]] {
+			return resolutions[names[a]] > resolutions[names[b]]
+		}
+		return names[a] < names[b]
+	})
+	for _, name := range names {
+		count := resolutions[name]
+		fmt.Printf("  %-12s %5d  %5.1f%%\n", name, count, float64(count)*100/float64(len(files)))
+	}
+}
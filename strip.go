@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// errMalformedImage is returned when the structure of an image
+// file can't be followed to remove its metadata
+var errMalformedImage = errors.New("malformed image")
+
+// copyWithoutMetadata copies an image leaving out the metadata that
+// doesn't change how it looks, like EXIF, XMP, comments and texts
+//
+// Only the container is rewritten, so the pixels aren't re-encoded.
+// ICC color profiles are kept since they do change how it looks, and
+// formats that can't be cleaned are copied as they are
+func copyWithoutMetadata(sourcePath string, targetPath string) error {
+	data, err := os.ReadFile(sourcePath)
+	if err != nil {
+		return fmt.Errorf("couldn't open %s", sourcePath)
+	}
+	format, err := imageFormat(sourcePath)
+	if err != nil {
+		return err
+	}
+	var stripped []byte
+	switch format {
+	case "jpeg":
+		stripped, err = stripJPEGMetadata(data)
+	case "png":
+		stripped, err = stripPNGMetadata(data)
+	case "webp":
+		stripped, err = stripWebPMetadata(data)
+	default:
+		return copyFile(sourcePath, targetPath)
+	}
+	if err != nil {
+		return fmt.Errorf("couldn't remove the metadata of %s: %s", sourcePath, err)
+	}
+	if err := os.WriteFile(targetPath, stripped, 0644); err != nil {
+		return fmt.Errorf("couldn't create file %s", targetPath)
+	}
+	return nil
+}
+
+// stripJPEGMetadata removes the APP1 to APP15 segments but the ICC
+// profile, and the comments, of a JPEG image
+func stripJPEGMetadata(data []byte) ([]byte, error) {
+	if len(data) < 2 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, errMalformedImage
+	}
+	stripped := []byte{0xFF, 0xD8}
+	offset := 2
+	for {
+		if offset+4 > len(data) || data[offset] != 0xFF {
+			return nil, errMalformedImage
+		}
+		marker := data[offset+1]
+		if marker == 0xFF {
+			// fill bytes before a marker
+			offset++
+			continue
+		}
+		length := int(binary.BigEndian.Uint16(data[offset+2:]))
+		end := offset + 2 + length
+		if length < 2 || end > len(data) {
+			return nil, errMalformedImage
+		}
+		if marker == 0xDA {
+			// the compressed data follows the start of scan until the end
+			return append(stripped, data[offset:]...), nil
+		}
+		segment := data[offset:end]
+		isICC := marker == 0xE2 && bytes.HasPrefix(segment[4:], []byte("ICC_PROFILE\x00"))
+		isMetadata := (marker >= 0xE1 && marker <= 0xEF && !isICC) || marker == 0xFE
+		if !isMetadata {
+			stripped = append(stripped, segment...)
+		}
+		offset = end
+	}
+}
+
+// pngMetadataChunks are the PNG chunks left out, which have texts,
+// EXIF and the time of the last modification
+var pngMetadataChunks = map[string]bool{"tEXt": true, "zTXt": true, "iTXt": true, "eXIf": true, "tIME": true}
+
+// stripPNGMetadata removes the text, EXIF and time chunks of a PNG image
+func stripPNGMetadata(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, []byte(pngSignature)) {
+		return nil, errMalformedImage
+	}
+	stripped := append([]byte{}, pngSignature...)
+	offset := len(pngSignature)
+	for offset < len(data) {
+		if offset+12 > len(data) {
+			return nil, errMalformedImage
+		}
+		length := int(binary.BigEndian.Uint32(data[offset:]))
+		end := offset + 12 + length
+		if end > len(data) {
+			return nil, errMalformedImage
+		}
+		if !pngMetadataChunks[string(data[offset+4:offset+8])] {
+			stripped = append(stripped, data[offset:end]...)
+		}
+		offset = end
+	}
+	return stripped, nil
+}
+
+// stripWebPMetadata removes the EXIF and XMP chunks of a WebP image
+// and their flags in the VP8X chunk
+func stripWebPMetadata(data []byte) ([]byte, error) {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, errMalformedImage
+	}
+	stripped := append([]byte{}, data[:12]...)
+	offset := 12
+	for offset < len(data) {
+		if offset+8 > len(data) {
+			return nil, errMalformedImage
+		}
+		name := string(data[offset : offset+4])
+		size := int(binary.LittleEndian.Uint32(data[offset+4:]))
+		// chunks are padded to an even size
+		end := offset + 8 + size + size%2
+		if end > len(data) {
+			return nil, errMalformedImage
+		}
+		switch name {
+		case "EXIF", "XMP ":
+		case "VP8X":
+			chunk := append([]byte{}, data[offset:end]...)
+			if len(chunk) > 8 {
+				// the flags of the EXIF and XMP chunks
+				chunk[8] &^= 0x08 | 0x04
+			}
+			stripped = append(stripped, chunk...)
+		default:
+			stripped = append(stripped, data[offset:end]...)
+		}
+		offset = end
+	}
+	binary.LittleEndian.PutUint32(stripped[4:], uint32(len(stripped)-8))
+	return stripped, nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// ANSI colors of the summary
+const (
+	colorReset  = "\x1b[0m"
+	colorBold   = "\x1b[1m"
+	colorGreen  = "\x1b[32m"
+	colorYellow = "\x1b[33m"
+	colorRed    = "\x1b[31m"
+	colorGray   = "\x1b[90m"
+)
+
+// printSummary prints a table with the counts of a run when the
+// program runs in a terminal, so scheduled runs stay silent
+func printSummary(stats *runStats) {
+	if stats == nil || !isTerminal(os.Stdout) {
+		return
+	}
+	color := supportsColor(os.Stdout)
+	paint := func(code string, text string) string {
+		if !color {
+			return text
+		}
+		return code + text + colorReset
+	}
+	copiedColor := colorGray
+	if stats.Copied > 0 {
+		copiedColor = colorGreen
+	}
+	errorsColor := colorGray
+	if stats.Errors > 0 {
+		errorsColor = colorRed
+	}
+	type row struct {
+		label string
+		count int
+		color string
+	}
+	rows := []row{
+		{tr("files scanned"), stats.Scanned, colorBold},
+		{tr("wallpapers copied"), stats.Copied, copiedColor},
+		{tr("skipped as too small"), stats.Small, colorYellow},
+		{tr("skipped as existing"), stats.Existing, colorYellow},
+	}
+	if stats.People > 0 {
+		rows = append(rows, row{tr("skipped for people"), stats.People, colorYellow})
+	}
+	if stats.Theme > 0 {
+		rows = append(rows, row{tr("skipped for theme"), stats.Theme, colorYellow})
+	}
+	if stats.TextOverlay > 0 {
+		rows = append(rows, row{tr("skipped for text"), stats.TextOverlay, colorYellow})
+	}
+	if stats.LowScore > 0 {
+		rows = append(rows, row{tr("skipped for low score"), stats.LowScore, colorYellow})
+	}
+	rows = append(rows, row{tr("errors"), stats.Errors, errorsColor})
+	for _, row := range rows {
+		fmt.Printf("  %-22s %s\n", row.label, paint(row.color, fmt.Sprintf("%5d", row.count)))
+	}
+	if stats.SpaceSaved > 0 {
+		fmt.Printf("  %-22s %s\n", tr("saved by re-encoding"), paint(colorGreen, formatFileSize(stats.SpaceSaved)))
+	}
+	if stats.Errors > 0 {
+		fmt.Println(paint(colorRed, tr("see %s for the errors", logPath())))
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// telemetryState is the local record of the anonymous usage statistics
+//
+// Nothing is counted or sent unless Enabled is true
+type telemetryState struct {
+	Enabled     bool
+	Runs        int
+	ImagesSaved int
+}
+
+// telemetryReport is exactly what is sent when telemetry is on
+type telemetryReport struct {
+	Version     string `json:"version"`
+	OS          string `json:"os"`
+	OSVersion   string `json:"osVersion"`
+	Runs        int    `json:"runs"`
+	ImagesSaved int    `json:"imagesSaved"`
+}
+
+// telemetryPath returns the path of the telemetry state file
+func telemetryPath() string {
+	return filepath.Join(appDir(), "telemetry.json")
+}
+
+// loadTelemetry reads the telemetry state, which is
+// turned off if the file doesn't exist
+func loadTelemetry() *telemetryState {
+	state := new(telemetryState)
+	data, err := os.ReadFile(telemetryPath())
+	if err != nil {
+		return state
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return new(telemetryState)
+	}
+	return state
+}
+
+// save writes the telemetry state file
+func (s *telemetryState) save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(telemetryPath(), data, 0644)
+}
+
+// report returns the aggregate counters that would be sent
+func (s *telemetryState) report() *telemetryReport {
+	return &telemetryReport{
+		Version:     version,
+		OS:          runtime.GOOS,
+		OSVersion:   osVersion(),
+		Runs:        s.Runs,
+		ImagesSaved: s.ImagesSaved,
+	}
+}
+
+// telemetry handles the telemetry command, which shows the
+// status, turns telemetry on or off, or previews the report
+func telemetry(action string) {
+	state := loadTelemetry()
+	switch action {
+	case "status":
+		if state.Enabled {
+			fmt.Println(tr("telemetry is on"))
+		} else {
+			fmt.Println(tr("telemetry is off"))
+		}
+		if loadConfig().TelemetryURL == "" {
+			fmt.Println(tr("TelemetryURL is empty, statistics are only kept locally"))
+		}
+	case "on", "off":
+		state.Enabled = action == "on"
+		if !state.Enabled {
+			state = new(telemetryState)
+		}
+		if err := state.save(); err != nil {
+			log.Fatalln(err)
+		}
+		if state.Enabled {
+			fmt.Println(tr("telemetry turned on"))
+		} else {
+			fmt.Println(tr("telemetry turned off"))
+		}
+	case "preview":
+		data, err := json.MarshalIndent(state.report(), "", "  ")
+		if err != nil {
+			log.Fatalln(err)
+		}
+		fmt.Println(string(data))
+	default:
+		fmt.Println(tr("Unknown telemetry action %s, use status, on, off or preview", action))
+		os.Exit(1)
+	}
+}
+
+// recordTelemetry adds a run to the counters and sends the
+// report when telemetry is on and an address is configured
+func recordTelemetry(config *config, stats *runStats) {
+	state := loadTelemetry()
+	if !state.Enabled {
+		return
+	}
+	state.Runs++
+	state.ImagesSaved += stats.Copied
+	if err := state.save(); err != nil {
+		logError(err)
+		return
+	}
+	if config.TelemetryURL == "" {
+		return
+	}
+	data, err := json.Marshal(state.report())
+	if err != nil {
+		logError(err)
+		return
+	}
+	response, err := httpClient.Post(config.TelemetryURL, "application/json", bytes.NewReader(data))
+	if err != nil {
+		log.Printf("couldn't send telemetry: %s\n", err)
+		return
+	}
+	response.Body.Close()
+}
@@ -0,0 +1,21 @@
+package main
+
+import "os"
+
+// isTerminal tells whether a file is an interactive terminal
+func isTerminal(file *os.File) bool {
+	stat, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	return stat.Mode()&os.ModeCharDevice != 0
+}
+
+// supportsColor tells whether color sequences can be written to a
+// file, which must be a terminal and NO_COLOR must not be set
+func supportsColor(file *os.File) bool {
+	if !isTerminal(file) || os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	return enableColors(file)
+}
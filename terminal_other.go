@@ -0,0 +1,17 @@
+//go:build !windows
+
+package main
+
+import "os"
+
+// enableColors tells whether a terminal shows color sequences,
+// which every terminal does outside of Windows
+func enableColors(file *os.File) bool {
+	return true
+}
+
+// hideInput does nothing outside of Windows, where secrets
+// can't be stored, and returns a function that does nothing
+func hideInput(file *os.File) func() {
+	return func() {}
+}
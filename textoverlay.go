@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// promotionsCategory is the folder inside the output folder where
+// images with text overlays go when TextOverlays is folder
+const promotionsCategory = "Promotions"
+
+// promotionsDir returns the folder for images with text overlays
+func (c *config) promotionsDir() string {
+	return filepath.Join(c.OutputDir, promotionsCategory)
+}
+
+// the grid of cells that are looked at for text, and the pixels
+// sampled per side of each cell
+const (
+	textColumns = 32
+	textRows    = 18
+	textSamples = 24
+)
+
+// textLineLength is the number of cells side by side that make a line of text
+const textLineLength = 3
+
+// hasTextOverlay tells whether an image has prominent text, like the
+// promotional tiles Spotlight delivers among the photos, according
+// to the DetectTextCommand of the configuration or, without one, to
+// how much of the image looks like text
+//
+// The command receives the path of the image as last argument and
+// must print how prominent the text is, from 0 (none) to 1 (the
+// whole image). Images at or above TextThreshold have text. It is
+// only run when TextOverlays is skip or folder. Without a command,
+// images that couldn't be decoded have no text
+func (j *job) hasTextOverlay(imagePath string, img image.Image) bool {
+	mode := strings.ToLower(strings.TrimSpace(j.config.TextOverlays))
+	if mode != "skip" && mode != "folder" {
+		return false
+	}
+	var prominence float64
+	if j.config.DetectTextCommand != "" {
+		var err error
+		prominence, err = detectText(j.config, imagePath)
+		if err != nil {
+			j.logger.Errorln(err)
+			return false
+		}
+	} else if img != nil {
+		prominence = textProminence(img)
+	}
+	j.logger.Debugf("text prominence in %s is %.2f (threshold %.2f)", filepath.Base(imagePath), prominence, j.config.TextThreshold)
+	return prominence >= j.config.TextThreshold
+}
+
+// detectText runs the text detector on an image
+func detectText(config *config, imagePath string) (float64, error) {
+	output, err := runExternal(config.DetectTextCommand, imagePath)
+	if err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(output)
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("text detector printed nothing for %s", imagePath)
+	}
+	prominence, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, fmt.Errorf("couldn't read text detection of %s: %s", imagePath, err)
+	}
+	return prominence, nil
+}
+
+// textProminence returns the share of an image that looks like text,
+// from 0 to 1
+//
+// The image is divided in cells, and a cell looks like text when it
+// has many sharp edges between two tones, like letters of one color
+// over a plain background, and is part of a line of such cells.
+// Photos have edges too, like leaves or rocks, but their tones are
+// spread instead of split in two and they don't line up
+func textProminence(img image.Image) float64 {
+	bounds := img.Bounds()
+	cellWidth, cellHeight := bounds.Dx()/textColumns, bounds.Dy()/textRows
+	if cellWidth < 3 || cellHeight < 1 {
+		return 0
+	}
+	textCells := 0
+	textLike := make([]bool, textColumns)
+	lumas := make([]float64, 0, textSamples*textSamples)
+	for row := 0; row < textRows; row++ {
+		for column := range textLike {
+			lumas = lumas[:0]
+			edges := 0
+			for sy := 0; sy < textSamples; sy++ {
+				y := bounds.Min.Y + row*cellHeight + sy*cellHeight/textSamples
+				for sx := 0; sx < textSamples; sx++ {
+					x := bounds.Min.X + column*cellWidth + sx*(cellWidth-2)/textSamples
+					luma := brightness(img, x, y)
+					lumas = append(lumas, luma)
+					// two pixels apart, since the edges of letters are smoothed
+					if diff := brightness(img, x+2, y) - luma; diff > 60 || diff < -60 {
+						edges++
+					}
+				}
+			}
+			textLike[column] = edges*25 >= len(lumas) && isTwoTone(lumas)
+		}
+		textCells += textLineCells(textLike)
+	}
+	return float64(textCells) / (textColumns * textRows)
+}
+
+// textLineCells returns how many cells of a row are in runs of at
+// least textLineLength cells that look like text, since text is
+// written in lines while the edges of shapes in photos wander
+func textLineCells(textLike []bool) int {
+	cells, run := 0, 0
+	for column := 0; column <= len(textLike); column++ {
+		if column < len(textLike) && textLike[column] {
+			run++
+			continue
+		}
+		if run >= textLineLength {
+			cells += run
+		}
+		run = 0
+	}
+	return cells
+}
+
+// isTwoTone tells whether most of the lumas are near the darkest
+// or the brightest of them, which are far apart
+func isTwoTone(lumas []float64) bool {
+	low, high := 255.0, 0.0
+	for _, luma := range lumas {
+		low = min(low, luma)
+		high = max(high, luma)
+	}
+	if high-low < 80 {
+		return false
+	}
+	near := (high - low) / 6
+	extremes := 0
+	for _, luma := range lumas {
+		if luma-low <= near || high-luma <= near {
+			extremes++
+		}
+	}
+	return extremes*20 >= len(lumas)*17
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"image"
+	"math"
+	"strings"
+)
+
+// the themes a wallpaper goes with
+const (
+	darkTheme  = "dark"
+	lightTheme = "light"
+)
+
+// the luma from 0 to 255 under which a pixel is dark and over which it is bright
+const (
+	darkLuma   = 85
+	brightLuma = 170
+)
+
+// imageTheme returns whether an image goes with a dark or a light
+// theme, from a histogram of the luma of a sample of its pixels
+//
+// An image is dark when its average luma is low and few of its
+// pixels are bright, so a dark photo with a bright sky across
+// half of it isn't taken for dark
+func imageTheme(img image.Image) string {
+	lumas := sampleLumas(img)
+	var sum float64
+	var dark, bright int
+	for _, luma := range lumas {
+		sum += luma
+		if luma < darkLuma {
+			dark++
+		} else if luma > brightLuma {
+			bright++
+		}
+	}
+	samples := len(lumas)
+	average := sum / float64(samples)
+	if average < 110 && bright*4 < samples && dark*3 >= samples {
+		return darkTheme
+	}
+	return lightTheme
+}
+
+// sampleLumas returns the luma of a grid of pixels of an image,
+// as many as are sampled for its palette
+func sampleLumas(img image.Image) []float64 {
+	bounds := img.Bounds()
+	lumas := make([]float64, 0, paletteSamples*paletteSamples)
+	for sy := 0; sy < paletteSamples; sy++ {
+		y := bounds.Min.Y + sy*bounds.Dy()/paletteSamples
+		for sx := 0; sx < paletteSamples; sx++ {
+			x := bounds.Min.X + sx*bounds.Dx()/paletteSamples
+			lumas = append(lumas, brightness(img, x, y))
+		}
+	}
+	return lumas
+}
+
+// brightnessContrast returns the brightness of an image, its average
+// luma, and its contrast, the standard deviation of the luma, both in
+// percent of the largest luma
+func brightnessContrast(img image.Image) (float64, float64) {
+	lumas := sampleLumas(img)
+	var sum float64
+	for _, luma := range lumas {
+		sum += luma
+	}
+	average := sum / float64(len(lumas))
+	var variance float64
+	for _, luma := range lumas {
+		variance += (luma - average) * (luma - average)
+	}
+	deviation := math.Sqrt(variance / float64(len(lumas)))
+	return average * 100 / 255, deviation * 100 / 255
+}
+
+// filtersPixels tells whether MinBrightness, MaxBrightness,
+// MinContrast or MinSharpness are set, which needs decoding the images
+func (c *config) filtersPixels() bool {
+	return c.MinBrightness > 0 || c.MaxBrightness > 0 || c.MinContrast > 0 || c.MinSharpness > 0
+}
+
+// hasGoodPixels tells whether an image is within MinBrightness and
+// MaxBrightness and has at least MinContrast and MinSharpness, so
+// nearly black promo frames, washed out and blurry images are left out
+func (c *config) hasGoodPixels(img image.Image) bool {
+	if !c.filtersPixels() {
+		return true
+	}
+	average, contrast := brightnessContrast(img)
+	if average < c.MinBrightness || (c.MaxBrightness > 0 && average > c.MaxBrightness) || contrast < c.MinContrast {
+		return false
+	}
+	return c.MinSharpness <= 0 || imageSharpness(img) >= c.MinSharpness
+}
+
+// entryTheme returns the theme of a saved wallpaper, computing it
+// for entries indexed before themes were
+func entryTheme(entry *indexEntry) string {
+	if entry.Theme == "" {
+		if img, err := decodeImage(entry.Path); err == nil {
+			entry.Theme = imageTheme(img)
+		}
+	}
+	return entry.Theme
+}
+
+// skipsTheme tells whether an image doesn't go with the Theme
+// of the configuration, images that couldn't be decoded go with any
+func (j *job) skipsTheme(img image.Image) bool {
+	wanted := strings.ToLower(strings.TrimSpace(j.config.Theme))
+	if wanted == "" || wanted == "any" || img == nil {
+		return false
+	}
+	return imageTheme(img) != wanted
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"strings"
+)
+
+// thumbnailWidth is the width in pixels of the thumbnails shown in the terminal
+const thumbnailWidth = 320
+
+// inlineImageProtocol returns the protocol the terminal uses
+// to show images, kitty or iterm, or an empty string if none
+func inlineImageProtocol() string {
+	if !isTerminal(os.Stdout) {
+		return ""
+	}
+	if os.Getenv("KITTY_WINDOW_ID") != "" || strings.Contains(os.Getenv("TERM"), "kitty") {
+		return "kitty"
+	}
+	switch os.Getenv("TERM_PROGRAM") {
+	case "iTerm.app", "WezTerm":
+		return "iterm"
+	}
+	return ""
+}
+
+// printThumbnail shows a small version of an image in terminals that
+// can show images and does nothing in the rest
+func printThumbnail(imagePath string) error {
+	protocol := inlineImageProtocol()
+	if protocol == "" {
+		return nil
+	}
+	img, err := decodeImage(imagePath)
+	if err != nil {
+		return err
+	}
+	bounds := img.Bounds()
+	height := max(1, bounds.Dy()*thumbnailWidth/max(1, bounds.Dx()))
+	thumbnail := resizeRect(img, bounds, thumbnailWidth, height)
+
+	var data bytes.Buffer
+	if protocol == "kitty" {
+		// kitty only takes PNG, sent in chunks of 4096 bytes
+		if err := png.Encode(&data, thumbnail); err != nil {
+			return err
+		}
+		encoded := base64.StdEncoding.EncodeToString(data.Bytes())
+		for i := 0; i < len(encoded); i += 4096 {
+			chunk := encoded[i:min(i+4096, len(encoded))]
+			more := 0
+			if i+4096 < len(encoded) {
+				more = 1
+			}
+			if i == 0 {
+				fmt.Printf("\x1b_Gf=100,a=T,m=%d;%s\x1b\\", more, chunk)
+			} else {
+				fmt.Printf("\x1b_Gm=%d;%s\x1b\\", more, chunk)
+			}
+		}
+		fmt.Println()
+		return nil
+	}
+	if err := jpeg.Encode(&data, thumbnail, &jpeg.Options{Quality: 80}); err != nil {
+		return err
+	}
+	fmt.Printf("\x1b]1337;File=inline=1;size=%d;preserveAspectRatio=1:%s\a\n",
+		data.Len(), base64.StdEncoding.EncodeToString(data.Bytes()))
+	return nil
+}
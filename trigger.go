@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+)
+
+// handleTrigger reads a command from a client of the trigger channel
+// and answers it, so far only scan is understood
+func handleTrigger(conn io.ReadWriter, control *Control) {
+	command, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil && command == "" {
+		return
+	}
+	switch strings.TrimSpace(command) {
+	case "scan":
+		var queued bool
+		control.Scan(struct{}{}, &queued)
+		if queued {
+			control.event("scan requested through %s", triggerAddress())
+		}
+		fmt.Fprintln(conn, "ok")
+	default:
+		fmt.Fprintln(conn, "unknown command")
+	}
+}
+
+// serveTrigger accepts clients of the trigger channel until it fails
+func serveTrigger(control *Control) {
+	if err := listenTrigger(control); err != nil {
+		log.Printf("couldn't open the trigger channel %s: %s\n", triggerAddress(), err)
+	}
+}
+
+// trigger asks the running daemon to scan right now through the trigger
+// channel, which is simpler to use from hotkey tools than the control API
+func trigger() {
+	conn, err := dialTrigger()
+	if err != nil {
+		fmt.Println(tr("the daemon isn't running"))
+		os.Exit(1)
+	}
+	defer conn.Close()
+	if _, err := fmt.Fprintln(conn, "scan"); err != nil {
+		log.Fatalln(err)
+	}
+	answer, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil || strings.TrimSpace(answer) != "ok" {
+		log.Fatalf("the daemon didn't accept the scan: %s\n", strings.TrimSpace(answer))
+	}
+	fmt.Println(tr("scan requested"))
+}
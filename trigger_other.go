@@ -0,0 +1,46 @@
+//go:build !windows
+
+package main
+
+import (
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+)
+
+// triggerAddress returns the path of the unix socket of the trigger channel
+func triggerAddress() string {
+	return filepath.Join(appDir(), "trigger.sock")
+}
+
+// listenTrigger serves the trigger channel on a unix socket
+// only the current user can connect to
+func listenTrigger(control *Control) error {
+	// a socket left by a daemon that crashed would make the listen fail,
+	// the control address already makes sure this is the only daemon
+	os.Remove(triggerAddress())
+	listener, err := net.Listen("unix", triggerAddress())
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+	if err := os.Chmod(triggerAddress(), 0600); err != nil {
+		return err
+	}
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			defer conn.Close()
+			handleTrigger(conn, control)
+		}()
+	}
+}
+
+// dialTrigger connects to the trigger channel of the running daemon
+func dialTrigger() (io.ReadWriteCloser, error) {
+	return net.Dial("unix", triggerAddress())
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"io"
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// triggerPipe is the named pipe of the trigger channel
+const triggerPipe = `\\.\pipe\wspotsave`
+
+// triggerAddress returns where the trigger channel listens
+func triggerAddress() string {
+	return triggerPipe
+}
+
+// pipeSecurity returns security attributes that only let
+// the current user connect to the pipe
+func pipeSecurity() (*windows.SecurityAttributes, error) {
+	user, err := windows.GetCurrentProcessToken().GetTokenUser()
+	if err != nil {
+		return nil, err
+	}
+	descriptor, err := windows.SecurityDescriptorFromString("D:P(A;;GA;;;" + user.User.Sid.String() + ")")
+	if err != nil {
+		return nil, err
+	}
+	return &windows.SecurityAttributes{
+		Length:             uint32(unsafe.Sizeof(windows.SecurityAttributes{})),
+		SecurityDescriptor: descriptor,
+	}, nil
+}
+
+// listenTrigger serves the trigger channel on a named pipe,
+// one client at a time
+func listenTrigger(control *Control) error {
+	name, err := windows.UTF16PtrFromString(triggerPipe)
+	if err != nil {
+		return err
+	}
+	security, err := pipeSecurity()
+	if err != nil {
+		return err
+	}
+	for {
+		pipe, err := windows.CreateNamedPipe(
+			name,
+			windows.PIPE_ACCESS_DUPLEX,
+			windows.PIPE_TYPE_BYTE|windows.PIPE_READMODE_BYTE|windows.PIPE_WAIT|windows.PIPE_REJECT_REMOTE_CLIENTS,
+			windows.PIPE_UNLIMITED_INSTANCES,
+			512, 512, 0, security,
+		)
+		if err != nil {
+			return err
+		}
+		err = windows.ConnectNamedPipe(pipe, nil)
+		if err != nil && err != windows.ERROR_PIPE_CONNECTED {
+			windows.CloseHandle(pipe)
+			return err
+		}
+		conn := os.NewFile(uintptr(pipe), triggerPipe)
+		handleTrigger(conn, control)
+		windows.FlushFileBuffers(pipe)
+		windows.DisconnectNamedPipe(pipe)
+		conn.Close()
+	}
+}
+
+// dialTrigger connects to the trigger channel of the running daemon
+func dialTrigger() (io.ReadWriteCloser, error) {
+	return os.OpenFile(triggerPipe, os.O_RDWR, 0)
+}
@@ -0,0 +1,24 @@
+package main
+
+import "path/filepath"
+
+// ultrawideCategory is the folder inside the output folder
+// where ultrawide panoramas go when UltrawideDir is empty
+const ultrawideCategory = "Ultrawide"
+
+// ultrawideDir returns the folder for ultrawide panoramas
+func (c *config) ultrawideDir() string {
+	if c.UltrawideDir != "" {
+		return c.UltrawideDir
+	}
+	return filepath.Join(c.OutputDir, ultrawideCategory)
+}
+
+// isUltrawide tells whether an image is unusually wide,
+// like the panoramas Spotlight delivers from time to time
+func (c *config) isUltrawide(width int, height int) bool {
+	if c.UltrawideRatio <= 0 || height == 0 {
+		return false
+	}
+	return float64(width)/float64(height) >= c.UltrawideRatio
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// configProblem is a key of the configuration with a wrong value,
+// why it is wrong and how to fix it
+type configProblem struct {
+	section string
+	key     string
+	value   string
+	reason  string
+	fix     string
+}
+
+func (p configProblem) String() string {
+	if p.section == ini.DefaultSection {
+		return fmt.Sprintf("%s = %s: %s", p.key, p.value, p.reason)
+	}
+	return fmt.Sprintf("[%s] %s = %s: %s", p.section, p.key, p.value, p.reason)
+}
+
+// valueCheck returns why a value is wrong and how to fix it,
+// or empty strings if it is right
+type valueCheck func(value string) (reason string, fix string)
+
+// configChecks are the checks of the values of the configuration
+// keys, besides their type
+var configChecks = map[string]valueCheck{
+	"SourceDir":         checkFolder,
+	"OutputDir":         checkFolder,
+	"PendingDir":        checkFolder,
+	"RotationDir":       checkFolder,
+	"UltrawideDir":      checkFolder,
+	"LockScreenDir":     checkFolder,
+	"MinimumWidth":      checkNotNegative,
+	"MinimumHeight":     checkNotNegative,
+	"MinimumFileSizeKB": checkNotNegative,
+	"RotationCount":     checkNotNegative,
+	"MaxImages":         checkNotNegative,
+	"MaxLibrarySizeGB":  checkNotNegative,
+	"RetentionOrder":    checkOneOf("oldest", "score"),
+	"OnExisting":        checkOneOf(existingSkip, existingOverwrite, existingOverwriteIfDifferent, existingRename),
+	"UltrawideRatio":    checkNotNegative,
+	"MinAspectRatio":    checkNotNegative,
+	"MaxAspectRatio":    checkNotNegative,
+	"MinBrightness":     checkRange(0, 100),
+	"MaxBrightness":     checkRange(0, 100),
+	"MinContrast":       checkRange(0, 100),
+	"MinSharpness":      checkNotNegative,
+	"MaxSizeMB":         checkNotNegative,
+	"MaxBackups":        checkNotNegative,
+	"MaxAgeDays":        checkNotNegative,
+	"OutputSize":        checkSize,
+	"OutputFormat":      checkOneOf("jpeg", "jpg", "png", "webp"),
+	"PNGCompression":    checkOneOf("default", "fast", "best", "none"),
+	"JPEGQuality":       checkRange(0, 100),
+	"TargetFileSizeKB":  checkNotNegative,
+	"StackDistance":     checkRange(0, 64),
+	"DuplicateDistance": checkRange(-1, 64),
+	"PeopleThreshold":   checkRange(0, 1),
+	"TextOverlays":      checkOneOf("keep", "skip", "folder"),
+	"TextThreshold":     checkRange(0, 1),
+	"RotationOrder":     checkOneOf("newest", "score"),
+	"OrganizeBy":        checkOneOf("none", "category", "color"),
+	"Orientation":       checkOneOf("any", "landscape", "portrait"),
+	"Theme":             checkOneOf("any", "dark", "light"),
+	"KeepRenditions":    checkOneOf("both", "landscape", "portrait"),
+	"LogLevel":          checkOneOf("error", "info", "debug"),
+	"LogTarget":         checkOneOf("file", "eventlog", "both"),
+	"LockScreenSize":    checkSize,
+	"LockScreenMargins": checkLockScreenMargins,
+	"DaemonInterval":    checkDaemonInterval,
+	"NameTemplate":      checkNameTemplate,
+	"IPTCCaption":       checkTextTemplate("IPTCCaption"),
+	"IPTCKeywords":      checkTextTemplate("IPTCKeywords"),
+	"WatermarkText":     checkTextTemplate("WatermarkText"),
+	"CaptionText":       checkTextTemplate("CaptionText"),
+	"WatermarkSize":     checkRange(0, 50),
+	"WatermarkOpacity":  checkRange(0, 1),
+	"WatermarkPosition": checkOneOf("top-left", "top-right", "bottom-left", "bottom-right"),
+	"ExcludePatterns":   checkExcludePatterns,
+	"ConfigVersion":     checkConfigVersion,
+}
+
+// isConfigSection returns whether a section of the configuration
+// file has configuration keys, like the default, job, profile and
+// source sections
+func isConfigSection(name string) bool {
+	return name == ini.DefaultSection || strings.HasPrefix(name, jobSectionPrefix) ||
+		strings.HasPrefix(name, profileSectionPrefix) || strings.HasPrefix(name, sourceSectionPrefix)
+}
+
+// validateConfig returns the wrong values of the configuration,
+// which ini maps to zero or keeps as they are without complaint
+//
+// Empty values are left out, since they keep the default
+func validateConfig(iniConfig *ini.File) []configProblem {
+	var problems []configProblem
+	fields := reflect.TypeOf(config{})
+	for _, section := range iniConfig.Sections() {
+		if !isConfigSection(section.Name()) {
+			continue
+		}
+		for _, key := range section.Keys() {
+			field, ok := fields.FieldByName(key.Name())
+			if !ok || !field.IsExported() || strings.TrimSpace(key.String()) == "" {
+				continue
+			}
+			reason, fix := checkType(field.Type.Kind(), key.String())
+			if name, ok := secretName(key.String()); ok && slices.Contains(secretKeys, key.Name()) {
+				reason, fix = checkSecret(name)
+			} else if ok {
+				reason, fix = tr("it can't refer to a secret"), tr("write the value itself")
+			} else if reason == "" && configChecks[key.Name()] != nil {
+				reason, fix = configChecks[key.Name()](key.String())
+			}
+			if reason != "" {
+				problems = append(problems, configProblem{section.Name(), key.Name(), key.String(), reason, fix})
+			}
+		}
+	}
+	return problems
+}
+
+// configError returns an error that lists the wrong values
+// of the configuration with their fixes
+func configError(problems []configProblem) error {
+	lines := []string{tr("the configuration has %d wrong values:", len(problems))}
+	for _, problem := range problems {
+		lines = append(lines, "  "+problem.String()+", "+problem.fix)
+	}
+	return errors.New(strings.Join(lines, "\n"))
+}
+
+// checkSecret checks that a secret referred to by the configuration
+// is stored
+func checkSecret(name string) (string, string) {
+	if _, err := readSecret(name); err != nil {
+		return tr("the secret %s can't be read: %s", name, err), tr("store it with wspotsave secret set %s", name)
+	}
+	return "", ""
+}
+
+// checkType checks that a value can be read as a field of a kind
+func checkType(kind reflect.Kind, value string) (string, string) {
+	value = strings.TrimSpace(value)
+	switch kind {
+	case reflect.Int:
+		if _, err := strconv.Atoi(value); err != nil {
+			return tr("it isn't a whole number"), tr("write only digits, like 1920")
+		}
+	case reflect.Float64:
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return tr("it isn't a number"), tr("write a number with a dot, like 0.5")
+		}
+	case reflect.Bool:
+		key, _ := ini.Empty().Section("").NewKey("key", value)
+		if _, err := key.Bool(); err != nil {
+			return tr("it isn't true or false"), tr("write true or false")
+		}
+	}
+	return "", ""
+}
+
+// checkFolder checks that a folder, or a list of them separated
+// by ;, has no characters that Windows doesn't allow in paths
+func checkFolder(value string) (string, string) {
+	if i := strings.IndexAny(value, `<>"|?*`); i >= 0 {
+		return tr("%c can't be in a folder path", value[i]),
+			tr("remove it, paths with spaces don't need quotes")
+	}
+	return "", ""
+}
+
+// checkNotNegative checks that a number isn't negative
+func checkNotNegative(value string) (string, string) {
+	if n, _ := strconv.ParseFloat(strings.TrimSpace(value), 64); n < 0 {
+		return tr("it can't be negative"), tr("use 0 or a positive number")
+	}
+	return "", ""
+}
+
+// checkRange returns a check that a number is between min and max
+func checkRange(min float64, max float64) valueCheck {
+	return func(value string) (string, string) {
+		if n, _ := strconv.ParseFloat(strings.TrimSpace(value), 64); n < min || n > max {
+			return tr("it must be between %g and %g", min, max), tr("use a number in that range")
+		}
+		return "", ""
+	}
+}
+
+// checkOneOf returns a check that a value is one of a list,
+// ignoring case
+func checkOneOf(values ...string) valueCheck {
+	return func(value string) (string, string) {
+		if slices.Contains(values, strings.ToLower(strings.TrimSpace(value))) {
+			return "", ""
+		}
+		return tr("it isn't a known value"), tr("use one of %s", strings.Join(values, ", "))
+	}
+}
+
+// checkSize checks that a value is a size, like LockScreenSize
+func checkSize(value string) (string, string) {
+	if _, _, err := parseSize(value); err != nil {
+		return err.Error(), tr("use a size like 1920x1080")
+	}
+	return "", ""
+}
+
+// checkLockScreenMargins checks that LockScreenMargins are margins
+func checkLockScreenMargins(value string) (string, string) {
+	if _, err := parseMargins(value); err != nil {
+		return err.Error(), tr("use four percents for the top, right, bottom and left, like 0,0,30,0")
+	}
+	return "", ""
+}
+
+// checkDaemonInterval checks that DaemonInterval is an age
+func checkDaemonInterval(value string) (string, string) {
+	if _, err := parseAge(value); err != nil {
+		return err.Error(), tr("use an interval like 6h or 1d")
+	}
+	return "", ""
+}
+
+// checkExcludePatterns checks that ExcludePatterns are globs
+// or regular expressions
+func checkExcludePatterns(value string) (string, string) {
+	if _, err := parseExcludePatterns(value); err != nil {
+		return err.Error(), tr("write globs like *promo* or regular expressions between slashes like /^ab12/")
+	}
+	return "", ""
+}
+
+// checkNameTemplate checks that NameTemplate is a valid template
+func checkNameTemplate(value string) (string, string) {
+	if _, err := (&config{NameTemplate: value}).parseNameTemplate(); err != nil {
+		return err.Error(), tr("fix the template or leave it empty to keep the source names")
+	}
+	return "", ""
+}
+
+// checkTextTemplate returns a check that a template of a text
+// written into wallpapers, like IPTCCaption, is valid
+func checkTextTemplate(key string) valueCheck {
+	return func(value string) (string, string) {
+		if _, err := (&config{}).parseTextTemplate(key, value); err != nil {
+			return err.Error(), tr("fix the template or leave it empty to write nothing")
+		}
+		return "", ""
+	}
+}
+
+// checkConfigVersion checks that the configuration wasn't written
+// by a newer release, whose keys may be misread
+func checkConfigVersion(value string) (string, string) {
+	if version, _ := strconv.Atoi(strings.TrimSpace(value)); version > currentConfigVersion {
+		return tr("it was written by a newer release of wspotsave"), tr("update with wspotsave self-update")
+	}
+	return "", ""
+}
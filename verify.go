@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+)
+
+// verifyLibrary checks the saved wallpapers against the checksums
+// recorded when they were copied, to find files corrupted or modified
+// since, e.g. on a failing external drive
+//
+// Wallpapers saved before checksums were recorded are only counted,
+// unless --record is given to record their current checksum
+func verifyLibrary(args []string) {
+	flags := newFlagSet("verify")
+	record := flags.Bool("record", false, "record the checksum of the wallpapers that don't have one yet")
+	flags.Parse(args)
+
+	library, err := loadIndex()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	verified, unrecorded, problems := 0, 0, 0
+	for _, entry := range library.sorted(func(a, b *indexEntry) bool { return a.Path < b.Path }) {
+		checksum, err := fileChecksum(entry.Path)
+		switch {
+		case os.IsNotExist(err):
+			fmt.Println(tr("missing: %s", entry.Path))
+			problems++
+		case err != nil:
+			fmt.Println(tr("unreadable: %s: %s", entry.Path, err))
+			problems++
+		case entry.Checksum == "":
+			unrecorded++
+			if *record {
+				entry.Checksum = hex.EncodeToString(checksum)
+			}
+		case entry.Checksum != hex.EncodeToString(checksum):
+			fmt.Println(tr("modified or corrupted: %s", entry.Path))
+			problems++
+		default:
+			verified++
+		}
+	}
+	if *record && unrecorded > 0 {
+		if err := library.save(); err != nil {
+			log.Fatalln(err)
+		}
+	}
+
+	fmt.Println(tr("%d wallpapers verified, %d with problems", verified, problems))
+	if unrecorded > 0 {
+		if *record {
+			fmt.Println(tr("recorded the checksum of %d wallpapers", unrecorded))
+		} else {
+			fmt.Println(tr("%d wallpapers have no recorded checksum, run wspotsave verify --record to record it", unrecorded))
+		}
+	}
+	if problems > 0 {
+		os.Exit(exitFailure)
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+)
+
+// version of the program and how it was built, set at build time with
+// -ldflags "-X main.version=1.2.3 -X main.commit=abc1234 -X main.buildDate=2024-01-31"
+//
+// When not set, the commit and date recorded by go build are used
+var (
+	version   = "dev"
+	commit    = ""
+	buildDate = ""
+)
+
+// init fills in the build details not set at build time from
+// the ones recorded by go build
+func init() {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return
+	}
+	if version == "dev" && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		version = strings.TrimPrefix(info.Main.Version, "v")
+	}
+	settings := make(map[string]string)
+	for _, setting := range info.Settings {
+		settings[setting.Key] = setting.Value
+	}
+	if commit == "" && settings["vcs.revision"] != "" {
+		commit = settings["vcs.revision"]
+		if settings["vcs.modified"] == "true" {
+			commit += "-dirty"
+		}
+	}
+	if buildDate == "" {
+		buildDate = settings["vcs.time"]
+	}
+}
+
+// showVersion prints the version and build details, and with
+// --check whether a newer release is available
+func showVersion(args []string) {
+	flags := newFlagSet("version")
+	check := flags.Bool("check", false, "check whether a newer release is available")
+	flags.Parse(args)
+	if flags.NArg() != 0 {
+		flags.Usage()
+		os.Exit(1)
+	}
+	fmt.Println("wspotsave", version)
+	if commit != "" {
+		fmt.Println(tr("commit: %s", commit))
+	}
+	if buildDate != "" {
+		fmt.Println(tr("built: %s", buildDate))
+	}
+	fmt.Println(tr("go: %s %s/%s", runtime.Version(), runtime.GOOS, runtime.GOARCH))
+	if !*check {
+		return
+	}
+	if _, ok := parseVersion(version); !ok {
+		fmt.Println(tr("this is a development build, it isn't compared with the releases"))
+		return
+	}
+	latest, err := latestRelease()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if isNewerVersion(latest.version(), version) {
+		fmt.Println(tr("wspotsave %s is available, update with: wspotsave self-update", latest.version()))
+	} else {
+		fmt.Println(tr("wspotsave %s is already the latest version", version))
+	}
+}
+
+// isNewerVersion tells whether the semantic version a is newer than b,
+// it is false when either is a development build
+func isNewerVersion(a string, b string) bool {
+	aParts, aOk := parseVersion(a)
+	bParts, bOk := parseVersion(b)
+	if !aOk || !bOk {
+		return false
+	}
+	for i := range aParts {
+		if aParts[i] != bParts[i] {
+			return aParts[i] > bParts[i]
+		}
+	}
+	return false
+}
+
+// pseudoVersion matches the versions go build stamps on builds
+// that aren't of a tagged release, like 0.0.0-20240131120000-abcdef123456
+var pseudoVersion = regexp.MustCompile(`-(?:[0-9A-Za-z-]+\.)*\d{14}-[0-9a-f]{12}(?:\+.*)?$`)
+
+// parseVersion returns the major, minor and patch numbers of a version
+// like v1.2.3, ignoring any pre-release or build suffix
+//
+// Pseudo-versions aren't parsed, they are development builds
+func parseVersion(s string) ([3]int, bool) {
+	var parts [3]int
+	if pseudoVersion.MatchString(s) {
+		return parts, false
+	}
+	s = strings.TrimPrefix(s, "v")
+	if i := strings.IndexAny(s, "-+"); i >= 0 {
+		s = s[:i]
+	}
+	fields := strings.Split(s, ".")
+	if len(fields) > 3 {
+		return parts, false
+	}
+	for i, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil {
+			return parts, false
+		}
+		parts[i] = n
+	}
+	return parts, true
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// setWallpaper sets the image in the given path as the desktop wallpaper
+//
+// It detects the desktop environment from XDG_CURRENT_DESKTOP and uses
+// plasma-apply-wallpaperimage on KDE and gsettings on GNOME based desktops
+func setWallpaper(imagePath string) error {
+	desktop := strings.ToUpper(os.Getenv("XDG_CURRENT_DESKTOP"))
+	switch {
+	case strings.Contains(desktop, "KDE"):
+		return runCommand("plasma-apply-wallpaperimage", imagePath)
+	case strings.Contains(desktop, "GNOME"), strings.Contains(desktop, "UNITY"),
+		strings.Contains(desktop, "BUDGIE"), strings.Contains(desktop, "CINNAMON"):
+		uri := (&url.URL{Scheme: "file", Path: imagePath}).String()
+		if err := runCommand("gsettings", "set", "org.gnome.desktop.background", "picture-uri", uri); err != nil {
+			return err
+		}
+		// GNOME 42+ uses a separate key when the dark style is active,
+		// older versions don't have it so the error is ignored
+		runCommand("gsettings", "set", "org.gnome.desktop.background", "picture-uri-dark", uri)
+		return nil
+	case desktop == "":
+		return fmt.Errorf("couldn't detect desktop environment, XDG_CURRENT_DESKTOP is not set")
+	default:
+		return fmt.Errorf("desktop environment %s is not supported", desktop)
+	}
+}
+
+// setSpannedWallpaper sets an image that covers all the monitors
+// as the desktop wallpaper
+//
+// GNOME based desktops are told to span the image across the
+// monitors, KDE Plasma spans it per screen on its own
+func setSpannedWallpaper(imagePath string) error {
+	desktop := strings.ToUpper(os.Getenv("XDG_CURRENT_DESKTOP"))
+	if !strings.Contains(desktop, "KDE") {
+		runCommand("gsettings", "set", "org.gnome.desktop.background", "picture-options", "spanned")
+	}
+	return setWallpaper(imagePath)
+}
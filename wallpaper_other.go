@@ -0,0 +1,18 @@
+//go:build !linux && !windows
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// setWallpaper is not supported on this platform
+func setWallpaper(imagePath string) error {
+	return fmt.Errorf("setting the wallpaper is not supported on %s", runtime.GOOS)
+}
+
+// setSpannedWallpaper is not supported on this platform
+func setSpannedWallpaper(imagePath string) error {
+	return fmt.Errorf("setting the wallpaper is not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+const (
+	spiSetDeskWallpaper = 0x0014
+	spifUpdateIniFile   = 0x01
+	spifSendChange      = 0x02
+)
+
+var procSystemParametersInfoW = syscall.NewLazyDLL("user32.dll").NewProc("SystemParametersInfoW")
+
+// setWallpaper sets the image in the given path as the desktop wallpaper
+func setWallpaper(imagePath string) error {
+	pathPtr, err := syscall.UTF16PtrFromString(imagePath)
+	if err != nil {
+		return err
+	}
+	ret, _, err := procSystemParametersInfoW.Call(
+		spiSetDeskWallpaper,
+		0,
+		uintptr(unsafe.Pointer(pathPtr)),
+		spifUpdateIniFile|spifSendChange,
+	)
+	if ret == 0 {
+		return fmt.Errorf("couldn't set wallpaper %s: %s", imagePath, err)
+	}
+	return nil
+}
+
+// setSpannedWallpaper sets an image that covers all the monitors
+// as the desktop wallpaper, using the span wallpaper style
+func setSpannedWallpaper(imagePath string) error {
+	key, err := registry.OpenKey(registry.CURRENT_USER, `Control Panel\Desktop`, registry.SET_VALUE)
+	if err != nil {
+		return fmt.Errorf("couldn't open desktop settings: %s", err)
+	}
+	defer key.Close()
+	// style 22 spans the image across all the monitors
+	if err := key.SetStringValue("WallpaperStyle", "22"); err != nil {
+		return err
+	}
+	if err := key.SetStringValue("TileWallpaper", "0"); err != nil {
+		return err
+	}
+	return setWallpaper(imagePath)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"os"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// stampedText is a text drawn on a wallpaper and where
+type stampedText struct {
+	text     string
+	position string
+}
+
+// stampedTexts returns the texts drawn on a wallpaper, the one of
+// WatermarkText in its corner and the caption of CaptionText centered
+// in the lower margin when BurnCaption is true
+func (j *job) stampedTexts(entry *indexEntry) []stampedText {
+	var texts []stampedText
+	data := newTextData(entry)
+	watermark, err := j.config.executeTextTemplate("WatermarkText", j.config.WatermarkText, data)
+	if err != nil {
+		j.logger.Errorln(err)
+	} else if watermark != "" {
+		texts = append(texts, stampedText{watermark, j.config.WatermarkPosition})
+	}
+	if j.config.BurnCaption {
+		caption, err := j.config.executeTextTemplate("CaptionText", j.config.CaptionText, data)
+		if err != nil {
+			j.logger.Errorln(err)
+		} else if caption != "" {
+			texts = append(texts, stampedText{caption, "bottom-center"})
+		}
+	}
+	return texts
+}
+
+// stampTexts saves a wallpaper again from its source with the
+// watermark and the caption drawn on it, and returns whether it did
+//
+// The source is processed again instead of the saved file so the
+// image is encoded only once. WebP, HEIC and AVIF wallpapers can't be
+// encoded here and are left without them
+func (j *job) stampTexts(sourcePath string, entry *indexEntry) bool {
+	format, err := imageFormat(sourcePath)
+	if err != nil {
+		j.logger.Errorln(err)
+		return false
+	}
+	output := j.config.outputFormat(format)
+	if output != "jpeg" && output != "png" {
+		return false
+	}
+	texts := j.stampedTexts(entry)
+	if len(texts) == 0 {
+		return false
+	}
+	img, err := decodeImage(sourcePath)
+	if err != nil {
+		j.logger.Errorln(err)
+		return false
+	}
+	img = j.config.fitOutputSize(orientImage(img, imageOrientation(sourcePath)))
+	bounds := img.Bounds()
+	stamped := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(stamped, stamped.Bounds(), img, bounds.Min, draw.Src)
+	for _, text := range texts {
+		if err := j.config.drawText(stamped, text.text, text.position); err != nil {
+			j.logger.Errorln(err)
+			return false
+		}
+	}
+	if output == "png" {
+		err = j.config.encodePNG(stamped, entry.Path)
+	} else {
+		err = j.config.encodeWallpaper(stamped, entry.Path)
+	}
+	if err != nil {
+		j.logger.Errorln(err)
+		return false
+	}
+	return true
+}
+
+// watermarkFace returns the font face of the watermark, of WatermarkFont
+// or Go Regular, with the given size in pixels
+func (c *config) watermarkFace(size float64) (font.Face, error) {
+	fontData := goregular.TTF
+	if c.WatermarkFont != "" {
+		data, err := os.ReadFile(c.WatermarkFont)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't read the font %s", c.WatermarkFont)
+		}
+		fontData = data
+	}
+	parsed, err := opentype.Parse(fontData)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read the font %s: %s", c.WatermarkFont, err)
+	}
+	return opentype.NewFace(parsed, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+}
+
+// drawText draws a text at a position like bottom-right, in white
+// with a dark shadow so it can be read on any background,
+// WatermarkSize percent of the image high and with WatermarkOpacity
+func (c *config) drawText(stamped *image.RGBA, text string, position string) error {
+	bounds := stamped.Bounds()
+	size := max(float64(bounds.Dy())*c.WatermarkSize/100, 8)
+	face, err := c.watermarkFace(size)
+	if err != nil {
+		return err
+	}
+	defer face.Close()
+
+	margin := int(size)
+	width := font.MeasureString(face, text).Ceil()
+	metrics := face.Metrics()
+	x, y := margin, margin+metrics.Ascent.Ceil()
+	position = strings.ToLower(strings.TrimSpace(position))
+	if position == "" {
+		position = "bottom-right"
+	}
+	switch {
+	case strings.HasSuffix(position, "right"):
+		x = bounds.Dx() - margin - width
+	case strings.HasSuffix(position, "center"):
+		x = (bounds.Dx() - width) / 2
+	}
+	if strings.HasPrefix(position, "bottom") {
+		y = bounds.Dy() - margin - metrics.Descent.Ceil()
+	}
+
+	alpha := uint8(255 * min(max(c.WatermarkOpacity, 0), 1))
+	shadowOffset := max(int(size/16), 1)
+	for _, layer := range []struct {
+		color  color.Color
+		offset int
+	}{
+		{color.NRGBA{0, 0, 0, alpha / 2}, shadowOffset},
+		{color.NRGBA{255, 255, 255, alpha}, 0},
+	} {
+		drawer := font.Drawer{
+			Dst:  stamped,
+			Src:  image.NewUniform(layer.color),
+			Face: face,
+			Dot:  fixed.P(x+layer.offset, y+layer.offset),
+		}
+		drawer.DrawString(text)
+	}
+	return nil
+}
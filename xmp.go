@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/rwcarlsen/goexif/exif"
+)
+
+// xmpSidecarTemplate is an XMP sidecar with the fields that Lightroom
+// and digiKam read: Dublin Core texts and keywords, and the date
+var xmpSidecarTemplate = template.Must(template.New("xmp").Funcs(template.FuncMap{"xml": xmlText}).Parse(
+	`<?xpacket begin="` + "\ufeff" + `" id="W5M0MpCehiHzreSzNTczkc9d"?>
+<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description rdf:about=""
+    xmlns:dc="http://purl.org/dc/elements/1.1/"
+    xmlns:xmp="http://ns.adobe.com/xap/1.0/"
+    xmlns:photoshop="http://ns.adobe.com/photoshop/1.0/">
+{{- with .Title}}
+   <dc:title><rdf:Alt><rdf:li xml:lang="x-default">{{xml .}}</rdf:li></rdf:Alt></dc:title>
+{{- end}}
+{{- with .Description}}
+   <dc:description><rdf:Alt><rdf:li xml:lang="x-default">{{xml .}}</rdf:li></rdf:Alt></dc:description>
+{{- end}}
+{{- with .Copyright}}
+   <dc:rights><rdf:Alt><rdf:li xml:lang="x-default">{{xml .}}</rdf:li></rdf:Alt></dc:rights>
+{{- end}}
+{{- with .Keywords}}
+   <dc:subject><rdf:Bag>{{range .}}<rdf:li>{{xml .}}</rdf:li>{{end}}</rdf:Bag></dc:subject>
+{{- end}}
+{{- with .Source}}
+   <dc:source>{{xml .}}</dc:source>
+{{- end}}
+   <photoshop:DateCreated>{{.Date}}</photoshop:DateCreated>
+   <xmp:CreateDate>{{.Date}}</xmp:CreateDate>
+  </rdf:Description>
+ </rdf:RDF>
+</x:xmpmeta>
+<?xpacket end="w"?>
+`))
+
+// xmpSidecarData is what an XMP sidecar tells about a wallpaper
+type xmpSidecarData struct {
+	Title       string
+	Description string
+	Copyright   string
+	Keywords    []string
+	Source      string
+	Date        string
+}
+
+// xmlText escapes a text for XML
+func xmlText(text string) string {
+	var escaped strings.Builder
+	xml.EscapeText(&escaped, []byte(text))
+	return escaped.String()
+}
+
+// xmpSidecarPath returns the path of the XMP sidecar of a wallpaper,
+// its path with .xmp added, as darktable and digiKam expect, so
+// wallpapers with the same name in different formats have their own
+func xmpSidecarPath(imagePath string) string {
+	return imagePath + ".xmp"
+}
+
+// moveWallpaper moves a saved wallpaper together with its XMP
+// sidecar, if it has one
+func moveWallpaper(imagePath string, targetPath string) error {
+	if err := moveFile(imagePath, targetPath); err != nil {
+		return err
+	}
+	if _, err := os.Stat(xmpSidecarPath(imagePath)); err != nil {
+		return nil
+	}
+	return moveFile(xmpSidecarPath(imagePath), xmpSidecarPath(targetPath))
+}
+
+// removeWallpaper removes a saved wallpaper together with its XMP
+// sidecar, if it has one, returning the error of removing the
+// wallpaper even when it didn't exist
+func removeWallpaper(imagePath string) error {
+	err := os.Remove(imagePath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Remove(xmpSidecarPath(imagePath)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return err
+}
+
+// captureDate returns when the photo of a wallpaper was taken
+// according to its EXIF, or when Spotlight delivered its source file
+func captureDate(imagePath string, sourcePath string) time.Time {
+	if imageFile, err := os.Open(imagePath); err == nil {
+		defer imageFile.Close()
+		if info, err := exif.Decode(imageFile); err == nil {
+			if date, err := info.DateTime(); err == nil {
+				return date
+			}
+		}
+	}
+	if info, err := os.Stat(sourcePath); err == nil {
+		return info.ModTime()
+	}
+	return time.Now()
+}
+
+// writeXMPSidecar writes the XMP sidecar of a saved wallpaper with
+// its title, description, copyright, tags as keywords, the name of
+// its Spotlight asset as source and its capture date, and returns
+// its path
+func writeXMPSidecar(entry *indexEntry, captured time.Time) (string, error) {
+	data := xmpSidecarData{
+		Title:       entry.Title,
+		Description: entry.Description,
+		Copyright:   entry.Copyright,
+		Keywords:    entry.Tags,
+		Source:      entry.Source,
+		Date:        captured.Format(time.RFC3339),
+	}
+	var sidecar bytes.Buffer
+	if err := xmpSidecarTemplate.Execute(&sidecar, data); err != nil {
+		return "", err
+	}
+	sidecarPath := xmpSidecarPath(entry.Path)
+	if err := os.WriteFile(sidecarPath, sidecar.Bytes(), 0644); err != nil {
+		return "", fmt.Errorf("couldn't write the sidecar %s", sidecarPath)
+	}
+	return sidecarPath, nil
+}
+
+// writeSidecar writes the XMP sidecar of a wallpaper saved from a
+// source file when XMPSidecars is true, recording it in the journal
+// so undo removes it with the wallpaper
+func (j *job) writeSidecar(ctx *runContext, entry *indexEntry, sourcePath string) {
+	if !j.config.XMPSidecars {
+		return
+	}
+	sidecarPath, err := writeXMPSidecar(entry, captureDate(entry.Path, sourcePath))
+	if err != nil {
+		j.logger.Errorln(err)
+		return
+	}
+	ctx.journal.add(sidecarPath)
+}